@@ -0,0 +1,305 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+const (
+	defaultBaseURL = "https://api.anthropic.com/v1"
+	apiVersion     = "2023-06-01"
+)
+
+// Returned from the event handler once the `message_stop` event is received.
+var errStreamDone = errors.New("stream done")
+
+// The allow-list of Anthropic models that can be requested by the clients.
+var Catalog = llm.Catalog{
+	DefaultModel: "claude-3-5-haiku-20241022",
+	Models: map[string]llm.ModelLimits{
+		"claude-3-5-haiku-20241022":  {ContextWindow: 200000, MaxOutputTokens: 8192},
+		"claude-3-5-sonnet-20241022": {ContextWindow: 200000, MaxOutputTokens: 8192},
+		"claude-3-7-sonnet-20250219": {ContextWindow: 200000, MaxOutputTokens: 64000},
+		"claude-sonnet-4-20250514":   {ContextWindow: 200000, MaxOutputTokens: 64000},
+		"claude-opus-4-20250514":     {ContextWindow: 200000, MaxOutputTokens: 32000},
+	},
+	Pricing: map[string]llm.ModelPricing{
+		"claude-3-5-haiku-20241022":  {InputPerMillion: 0.8, OutputPerMillion: 4},
+		"claude-3-5-sonnet-20241022": {InputPerMillion: 3, OutputPerMillion: 15},
+		"claude-3-7-sonnet-20250219": {InputPerMillion: 3, OutputPerMillion: 15},
+		"claude-sonnet-4-20250514":   {InputPerMillion: 3, OutputPerMillion: 15},
+		"claude-opus-4-20250514":     {InputPerMillion: 15, OutputPerMillion: 75},
+	},
+	MaxTemperature: 1,
+	SupportsSeed:   false,
+	SupportsTools:  true,
+}
+
+// Client talks to Anthropic Messages api.
+type Client struct {
+	apiKey      string
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy llm.RetryPolicy
+}
+
+func NewClient() (*Client, error) {
+	apiKey, set := os.LookupEnv("ANTHROPIC_API_KEY")
+	if !set || apiKey == "" {
+		return nil, fmt.Errorf("anthropic: ANTHROPIC_API_KEY is not set")
+	}
+
+	baseURL, set := os.LookupEnv("ANTHROPIC_BASE_URL")
+	if !set || baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	retryPolicy, err := llm.RetryPolicyFromEnv("ANTHROPIC_MAX_RETRIES")
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %v", err)
+	}
+
+	return &Client{
+		apiKey:      apiKey,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		httpClient:  &http.Client{},
+		retryPolicy: retryPolicy,
+	}, nil
+}
+
+func (c *Client) Name() string {
+	return "anthropic"
+}
+
+func (c *Client) Catalog() *llm.Catalog {
+	return &Catalog
+}
+
+func (c *Client) parseAPIError(resp *http.Response, body []byte) error {
+	apiError := &llm.APIError{
+		Provider:   c.Name(),
+		StatusCode: resp.StatusCode,
+		RetryAfter: llm.ParseRetryAfter(resp.Header, time.Now()),
+	}
+
+	var envelope errorEnvelope
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Message != "" {
+		apiError.Type = envelope.Error.Type
+		apiError.Message = envelope.Error.Message
+	} else {
+		apiError.Message = strings.TrimSpace(string(body))
+		if apiError.Message == "" {
+			apiError.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+
+	// Anthropic doesn't have a dedicated error type for prompts which don't fit into the context window.
+	if apiError.Type == "invalid_request_error" && strings.Contains(apiError.Message, "prompt is too long") {
+		return &llm.ContextLengthExceededError{APIError: apiError}
+	}
+
+	return llm.ClassifyError(apiError)
+}
+
+func (c *Client) send(ctx context.Context, request *models.OpenAIChatRequest) (*http.Response, error) {
+	limits, _ := Catalog.Limits(request.Model)
+
+	body, err := json.Marshal(toMessagesRequest(request, limits.MaxOutputTokens))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: failed to marshal request body: %v", err)
+	}
+
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/messages", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("anthropic: failed to create a request: %v", err)
+		}
+
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Api-Key", c.apiKey)
+		req.Header.Add("Anthropic-Version", apiVersion)
+		if request.Stream {
+			req.Header.Add("Accept", "text/event-stream")
+		}
+
+		return req, nil
+	}
+
+	return llm.Do(ctx, c.httpClient, c.retryPolicy, newRequest, c.parseAPIError)
+}
+
+func (c *Client) Chat(ctx context.Context, request *models.OpenAIChatRequest) (*models.OpenAIResp, error) {
+	resp, err := c.send(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: failed to read the response body: %v", err)
+	}
+
+	var messagesResp messagesResponse
+	if err := json.Unmarshal(respBytes, &messagesResp); err != nil {
+		return nil, fmt.Errorf("anthropic: failed to unmarshal the response body: %v", err)
+	}
+
+	return toOpenAIResp(&messagesResp), nil
+}
+
+// ChatStream translates Anthropic's stream events into OpenAI's chunks.
+// Text deltas, tool calls and the final stop reason are forwarded to onChunk,
+// a tool call arrives as a chunk with its id and name followed by chunks with fragments of its arguments.
+func (c *Client) ChatStream(ctx context.Context, request *models.OpenAIChatRequest, onChunk func(chunk *models.OpenAIStreamChunk) error) error {
+	streamRequest := *request
+	streamRequest.Stream = true
+
+	resp, err := c.send(ctx, &streamRequest)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	model := request.Model
+	// The input tokens are reported when the message starts, the output tokens when it ends.
+	inputTokens := 0
+	// Maps the index of a tool_use content block onto the index of the tool call,
+	// OpenAI counts only the tool calls.
+	toolCallIndex := make(map[int]int)
+
+	err = llm.ReadEvents(resp.Body, func(event string, data string) error {
+		var payload streamEvent
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			return fmt.Errorf("anthropic: failed to unmarshal stream event: %v", err)
+		}
+
+		switch event {
+		case "message_start":
+			model = payload.Message.Model
+			inputTokens = payload.Message.Usage.InputTokens
+
+		case "content_block_start":
+			if payload.ContentBlock.Type != "tool_use" {
+				return nil
+			}
+			index := len(toolCallIndex)
+			toolCallIndex[payload.Index] = index
+			return onChunk(&models.OpenAIStreamChunk{
+				Model: model,
+				Choices: []models.OpenAIStreamChoiceEntry{
+					{Index: 0, Delta: models.OpenAIMessage{Role: "assistant", ToolCalls: []models.OpenAIToolCall{{
+						Index:    &index,
+						ID:       payload.ContentBlock.ID,
+						Type:     "function",
+						Function: models.OpenAIFunctionCall{Name: payload.ContentBlock.Name},
+					}}}},
+				},
+			})
+
+		case "content_block_delta":
+			switch payload.Delta.Type {
+			case "text_delta":
+				return onChunk(&models.OpenAIStreamChunk{
+					Model: model,
+					Choices: []models.OpenAIStreamChoiceEntry{
+						{Index: 0, Delta: models.OpenAIMessage{Role: "assistant", Content: payload.Delta.Text}},
+					},
+				})
+
+			case "input_json_delta":
+				index, ok := toolCallIndex[payload.Index]
+				if !ok {
+					return nil
+				}
+				return onChunk(&models.OpenAIStreamChunk{
+					Model: model,
+					Choices: []models.OpenAIStreamChoiceEntry{
+						{Index: 0, Delta: models.OpenAIMessage{Role: "assistant", ToolCalls: []models.OpenAIToolCall{{
+							Index:    &index,
+							Function: models.OpenAIFunctionCall{Arguments: payload.Delta.PartialJSON},
+						}}}},
+					},
+				})
+			}
+
+		case "message_delta":
+			if payload.Delta.StopReason == "" {
+				return nil
+			}
+			reason := finishReason(payload.Delta.StopReason)
+			return onChunk(&models.OpenAIStreamChunk{
+				Model: model,
+				Choices: []models.OpenAIStreamChoiceEntry{
+					{Index: 0, Delta: models.OpenAIMessage{Role: "assistant"}, FinishReason: &reason},
+				},
+				Usage: &models.OpenAIUsage{
+					PromptTokens:     inputTokens,
+					CompletionTokens: payload.Usage.OutputTokens,
+					TotalTokens:      inputTokens + payload.Usage.OutputTokens,
+				},
+			})
+
+		case "message_stop":
+			return errStreamDone
+
+		case "error":
+			return llm.ClassifyError(&llm.APIError{
+				Provider:   c.Name(),
+				StatusCode: errorStatus(payload.Error.Type),
+				Type:       payload.Error.Type,
+				Message:    payload.Error.Message,
+			})
+		}
+
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errStreamDone):
+		return nil
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("anthropic: stream ended unexpectedly")
+	default:
+		return err
+	}
+}
+
+// errorStatus returns the status code Anthropic uses for the error type,
+// errors delivered as stream events don't have one.
+func errorStatus(errorType string) int {
+	switch errorType {
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	case "not_found_error":
+		return http.StatusNotFound
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Embed is not supported, Anthropic doesn't provide an embeddings api.
+func (c *Client) Embed(ctx context.Context, request *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	return nil, fmt.Errorf("anthropic: embeddings are %w", llm.ErrNotSupported)
+}
@@ -0,0 +1,229 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+func newTestClient(baseURL string) *Client {
+	return &Client{
+		apiKey:     "test-key",
+		baseURL:    baseURL,
+		httpClient: &http.Client{},
+	}
+}
+
+var testRequest = Catalog.NewChatRequest([]models.OpenAIMessage{{Role: "user", Content: "hi"}}, &models.OpenAIParams{})
+
+func TestChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "test-key" || r.Header.Get("Anthropic-Version") != apiVersion {
+			t.Errorf("missing authentication headers")
+		}
+
+		var request messagesRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.System != llm.DefaultSystemPrompt || len(request.Messages) != 1 || request.MaxTokens != defaultMaxTokens {
+			t.Errorf("unexpected request: %+v", request)
+		}
+
+		fmt.Fprint(w, `{"model":"claude","content":[{"type":"text","text":"Hello"},{"type":"text","text":"!"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}`)
+	}))
+	defer server.Close()
+
+	resp, err := newTestClient(server.URL).Chat(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Message.Content != "Hello!" {
+		t.Errorf("expected: Hello!, got: %s", resp.Choices[0].Message.Content)
+	}
+	if resp.Usage == nil || *resp.Usage != (models.OpenAIUsage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}) {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude\",\"usage\":{\"input_tokens\":12}}}\n\n")
+		fmt.Fprint(w, "event: ping\ndata: {\"type\":\"ping\"}\n\n")
+		for _, delta := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":%q}}\n\n", delta)
+		}
+		fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"max_tokens\"},\"usage\":{\"output_tokens\":2}}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	var sb strings.Builder
+	var reason string
+	var usage *models.OpenAIUsage
+	err := newTestClient(server.URL).ChatStream(context.Background(), testRequest, func(chunk *models.OpenAIStreamChunk) error {
+		sb.WriteString(chunk.Choices[0].Delta.Content)
+		if chunk.Choices[0].FinishReason != nil {
+			reason = *chunk.Choices[0].FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "Hello" || reason != "length" {
+		t.Errorf("unexpected stream result: %s, finish reason: %s", sb.String(), reason)
+	}
+	if usage == nil || *usage != (models.OpenAIUsage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	testData := []struct {
+		status int
+		body   string
+		check  func(err error) bool
+	}{
+		{400, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			func(err error) bool { var e *llm.ContextLengthExceededError; return errors.As(err, &e) }},
+		{529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			func(err error) bool { var e *llm.ServerError; return errors.As(err, &e) }},
+		{429, `{"type":"error","error":{"type":"rate_limit_error","message":"Rate limited"}}`,
+			func(err error) bool { var e *llm.RateLimitError; return errors.As(err, &e) }},
+	}
+
+	for _, data := range testData {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(data.status)
+			fmt.Fprint(w, data.body)
+		}))
+
+		_, err := newTestClient(server.URL).Chat(context.Background(), testRequest)
+		server.Close()
+
+		if !data.check(err) {
+			t.Fatalf("unexpected error for status %d: %T %v", data.status, err, err)
+		}
+	}
+}
+
+func TestToolsTranslation(t *testing.T) {
+	request := &models.OpenAIChatRequest{
+		Model: "claude",
+		Messages: []models.OpenAIMessage{
+			{Role: "user", Content: "What time is it in Berlin and what is 6*7?"},
+			{Role: "assistant", ToolCalls: []models.OpenAIToolCall{
+				{ID: "call_1", Type: "function", Function: models.OpenAIFunctionCall{Name: "current_time", Arguments: `{"timezone":"Europe/Berlin"}`}},
+				{ID: "call_2", Type: "function", Function: models.OpenAIFunctionCall{Name: "calculator", Arguments: `{"expression":`}},
+			}},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"time":"2024-01-01T12:00:00+01:00"}`},
+			{Role: "tool", ToolCallID: "call_2", Content: `{"result":42}`},
+		},
+		Tools: []models.OpenAITool{{Type: "function", Function: models.OpenAIFunctionDefinition{
+			Name: "calculator", Parameters: json.RawMessage(`{"type":"object"}`),
+		}}},
+	}
+
+	translated := toMessagesRequest(request, 0)
+
+	if len(translated.Tools) != 1 || translated.Tools[0].Name != "calculator" || string(translated.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("unexpected tools: %+v", translated.Tools)
+	}
+	if len(translated.Messages) != 3 {
+		t.Fatalf("expected the tool results to be merged into a single message, got: %+v", translated.Messages)
+	}
+
+	assistant := translated.Messages[1]
+	if len(assistant.Content) != 2 || assistant.Content[0].Type != "tool_use" || string(assistant.Content[0].Input) != `{"timezone":"Europe/Berlin"}` {
+		t.Errorf("unexpected assistant message: %+v", assistant)
+	}
+	if string(assistant.Content[1].Input) != "{}" {
+		t.Errorf("expected invalid arguments to be replaced with an empty object, got: %s", assistant.Content[1].Input)
+	}
+
+	results := translated.Messages[2]
+	if results.Role != "user" || len(results.Content) != 2 || results.Content[1].ToolUseID != "call_2" || results.Content[1].Content != `{"result":42}` {
+		t.Errorf("unexpected tool results: %+v", results)
+	}
+}
+
+func TestChatToolUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"claude","content":[{"type":"text","text":"Let me calculate."},{"type":"tool_use","id":"toolu_1","name":"calculator","input":{"expression":"6*7"}}],"stop_reason":"tool_use"}`)
+	}))
+	defer server.Close()
+
+	resp, err := newTestClient(server.URL).Chat(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("expected a tool call, got: %+v", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID != "toolu_1" || call.Function.Name != "calculator" || call.Function.Arguments != `{"expression":"6*7"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+}
+
+func TestChatStreamToolUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Calculating\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"calculator\",\"input\":{}}}\n\n")
+		for _, fragment := range []string{`{"expression"`, `: "6*7"}`} {
+			fmt.Fprintf(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":%q}}\n\n", fragment)
+		}
+		fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	var arguments strings.Builder
+	var call models.OpenAIToolCall
+	var reason string
+	err := newTestClient(server.URL).ChatStream(context.Background(), testRequest, func(chunk *models.OpenAIStreamChunk) error {
+		for _, delta := range chunk.Choices[0].Delta.ToolCalls {
+			if *delta.Index != 0 {
+				t.Errorf("expected the tool call index to be 0, got %d", *delta.Index)
+			}
+			if delta.ID != "" {
+				call = delta
+			}
+			arguments.WriteString(delta.Function.Arguments)
+		}
+		if chunk.Choices[0].FinishReason != nil {
+			reason = *chunk.Choices[0].FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.ID != "toolu_1" || call.Function.Name != "calculator" || arguments.String() != `{"expression": "6*7"}` || reason != "tool_calls" {
+		t.Errorf("unexpected tool call: %+v, arguments: %s, finish reason: %s", call, arguments.String(), reason)
+	}
+}
+
+func TestResponseSchemaInstructions(t *testing.T) {
+	request := Catalog.NewChatRequest([]models.OpenAIMessage{{Role: "user", Content: "Extract the name"}},
+		&models.OpenAIParams{ResponseSchema: json.RawMessage(`{"type":"object"}`)})
+
+	translated := toMessagesRequest(request, 0)
+	if !strings.HasPrefix(translated.System, llm.DefaultSystemPrompt) || !strings.HasSuffix(translated.System, `{"type":"object"}`) {
+		t.Errorf("expected the schema to be appended to the system prompt, got: %s", translated.System)
+	}
+}
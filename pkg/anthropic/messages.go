@@ -0,0 +1,218 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Wire format of Anthropic Messages api.
+// See https://docs.anthropic.com/en/api/messages
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type messagesRequest struct {
+	Model         string    `json:"model"`
+	MaxTokens     int       `json:"max_tokens"`
+	System        string    `json:"system,omitempty"`
+	Messages      []message `json:"messages"`
+	Temperature   *float64  `json:"temperature,omitempty"`
+	TopP          *float64  `json:"top_p,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Tools         []tool    `json:"tools,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+}
+
+// A content block of one of the types: text, tool_use or tool_result.
+type contentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// Set on tool_use blocks.
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// Set on tool_result blocks.
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type messagesResponse struct {
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+// The payload of `content_block_start`, `content_block_delta`, `message_delta` and `error` stream events.
+type streamEvent struct {
+	Type string `json:"type"`
+	// The index of the content block, set on content_block_* events.
+	Index        int          `json:"index"`
+	ContentBlock contentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Message struct {
+		Model string `json:"model"`
+		Usage usage  `json:"usage"`
+	} `json:"message"`
+	// Set on message_delta events, the output tokens so far.
+	Usage usage        `json:"usage"`
+	Error errorDetails `json:"error"`
+}
+
+type errorDetails struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type errorEnvelope struct {
+	Type  string       `json:"type"`
+	Error errorDetails `json:"error"`
+}
+
+// Anthropic requires max_tokens to be set, this is used when the client didn't ask for a limit.
+const defaultMaxTokens = 4096
+
+const responseSchemaInstructions = "Respond only with a JSON document that conforms to the following JSON schema. " +
+	"Do not wrap the document in a code block and do not add any other text.\n\n%s"
+
+// toMessagesRequest translates a chat completion request into Anthropic's format.
+// System messages are moved into the top-level system prompt,
+// tool calls become tool_use blocks and tool results become tool_result blocks of a user message.
+func toMessagesRequest(request *models.OpenAIChatRequest, maxOutputTokens int) *messagesRequest {
+	maxTokens := defaultMaxTokens
+	if maxOutputTokens > 0 && maxOutputTokens < maxTokens {
+		maxTokens = maxOutputTokens
+	}
+	if request.MaxTokens != nil {
+		maxTokens = *request.MaxTokens
+	}
+
+	var system []string
+	messages := make([]message, 0, len(request.Messages))
+	for _, m := range request.Messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+
+		case "tool":
+			block := contentBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}
+			// Results of the calls made in a single turn have to be sent in a single message.
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "user" && messages[last].Content[0].Type == "tool_result" {
+				messages[last].Content = append(messages[last].Content, block)
+				continue
+			}
+			messages = append(messages, message{Role: "user", Content: []contentBlock{block}})
+
+		default:
+			var content []contentBlock
+			// Anthropic rejects empty text blocks, and assistant messages with tool calls usually have no text.
+			if m.Content != "" || len(m.ToolCalls) == 0 {
+				content = append(content, contentBlock{Type: "text", Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				content = append(content, contentBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+			messages = append(messages, message{Role: m.Role, Content: content})
+		}
+	}
+
+	// Anthropic has no equivalent of response_format, the best it can do is to follow the instructions.
+	if request.ResponseFormat != nil && request.ResponseFormat.JSONSchema != nil {
+		system = append(system, fmt.Sprintf(responseSchemaInstructions, request.ResponseFormat.JSONSchema.Schema))
+	}
+
+	var tools []tool
+	for _, definition := range request.Tools {
+		tools = append(tools, tool{
+			Name:        definition.Function.Name,
+			Description: definition.Function.Description,
+			InputSchema: definition.Function.Parameters,
+		})
+	}
+
+	return &messagesRequest{
+		Model:         request.Model,
+		MaxTokens:     maxTokens,
+		System:        strings.Join(system, "\n\n"),
+		Messages:      messages,
+		Temperature:   request.Temperature,
+		TopP:          request.TopP,
+		StopSequences: request.Stop,
+		Tools:         tools,
+		Stream:        request.Stream,
+	}
+}
+
+// finishReason maps Anthropic's stop reasons onto OpenAI's finish reasons.
+func finishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+func toOpenAIResp(resp *messagesResponse) *models.OpenAIResp {
+	var content strings.Builder
+	var toolCalls []models.OpenAIToolCall
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, models.OpenAIToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: models.OpenAIFunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+
+	return &models.OpenAIResp{
+		Model: resp.Model,
+		Choices: []models.OpenAIChoiceEntry{
+			{
+				Index: 0,
+				Message: models.OpenAIMessage{
+					Role:      "assistant",
+					Content:   content.String(),
+					ToolCalls: toolCalls,
+				},
+				FinishReason: finishReason(resp.StopReason),
+			},
+		},
+		Usage: &models.OpenAIUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+}
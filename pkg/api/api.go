@@ -6,37 +6,104 @@ import (
 	"os"
 	"time"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 
+	"github.com/isnastish/openai/pkg/anthropic"
 	"github.com/isnastish/openai/pkg/auth"
+	"github.com/isnastish/openai/pkg/cache"
 	"github.com/isnastish/openai/pkg/db"
 	firebase "github.com/isnastish/openai/pkg/db/firestore"
+	"github.com/isnastish/openai/pkg/db/memory"
 	"github.com/isnastish/openai/pkg/db/mongodb"
 	"github.com/isnastish/openai/pkg/db/postgres"
 	emailservice "github.com/isnastish/openai/pkg/email_service"
 	"github.com/isnastish/openai/pkg/ipresolver"
+	"github.com/isnastish/openai/pkg/llm"
+	"github.com/isnastish/openai/pkg/llm/router"
 	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/ollama"
 	"github.com/isnastish/openai/pkg/openai"
+	"github.com/isnastish/openai/pkg/redact"
+	"github.com/isnastish/openai/pkg/tools"
 )
 
 type App struct {
 	fiberApp         *fiber.App
-	openaiClient     *openai.Client
+	llmProvider      llm.Provider
+	tools            *tools.Registry
 	ipResolverClient *ipresolver.Client
 	auth             *auth.AuthManager
 	dbController     db.DatabaseController
-	port             int
+	vectorStore      db.VectorStore
+	contextStrategy  string
+	quotas           *quotas
+	cache            cache.Cache
+	moderation       *moderationPolicy
+	redactor         *redact.Redactor
+	// Email domains which stand for organisations, templates can be shared within them.
+	organizations map[string]bool
+	port          int
 
-	// TODO: Work on naming the package and the service itself.
-	awsEmailService *emailservice.AWSEmailService
+	emailService  emailservice.Sender
+	accountEmails *accountEmails
+}
+
+// newLLMProvider creates the client of the given llm backend.
+func newLLMProvider(backend string) (llm.Provider, error) {
+	switch backend {
+	case "openai":
+		client, err := openai.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an OpenAI client, error: %v", err)
+		}
+		return client, nil
+
+	case "anthropic":
+		client, err := anthropic.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an Anthropic client, error: %v", err)
+		}
+		return client, nil
+
+	case "ollama":
+		client, err := ollama.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an Ollama client, error: %v", err)
+		}
+		return client, nil
+	}
+
+	return nil, fmt.Errorf("unknown llm backend %q", backend)
 }
 
 func NewApp(port int /* TODO: pass a secret */) (*App, error) {
-	openaiClient, err := openai.NewClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create an OpenAI client, error: %v", err)
+	var llmProvider llm.Provider
+	var err error
+
+	// A routing config spreads the requests across several backends,
+	// otherwise all of them go to a single one.
+	if routingConfigPath, set := os.LookupEnv("LLM_ROUTING_CONFIG"); set && routingConfigPath != "" {
+		routingConfig, err := router.LoadConfig(routingConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		llmProvider, err = router.New(routingConfig, newLLMProvider)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		llmBackend, set := os.LookupEnv("LLM_BACKEND")
+		if !set || llmBackend == "" {
+			llmBackend = "openai"
+		}
+		llmProvider, err = newLLMProvider(llmBackend)
+		if err != nil {
+			return nil, err
+		}
 	}
+	log.Logger.Info("using %s llm backend", llmProvider.Name())
 
 	ipResolverClient, err := ipresolver.NewClient()
 	if err != nil {
@@ -78,16 +145,105 @@ func NewApp(port int /* TODO: pass a secret */) (*App, error) {
 		return nil, fmt.Errorf("unknown backend")
 	}
 
-	// NOTE: This is a work-around for now.
-	var awsEmailService *emailservice.AWSEmailService
-	if false {
-		awsEmailService, err = emailservice.NewAWSEmailService()
+	// The in-memory store is lost on restart, it's meant for development and tests.
+	vectorBackend, set := os.LookupEnv("VECTOR_STORE")
+	if !set || vectorBackend == "" {
+		vectorBackend = "memory"
+	}
+
+	var vectorStore db.VectorStore
+
+	switch vectorBackend {
+	case "memory":
+		vectorStore = memory.NewVectorStore()
+	case "postgres":
+		vectorStore, err = postgres.NewPostgresVectorStore(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to initialize aws mailing service, %v", err)
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", vectorBackend)
+	}
+	log.Logger.Info("using %s vector store", vectorBackend)
+
+	// Before users had IDs their data was owned by their email, it's moved to the ID before any request is served.
+	// Scanning every owner may take longer than connecting, so it gets its own deadline.
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancelMigrate()
+	if err := dbController.MigrateOwners(migrateCtx); err != nil {
+		return nil, err
+	}
+	if err := vectorStore.MigrateOwners(migrateCtx); err != nil {
+		return nil, err
+	}
+
+	contextStrategy, set := os.LookupEnv("CONTEXT_STRATEGY")
+	if !set || contextStrategy == "" {
+		contextStrategy = contextDropOldest
+	}
+	if !validContextStrategy(contextStrategy) {
+		return nil, fmt.Errorf("CONTEXT_STRATEGY must be either %s or %s", contextDropOldest, contextSummarize)
+	}
+
+	responseCache, err := newCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if responseCache == nil {
+		log.Logger.Info("response cache is disabled")
+	}
+
+	moderationPolicy, err := newModerationPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if moderationPolicy == nil {
+		log.Logger.Info("moderation is disabled")
+	}
+
+	redactor, err := newRedactor()
+	if err != nil {
+		return nil, err
+	}
+	if redactor != nil {
+		log.Logger.Info("redacting personal data from the prompts")
+	}
+
+	quotas, err := loadQuotas()
+	if err != nil {
+		return nil, err
+	}
+
+	toolRegistry := tools.NewRegistry()
+	for _, tool := range []tools.Tool{
+		tools.CurrentTime(),
+		tools.Calculator(),
+		tools.UserProfile(dbController.GetUserByID),
+	} {
+		if err := toolRegistry.Register(tool); err != nil {
+			return nil, err
 		}
 	}
 
+	emailService, err := newEmailService()
+	if err != nil {
+		return nil, err
+	}
+
+	accountEmails, err := newAccountEmails(port)
+	if err != nil {
+		return nil, err
+	}
+	if !accountEmails.requireVerification {
+		log.Logger.Info("email verification is not required")
+	}
+
 	accessTokenTTL := time.Minute * 15
+	authManager, err := newAuthManager(accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	app := &App{
 		fiberApp: fiber.New(fiber.Config{
 			// TODO: Figure out the prefork parameter.
@@ -95,37 +251,94 @@ func NewApp(port int /* TODO: pass a secret */) (*App, error) {
 			Prefork:      false,
 			ServerHeader: "Fiber",
 		}),
-		openaiClient:     openaiClient,
+		llmProvider:      llmProvider,
+		tools:            toolRegistry,
 		ipResolverClient: ipResolverClient,
-		auth:             auth.NewAuthManager([]byte("my-dummy-secret"), accessTokenTTL),
+		auth:             authManager,
 		dbController:     dbController,
+		vectorStore:      vectorStore,
+		contextStrategy:  contextStrategy,
+		quotas:           quotas,
+		cache:            responseCache,
+		moderation:       moderationPolicy,
+		redactor:         redactor,
+		organizations:    loadOrganizations(),
 		port:             port,
-		awsEmailService:  awsEmailService,
+		emailService:     emailService,
+		accountEmails:    accountEmails,
 	}
 
+	app.registerRoutes()
+
+	return app, nil
+}
+
+// registerRoutes installs the middleware and the routes of the server.
+func (a *App) registerRoutes() {
 	// CORS middleware
-	app.fiberApp.Use("/", SetupCORSMiddleware)
+	a.fiberApp.Use("/", SetupCORSMiddleware)
 
 	// logging middleware
-	app.fiberApp.Use(logger.New(logger.Config{
+	a.fiberApp.Use(logger.New(logger.Config{
 		Format: "[${ip}]:${port} latency:${latency} ${status} - ${method} ${path}\n",
 	}))
 
 	// We need to apply auth middleware only to certain routes.
 	// The middleware would be invoked only for routes starting with openai
-	app.fiberApp.Use("/protected", func(ctx *fiber.Ctx) error {
-		return app.auth.AuthorizationMiddleware(ctx)
+	a.fiberApp.Use("/protected", func(ctx *fiber.Ctx) error {
+		return a.auth.AuthorizationMiddleware(ctx)
 	})
+	a.fiberApp.Use("/protected", a.VerifiedEmailMiddleware)
+
+	a.fiberApp.Post("/signup", a.SignupRoute)
+	a.fiberApp.Post("/login", a.LoginRoute)
+	a.fiberApp.Get("/logout", a.LogoutRoute)
+	a.fiberApp.Get("/refresh", a.RefreshTokensRoute)
+	a.fiberApp.Get("/verify-email", a.VerifyEmailRoute)
+	a.fiberApp.Post("/verify-email/resend", a.ResendVerificationEmailRoute)
+	a.fiberApp.Post("/forgot-password", a.ForgotPasswordRoute)
+	a.fiberApp.Post("/reset-password", a.ResetPasswordRoute)
+	a.fiberApp.Get("/.well-known/jwks.json", a.JWKSRoute)
 
-	app.fiberApp.Post("/signup", app.SignupRoute)
-	app.fiberApp.Post("/login", app.LoginRoute)
-	app.fiberApp.Get("/logout", app.LogoutRoute)
-	app.fiberApp.Get("/refresh", app.RefreshTokensRoute)
+	a.fiberApp.Post("/protected/change-password", a.ChangePasswordRoute)
 
 	// NOTE: This route should be accessed only if the authentication passes.
-	app.fiberApp.Post("/protected/openai", app.OpenAIRoute)
+	a.fiberApp.Post("/protected/openai", a.OpenAIRoute)
+	a.fiberApp.Post("/protected/openai/stream", a.OpenAIStreamRoute)
 
-	return app, nil
+	a.fiberApp.Post("/protected/embeddings", a.EmbeddingsRoute)
+
+	a.fiberApp.Get("/protected/usage", a.UsageRoute)
+
+	a.fiberApp.Post("/protected/documents", a.UploadDocumentRoute)
+	a.fiberApp.Get("/protected/documents", a.ListDocumentsRoute)
+	a.fiberApp.Get("/protected/documents/:id", a.GetDocumentRoute)
+	a.fiberApp.Delete("/protected/documents/:id", a.DeleteDocumentRoute)
+
+	a.fiberApp.Post("/protected/templates", a.CreateTemplateRoute)
+	a.fiberApp.Get("/protected/templates", a.ListTemplatesRoute)
+	a.fiberApp.Get("/protected/templates/:id", a.GetTemplateRoute)
+	a.fiberApp.Patch("/protected/templates/:id", a.UpdateTemplateRoute)
+	a.fiberApp.Delete("/protected/templates/:id", a.DeleteTemplateRoute)
+	a.fiberApp.Post("/protected/templates/:id/versions", a.AddTemplateVersionRoute)
+	a.fiberApp.Get("/protected/templates/:id/versions/:version", a.GetTemplateVersionRoute)
+	a.fiberApp.Post("/protected/templates/:id/run", a.RunTemplateRoute)
+	a.fiberApp.Post("/protected/assistants", a.CreateAssistantRoute)
+	a.fiberApp.Get("/protected/assistants", a.ListAssistantsRoute)
+	a.fiberApp.Get("/protected/assistants/:id", a.GetAssistantRoute)
+	a.fiberApp.Patch("/protected/assistants/:id", a.UpdateAssistantRoute)
+	a.fiberApp.Delete("/protected/assistants/:id", a.DeleteAssistantRoute)
+
+	a.fiberApp.Post("/protected/conversations", a.CreateConversationRoute)
+	a.fiberApp.Get("/protected/conversations", a.ListConversationsRoute)
+	// Registered before the routes of a single conversation, so search, export and import aren't taken for ids.
+	a.fiberApp.Get("/protected/conversations/search", a.SearchConversationsRoute)
+	a.fiberApp.Get("/protected/conversations/export", a.ExportConversationsRoute)
+	a.fiberApp.Post("/protected/conversations/import", a.ImportConversationsRoute)
+	a.fiberApp.Get("/protected/conversations/:id", a.GetConversationRoute)
+	a.fiberApp.Delete("/protected/conversations/:id", a.DeleteConversationRoute)
+	a.fiberApp.Get("/protected/conversations/:id/export", a.ExportConversationRoute)
+	a.fiberApp.Get("/protected/conversations/:id/ws", a.ChatSocketUpgradeRoute, websocket.New(a.ChatSocketRoute))
 }
 
 func (a *App) Serve() error {
@@ -141,6 +354,10 @@ func (a *App) Serve() error {
 func (a *App) Shutdown() error {
 	// TODO: Create a context with timeout?
 	defer a.dbController.Close(context.Background())
+	defer a.vectorStore.Close(context.Background())
+	if a.cache != nil {
+		defer a.cache.Close()
+	}
 
 	// TODO: Use ShutdownWithContext instead
 	if err := a.fiberApp.Shutdown(); err != nil {
@@ -0,0 +1,635 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/auth"
+	"github.com/isnastish/openai/pkg/db/memory"
+	emailservice "github.com/isnastish/openai/pkg/email_service"
+	"github.com/isnastish/openai/pkg/llm"
+	"github.com/isnastish/openai/pkg/tools"
+)
+
+const testModel = "gpt-4o-mini"
+
+// fakeDatabase keeps everything in memory, the lookups follow the contract of db.DatabaseController.
+type fakeDatabase struct {
+	mu sync.Mutex
+
+	users               map[string]*models.UserData
+	refreshTokens       map[string]*models.RefreshToken
+	passwordResetTokens map[string]*models.PasswordResetToken
+	passwordResetSentAt map[string]time.Time
+	conversations       map[string]*models.Conversation
+	messages            []models.ConversationMessage
+	assistants          map[string]*models.Assistant
+	usage               []models.UsageRecord
+}
+
+func newFakeDatabase() *fakeDatabase {
+	return &fakeDatabase{
+		users:               make(map[string]*models.UserData),
+		refreshTokens:       make(map[string]*models.RefreshToken),
+		passwordResetTokens: make(map[string]*models.PasswordResetToken),
+		passwordResetSentAt: make(map[string]time.Time),
+		conversations:       make(map[string]*models.Conversation),
+		assistants:          make(map[string]*models.Assistant),
+	}
+}
+
+func (db *fakeDatabase) AddUser(_ context.Context, userData *models.UserData, _ *models.Geolocation) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	user := *userData
+	db.users[user.ID] = &user
+	return nil
+}
+
+func (db *fakeDatabase) GetUserByEmail(_ context.Context, email string) (*models.UserData, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, user := range db.users {
+		if user.Email == email {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (db *fakeDatabase) GetUserByID(_ context.Context, id string) (*models.UserData, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	user, ok := db.users[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *user
+	return &found, nil
+}
+
+func (db *fakeDatabase) MigrateOwners(_ context.Context) error {
+	return nil
+}
+
+func (db *fakeDatabase) MarkEmailVerified(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if user, ok := db.users[id]; ok {
+		user.EmailVerified = true
+	}
+	return nil
+}
+
+func (db *fakeDatabase) UpdatePassword(_ context.Context, id string, password string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if user, ok := db.users[id]; ok {
+		user.Password = password
+	}
+	return nil
+}
+
+func (db *fakeDatabase) RecordVerificationEmail(_ context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	user, ok := db.users[id]
+	if !ok || (user.VerificationSentAt != nil && user.VerificationSentAt.After(sentAt.Add(-interval))) {
+		return false, nil
+	}
+	user.VerificationSentAt = &sentAt
+	return true, nil
+}
+
+func (db *fakeDatabase) RecordPasswordResetEmail(_ context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	previous, sent := db.passwordResetSentAt[id]
+	if _, ok := db.users[id]; !ok || (sent && previous.After(sentAt.Add(-interval))) {
+		return false, nil
+	}
+	db.passwordResetSentAt[id] = sentAt
+	return true, nil
+}
+
+func (db *fakeDatabase) CreateRefreshToken(_ context.Context, token *models.RefreshToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	stored := *token
+	db.refreshTokens[token.ID] = &stored
+	return nil
+}
+
+func (db *fakeDatabase) GetRefreshToken(_ context.Context, id string) (*models.RefreshToken, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	token, ok := db.refreshTokens[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *token
+	return &found, nil
+}
+
+func (db *fakeDatabase) RotateRefreshToken(_ context.Context, id string, rotatedAt time.Time) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	token, ok := db.refreshTokens[id]
+	if !ok || token.RotatedAt != nil || token.Revoked {
+		return false, nil
+	}
+	token.RotatedAt = &rotatedAt
+	return true, nil
+}
+
+func (db *fakeDatabase) RevokeRefreshTokenFamily(_ context.Context, family string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, token := range db.refreshTokens {
+		if token.Family == family {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (db *fakeDatabase) RevokeRefreshTokens(_ context.Context, owner string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, token := range db.refreshTokens {
+		if token.Owner == owner {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (db *fakeDatabase) CreatePasswordResetToken(_ context.Context, token *models.PasswordResetToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	stored := *token
+	db.passwordResetTokens[token.ID] = &stored
+	return nil
+}
+
+func (db *fakeDatabase) UsePasswordResetToken(_ context.Context, id string, usedAt time.Time) (*models.PasswordResetToken, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	token, ok := db.passwordResetTokens[id]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(usedAt) {
+		return nil, nil
+	}
+	token.UsedAt = &usedAt
+	used := *token
+	return &used, nil
+}
+
+func (db *fakeDatabase) DeletePasswordResetTokens(_ context.Context, owner string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for id, token := range db.passwordResetTokens {
+		if token.Owner == owner {
+			delete(db.passwordResetTokens, id)
+		}
+	}
+	return nil
+}
+
+func (db *fakeDatabase) CreateConversation(_ context.Context, conversation *models.Conversation) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	stored := *conversation
+	db.conversations[conversation.ID] = &stored
+	return nil
+}
+
+func (db *fakeDatabase) GetConversation(_ context.Context, owner string, id string) (*models.Conversation, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	conversation, ok := db.conversations[id]
+	if !ok || conversation.Owner != owner {
+		return nil, nil
+	}
+	found := *conversation
+	return &found, nil
+}
+
+func (db *fakeDatabase) ListConversations(_ context.Context, owner string) ([]models.Conversation, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var conversations []models.Conversation
+	for _, conversation := range db.conversations {
+		if conversation.Owner == owner {
+			conversations = append(conversations, *conversation)
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+	})
+	return conversations, nil
+}
+
+func (db *fakeDatabase) DeleteConversation(_ context.Context, owner string, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if conversation, ok := db.conversations[id]; !ok || conversation.Owner != owner {
+		return nil
+	}
+	delete(db.conversations, id)
+	db.messages = slices.DeleteFunc(db.messages, func(message models.ConversationMessage) bool {
+		return message.ConversationID == id
+	})
+	return nil
+}
+
+func (db *fakeDatabase) AddMessage(_ context.Context, message *models.ConversationMessage) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.messages = append(db.messages, *message)
+	if conversation, ok := db.conversations[message.ConversationID]; ok && message.CreatedAt.After(conversation.UpdatedAt) {
+		conversation.UpdatedAt = message.CreatedAt
+	}
+	return nil
+}
+
+func (db *fakeDatabase) GetMessages(_ context.Context, conversationID string) ([]models.ConversationMessage, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var messages []models.ConversationMessage
+	for _, message := range db.messages {
+		if message.ConversationID == conversationID {
+			messages = append(messages, message)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+func (db *fakeDatabase) DeleteMessages(_ context.Context, conversationID string, ids []string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.messages = slices.DeleteFunc(db.messages, func(message models.ConversationMessage) bool {
+		return message.ConversationID == conversationID && slices.Contains(ids, message.ID)
+	})
+	return nil
+}
+
+func (db *fakeDatabase) SearchMessages(_ context.Context, _ string, _ *models.MessageSearchQuery) ([]models.MessageSearchHit, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) CreateAssistant(_ context.Context, assistant *models.Assistant) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	stored := *assistant
+	db.assistants[assistant.ID] = &stored
+	return nil
+}
+
+func (db *fakeDatabase) GetAssistant(_ context.Context, owner string, id string) (*models.Assistant, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	assistant, ok := db.assistants[id]
+	if !ok || assistant.Owner != owner {
+		return nil, nil
+	}
+	found := *assistant
+	return &found, nil
+}
+
+func (db *fakeDatabase) ListAssistants(_ context.Context, _ string) ([]models.Assistant, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) UpdateAssistant(_ context.Context, _ *models.Assistant) error {
+	return nil
+}
+
+func (db *fakeDatabase) DeleteAssistant(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (db *fakeDatabase) CreateDocument(_ context.Context, _ *models.Document) error {
+	return nil
+}
+
+func (db *fakeDatabase) GetDocument(_ context.Context, _ string, _ string) (*models.Document, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) ListDocuments(_ context.Context, _ string, _ string) ([]models.Document, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) DeleteDocument(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (db *fakeDatabase) CreatePromptTemplate(_ context.Context, _ *models.PromptTemplate, _ *models.PromptTemplateVersion) error {
+	return nil
+}
+
+func (db *fakeDatabase) GetPromptTemplate(_ context.Context, _ string, _ string, _ string) (*models.PromptTemplate, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) ListPromptTemplates(_ context.Context, _ string, _ string) ([]models.PromptTemplate, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) UpdatePromptTemplate(_ context.Context, _ *models.PromptTemplate) error {
+	return nil
+}
+
+func (db *fakeDatabase) DeletePromptTemplate(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (db *fakeDatabase) AddPromptTemplateVersion(_ context.Context, _ *models.PromptTemplateVersion) error {
+	return nil
+}
+
+func (db *fakeDatabase) GetPromptTemplateVersion(_ context.Context, _ string, _ int) (*models.PromptTemplateVersion, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) ListPromptTemplateVersions(_ context.Context, _ string) ([]models.PromptTemplateVersion, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) AddUsage(_ context.Context, record *models.UsageRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.usage = append(db.usage, *record)
+	return nil
+}
+
+func (db *fakeDatabase) GetUsage(_ context.Context, _ string, _ time.Time, _ time.Time) ([]models.ModelUsage, error) {
+	return nil, nil
+}
+
+func (db *fakeDatabase) AddModerationEvent(_ context.Context, _ *models.ModerationEvent) error {
+	return nil
+}
+
+func (db *fakeDatabase) Close(_ context.Context) error {
+	return nil
+}
+
+// fakeProvider answers every question with the same answer, streamed one word at a time.
+type fakeProvider struct {
+	catalog *llm.Catalog
+	answer  string
+	// The model which answers, the one asked for if empty, like a router falling back to another upstream does.
+	model string
+	// Reported with the answer, nil leaves it to be estimated.
+	usage *models.OpenAIUsage
+	// Receives a value whenever a stream starts blocking.
+	started chan struct{}
+
+	mu sync.Mutex
+	// Blocks the stream after the answer until the context is cancelled.
+	block    bool
+	requests []*models.OpenAIChatRequest
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{
+		catalog: &llm.Catalog{
+			DefaultModel:   testModel,
+			Models:         map[string]llm.ModelLimits{testModel: {ContextWindow: 128000, MaxOutputTokens: 16384}},
+			Pricing:        map[string]llm.ModelPricing{testModel: {InputPerMillion: 0.15, OutputPerMillion: 0.6}},
+			MaxTemperature: 2,
+		},
+		answer:  "Hello there",
+		started: make(chan struct{}, 1),
+	}
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Catalog() *llm.Catalog {
+	return p.catalog
+}
+
+// record keeps the request and reports whether the stream should block.
+func (p *fakeProvider) record(request *models.OpenAIChatRequest) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, request)
+	return p.block
+}
+
+func (p *fakeProvider) setBlock(block bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.block = block
+}
+
+// answeredBy returns the model which answers the request.
+func (p *fakeProvider) answeredBy(request *models.OpenAIChatRequest) string {
+	if p.model != "" {
+		return p.model
+	}
+	return request.Model
+}
+
+func (p *fakeProvider) Chat(_ context.Context, request *models.OpenAIChatRequest) (*models.OpenAIResp, error) {
+	p.record(request)
+	return &models.OpenAIResp{
+		Model:   p.answeredBy(request),
+		Choices: []models.OpenAIChoiceEntry{{Message: models.OpenAIMessage{Role: "assistant", Content: p.answer}}},
+		Usage:   p.usage,
+	}, nil
+}
+
+func (p *fakeProvider) ChatStream(ctx context.Context, request *models.OpenAIChatRequest, onChunk func(chunk *models.OpenAIStreamChunk) error) error {
+	block := p.record(request)
+	for i, word := range strings.SplitAfter(p.answer, " ") {
+		chunk := &models.OpenAIStreamChunk{
+			Model:   p.answeredBy(request),
+			Choices: []models.OpenAIStreamChoiceEntry{{Index: i, Delta: models.OpenAIMessage{Content: word}}},
+		}
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	if block {
+		p.started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if p.usage != nil {
+		return onChunk(&models.OpenAIStreamChunk{Model: p.answeredBy(request), Usage: p.usage})
+	}
+	return nil
+}
+
+func (p *fakeProvider) Embed(_ context.Context, _ *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	return nil, llm.ErrNotSupported
+}
+
+func (p *fakeProvider) requestCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.requests)
+}
+
+func (p *fakeProvider) request(i int) *models.OpenAIChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[i]
+}
+
+type fakeEmail struct {
+	subject string
+	body    string
+}
+
+// fakeEmailSender keeps the emails instead of sending them, err is returned by every send.
+type fakeEmailSender struct {
+	mu     sync.Mutex
+	emails []fakeEmail
+	err    error
+}
+
+func (s *fakeEmailSender) SendEmail(_ context.Context, messageBody string, subject string, _ string, _ emailservice.Recipient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.emails = append(s.emails, fakeEmail{subject: subject, body: messageBody})
+	return nil
+}
+
+func (s *fakeEmailSender) sent() []fakeEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.emails)
+}
+
+type testApp struct {
+	*App
+	db       *fakeDatabase
+	provider *fakeProvider
+	emails   *fakeEmailSender
+}
+
+// newTestApp creates an app with all the routes, backed by the fakes.
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	db := newFakeDatabase()
+	provider := newFakeProvider()
+	emails := &fakeEmailSender{}
+
+	app := &App{
+		fiberApp:        fiber.New(),
+		llmProvider:     provider,
+		tools:           tools.NewRegistry(),
+		auth:            auth.NewAuthManager([]byte("test-secret"), time.Minute),
+		dbController:    db,
+		vectorStore:     memory.NewVectorStore(),
+		contextStrategy: contextDropOldest,
+		quotas:          &quotas{},
+		emailService:    emails,
+		accountEmails: &accountEmails{
+			requireVerification: true,
+			from:                "no-reply@localhost",
+			verificationLink:    "http://localhost/verify-email",
+			resetLink:           "http://localhost/reset-password",
+		},
+	}
+	app.registerRoutes()
+
+	return &testApp{App: app, db: db, provider: provider, emails: emails}
+}
+
+// addUser adds a user with the password, the hash is computed with the lowest cost to keep the tests fast.
+func (a *testApp) addUser(t *testing.T, email string, password string, verified bool) *models.UserData {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.UserData{
+		ID:            "id-" + email,
+		FirstName:     "Ada",
+		Email:         email,
+		Password:      string(hash),
+		EmailVerified: verified,
+	}
+	if err := a.db.AddUser(context.Background(), user, &models.Geolocation{}); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (a *testApp) accessToken(t *testing.T, user *models.UserData) string {
+	t.Helper()
+
+	tokens, err := a.auth.GetTokens(user.ID, user.Email, user.EmailVerified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens.AccessToken
+}
+
+// request sends the request to the app, the body is encoded as json unless it's nil.
+func (a *testApp) request(t *testing.T, method string, path string, accessToken string, body any) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := a.fiberApp.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// decode reads the json body of the response into a value of type T.
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+
+	var value T
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
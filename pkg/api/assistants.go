@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/schema"
+)
+
+const (
+	maxAssistantNameLength        = 256
+	maxAssistantDescriptionLength = 2048
+	// Every collection is a separate query to the vector store.
+	maxRetrievalCollections = 20
+)
+
+// validateCollections checks the names of the collections an answer is retrieved from.
+func validateCollections(collections []string) error {
+	if len(collections) > maxRetrievalCollections {
+		return fmt.Errorf("%w: at most %d collections are allowed, got %d", errBadRequest, maxRetrievalCollections, len(collections))
+	}
+	for _, collection := range collections {
+		if err := validateCollection(collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyAssistantRequest validates the fields of the request and sets the ones which are present on the assistant.
+func (a *App) applyAssistantRequest(assistant *models.Assistant, request *models.AssistantRequest) error {
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return fmt.Errorf("%w: name is required", errBadRequest)
+		}
+		if utf8.RuneCountInString(name) > maxAssistantNameLength {
+			return fmt.Errorf("%w: name is longer than %d characters", errBadRequest, maxAssistantNameLength)
+		}
+		assistant.Name = name
+	}
+
+	if request.Description != nil {
+		description := strings.TrimSpace(*request.Description)
+		if utf8.RuneCountInString(description) > maxAssistantDescriptionLength {
+			return fmt.Errorf("%w: description is longer than %d characters", errBadRequest, maxAssistantDescriptionLength)
+		}
+		assistant.Description = description
+	}
+
+	if request.Params != nil {
+		params := *request.Params
+		if err := a.llmProvider.Catalog().ValidateParams(&params); err != nil {
+			return fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+		if _, err := a.toolDefinitions(&params); err != nil {
+			return err
+		}
+		if len(params.ResponseSchema) > 0 {
+			if _, err := schema.Compile(params.ResponseSchema); err != nil {
+				return fmt.Errorf("%w: invalid response_schema, %v", errBadRequest, err)
+			}
+		}
+		assistant.Params = params
+	}
+
+	if request.Collections != nil {
+		collections := *request.Collections
+		if err := validateCollections(collections); err != nil {
+			return err
+		}
+		if collections == nil {
+			collections = []string{}
+		}
+		assistant.Collections = collections
+	}
+
+	return nil
+}
+
+func (a *App) createAssistantController(ctx context.Context, owner string, requestBody []byte) (*models.Assistant, error) {
+	request, err := unmarshalRequestData[models.AssistantRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	if request.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", errBadRequest)
+	}
+
+	now := time.Now().UTC()
+	assistant := &models.Assistant{
+		ID:          uuid.NewString(),
+		Owner:       owner,
+		Collections: []string{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := a.applyAssistantRequest(assistant, request); err != nil {
+		return nil, err
+	}
+
+	if err := a.dbController.CreateAssistant(ctx, assistant); err != nil {
+		return nil, err
+	}
+
+	return assistant, nil
+}
+
+func (a *App) getAssistantController(ctx context.Context, owner string, id string) (*models.Assistant, error) {
+	assistant, err := a.dbController.GetAssistant(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if assistant == nil {
+		return nil, fmt.Errorf("%w: assistant %s", errNotFound, id)
+	}
+	return assistant, nil
+}
+
+func (a *App) updateAssistantController(ctx context.Context, owner string, id string, requestBody []byte) (*models.Assistant, error) {
+	request, err := unmarshalRequestData[models.AssistantRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	assistant, err := a.getAssistantController(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.applyAssistantRequest(assistant, request); err != nil {
+		return nil, err
+	}
+	assistant.UpdatedAt = time.Now().UTC()
+
+	if err := a.dbController.UpdateAssistant(ctx, assistant); err != nil {
+		return nil, err
+	}
+
+	return assistant, nil
+}
+
+func (a *App) deleteAssistantController(ctx context.Context, owner string, id string) error {
+	if _, err := a.getAssistantController(ctx, owner, id); err != nil {
+		return err
+	}
+
+	return a.dbController.DeleteAssistant(ctx, owner, id)
+}
+
+// withAssistant returns a copy of the query with the parameters the client omitted taken from the assistant.
+// The documents of the assistant are only searched if the client didn't ask for retrieval itself.
+func withAssistant(query *models.OpenAIRequest, assistant *models.Assistant) *models.OpenAIRequest {
+	result := *query
+	params := &result.OpenAIParams
+	defaults := &assistant.Params
+
+	if params.Model == "" {
+		params.Model = defaults.Model
+	}
+	if params.Temperature == nil {
+		params.Temperature = defaults.Temperature
+	}
+	if params.TopP == nil {
+		params.TopP = defaults.TopP
+	}
+	if params.MaxTokens == nil {
+		params.MaxTokens = defaults.MaxTokens
+	}
+	if params.Stop == nil {
+		params.Stop = defaults.Stop
+	}
+	if params.Seed == nil {
+		params.Seed = defaults.Seed
+	}
+	if params.SystemPrompt == "" {
+		params.SystemPrompt = defaults.SystemPrompt
+	}
+	if params.Tools == nil {
+		params.Tools = defaults.Tools
+	}
+	if len(params.ResponseSchema) == 0 {
+		params.ResponseSchema = defaults.ResponseSchema
+	}
+
+	if result.Retrieval == nil && len(assistant.Collections) > 0 {
+		result.Retrieval = &models.RetrievalParams{Collections: assistant.Collections}
+	}
+
+	return &result
+}
+
+// assistantOf returns the assistant the conversation was started with,
+// nil if there is none or it has been deleted since, the server's defaults apply then.
+func (a *App) assistantOf(ctx context.Context, conversation *models.Conversation) (*models.Assistant, error) {
+	if conversation == nil || conversation.AssistantID == "" {
+		return nil, nil
+	}
+	return a.dbController.GetAssistant(ctx, conversation.Owner, conversation.AssistantID)
+}
+
+func (a *App) CreateAssistantRoute(ctx *fiber.Ctx) error {
+	assistant, err := a.createAssistantController(ctx.Context(), callerOf(ctx), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(assistant, "application/json")
+}
+
+func (a *App) ListAssistantsRoute(ctx *fiber.Ctx) error {
+	assistants, err := a.dbController.ListAssistants(ctx.Context(), callerOf(ctx))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(assistants, "application/json")
+}
+
+func (a *App) GetAssistantRoute(ctx *fiber.Ctx) error {
+	assistant, err := a.getAssistantController(ctx.Context(), callerOf(ctx), ctx.Params("id"))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(assistant, "application/json")
+}
+
+func (a *App) UpdateAssistantRoute(ctx *fiber.Ctx) error {
+	assistant, err := a.updateAssistantController(ctx.Context(), callerOf(ctx), ctx.Params("id"), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(assistant, "application/json")
+}
+
+func (a *App) DeleteAssistantRoute(ctx *fiber.Ctx) error {
+	if err := a.deleteAssistantController(ctx.Context(), callerOf(ctx), ctx.Params("id")); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestWithAssistant(t *testing.T) {
+	temperature := 0.2
+	maxTokens := 100
+	assistant := &models.Assistant{
+		Params: models.OpenAIParams{
+			Model:          "gpt-4o",
+			Temperature:    &temperature,
+			MaxTokens:      &maxTokens,
+			SystemPrompt:   "You answer in French.",
+			Tools:          []string{},
+			ResponseSchema: json.RawMessage(`{"type":"object"}`),
+		},
+		Collections: []string{"manuals", "faq"},
+	}
+
+	ownTemperature := 1.0
+	query := &models.OpenAIRequest{
+		OpenaiQuestion: "Hello",
+		OpenAIParams:   models.OpenAIParams{Temperature: &ownTemperature},
+	}
+
+	result := withAssistant(query, assistant)
+	if result.Model != "gpt-4o" || *result.MaxTokens != 100 || result.SystemPrompt != "You answer in French." {
+		t.Errorf("expected the omitted parameters to be taken from the assistant, got %+v", result.OpenAIParams)
+	}
+	if *result.Temperature != 1.0 {
+		t.Errorf("expected the temperature of the question to take precedence, got %v", *result.Temperature)
+	}
+	if result.Tools == nil || len(result.Tools) != 0 {
+		t.Errorf("expected the tools to stay disabled, got %v", result.Tools)
+	}
+	if string(result.ResponseSchema) != `{"type":"object"}` {
+		t.Errorf("unexpected response schema %s", result.ResponseSchema)
+	}
+	if result.Retrieval == nil || strings.Join(result.Retrieval.Collections, ",") != "manuals,faq" {
+		t.Errorf("expected the collections of the assistant to be searched, got %+v", result.Retrieval)
+	}
+
+	if query.Model != "" || query.Retrieval != nil {
+		t.Errorf("expected the query not to be modified, got %+v", query)
+	}
+
+	own := &models.OpenAIRequest{Retrieval: &models.RetrievalParams{Collection: "notes"}}
+	if result := withAssistant(own, assistant); result.Retrieval.Collection != "notes" || len(result.Retrieval.Collections) != 0 {
+		t.Errorf("expected the retrieval asked for by the client to be kept, got %+v", result.Retrieval)
+	}
+}
+
+func TestValidateCollections(t *testing.T) {
+	if err := validateCollections([]string{"manuals", "faq-2024"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := validateCollections([]string{"manuals", ""}); err == nil {
+		t.Errorf("expected an empty collection name to be rejected")
+	}
+
+	tooMany := make([]string, maxRetrievalCollections+1)
+	for i := range tooMany {
+		tooMany[i] = "c"
+	}
+	if err := validateCollections(tooMany); err == nil {
+		t.Errorf("expected more than %d collections to be rejected", maxRetrievalCollections)
+	}
+}
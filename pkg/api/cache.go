@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/cache"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// Values of the X-Cache header, which tells the client whether the answer came from the cache.
+const (
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+	// The request wasn't looked up, because it isn't deterministic, the client opted out,
+	// or the cache is disabled.
+	cacheBypass = "BYPASS"
+)
+
+const (
+	defaultCacheTTL        = time.Hour
+	defaultCacheMaxEntries = 10000
+	defaultCacheMaxBytes   = 64 << 20
+)
+
+// newCache creates the cache selected with CACHE_BACKEND, either memory, redis or none.
+// The ttl is set with CACHE_TTL, the in-memory cache is bounded with CACHE_MAX_ENTRIES and CACHE_MAX_BYTES.
+// Returns nil if the cache is disabled.
+func newCache(ctx context.Context) (cache.Cache, error) {
+	backend, set := os.LookupEnv("CACHE_BACKEND")
+	if !set || backend == "" {
+		backend = "memory"
+	}
+
+	ttl := defaultCacheTTL
+	if value, set := os.LookupEnv("CACHE_TTL"); set && value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("CACHE_TTL must be a positive duration, for example 30m")
+		}
+		ttl = parsed
+	}
+
+	switch backend {
+	case "none":
+		return nil, nil
+
+	case "memory":
+		maxEntries, maxBytes := defaultCacheMaxEntries, defaultCacheMaxBytes
+		for name, limit := range map[string]*int{"CACHE_MAX_ENTRIES": &maxEntries, "CACHE_MAX_BYTES": &maxBytes} {
+			value, set := os.LookupEnv(name)
+			if !set || value == "" {
+				continue
+			}
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("%s must be a positive integer", name)
+			}
+			*limit = parsed
+		}
+		return cache.NewLRU(maxEntries, maxBytes, ttl), nil
+
+	case "redis":
+		return cache.NewRedis(ctx, ttl)
+	}
+
+	return nil, fmt.Errorf("unknown cache backend %q", backend)
+}
+
+// useCache reports whether the answer to the request may be looked up in the cache.
+func (a *App) useCache(turn *chatTurn, request *models.OpenAIChatRequest) bool {
+	return a.cache != nil && !turn.query.NoCache && cache.Cacheable(request)
+}
+
+// chat sends the request to the llm provider unless the answer is already cached.
+// The personal data is redacted from the request if the deployment asks for it, the answer is restored.
+// A cached answer hasn't cost anything, so it's returned with a zero usage.
+// The cache failing is logged, the request is sent to the provider as if it was a miss.
+func (a *App) chat(ctx context.Context, turn *chatTurn, request *models.OpenAIChatRequest, useCache bool) (*models.OpenAIResp, bool, error) {
+	// Both the cache key and the cached answer are computed from the redacted request,
+	// the placeholders are numbered in the order the values appear, so they match for identical prompts.
+	sent := redactRequest(turn.redaction, request)
+
+	result, hit, err := a.cachedChat(ctx, sent, useCache)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for i := range result.Choices {
+		restoreMessage(turn.redaction, &result.Choices[i].Message)
+	}
+
+	return result, hit, nil
+}
+
+func (a *App) cachedChat(ctx context.Context, request *models.OpenAIChatRequest, useCache bool) (*models.OpenAIResp, bool, error) {
+	if !useCache {
+		result, err := a.llmProvider.Chat(ctx, request)
+		return result, false, err
+	}
+
+	key, err := cache.Key(request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cached, hit, err := a.cache.Get(ctx, key)
+	if err != nil {
+		log.Logger.Error("failed to look up cached answer: %v", err)
+	}
+	if hit {
+		var result models.OpenAIResp
+		if err := json.Unmarshal(cached, &result); err == nil {
+			result.Usage = &models.OpenAIUsage{}
+			return &result, true, nil
+		}
+		log.Logger.Error("failed to unmarshal cached answer: %v", err)
+	}
+
+	result, err := a.llmProvider.Chat(ctx, request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Answers without choices are reported as errors, there is no point in caching them.
+	if len(result.Choices) > 0 {
+		data, err := json.Marshal(result)
+		if err == nil {
+			err = a.cache.Set(ctx, key, data)
+		}
+		if err != nil {
+			log.Logger.Error("failed to cache answer: %v", err)
+		}
+	}
+
+	return result, false, nil
+}
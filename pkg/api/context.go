@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/redact"
+	"github.com/isnastish/openai/pkg/tokenizer"
+)
+
+// Strategies of fitting a long conversation into the context window of the model.
+const (
+	// The oldest turns are left out of the prompt.
+	contextDropOldest = "drop_oldest"
+	// The oldest turns are replaced with their summary written by the model.
+	contextSummarize = "summarize"
+)
+
+const (
+	// Room left for the answer when the client doesn't set max_tokens.
+	defaultReservedOutputTokens = 4096
+	// Upper bound for the summary of the turns that didn't fit.
+	summaryMaxTokens = 512
+	// The tokens taken by the text the summary is wrapped into.
+	summaryOverheadTokens = 16
+)
+
+const summarySystemPrompt = "You summarise conversations. Write a concise summary of the conversation " +
+	"below, keeping the facts, names, numbers and decisions the rest of the conversation may rely on."
+
+func validContextStrategy(strategy string) bool {
+	return strategy == contextDropOldest || strategy == contextSummarize
+}
+
+// addUsage adds the usage of a single request to the usage of the whole turn.
+func addUsage(total *models.OpenAIUsage, usage *models.OpenAIUsage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
+
+// toolTokens estimates the tokens taken by the tool definitions, they are sent as json.
+func toolTokens(counter *tokenizer.Tokenizer, tools []models.OpenAITool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	definitions, _ := json.Marshal(tools)
+	return counter.Count(string(definitions))
+}
+
+// estimateUsage counts the tokens of the request and the answer,
+// for the providers which don't report the usage themselves.
+func estimateUsage(request *models.OpenAIChatRequest, answer *models.OpenAIMessage) *models.OpenAIUsage {
+	counter, err := tokenizer.ForModel(request.Model)
+	if err != nil {
+		log.Logger.Error("failed to estimate usage: %v", err)
+		return nil
+	}
+
+	promptTokens := counter.CountMessages(request.Messages) + toolTokens(counter, request.Tools)
+	completionTokens := counter.CountMessage(answer) - counter.CountMessage(&models.OpenAIMessage{})
+
+	return &models.OpenAIUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// turnsToDrop returns how many of the oldest messages of the history have to be left out
+// so the rest takes at most the available tokens.
+// Only whole turns are dropped, a turn starts with a question of the user,
+// and a tool result can't be sent without the call it answers.
+func turnsToDrop(counter *tokenizer.Tokenizer, history []models.OpenAIMessage, available int) int {
+	tokens := 0
+	for i := range history {
+		tokens += counter.CountMessage(&history[i])
+	}
+
+	cut := 0
+	for tokens > available && cut < len(history) {
+		tokens -= counter.CountMessage(&history[cut])
+		cut++
+		for cut < len(history) && history[cut].Role != "user" {
+			tokens -= counter.CountMessage(&history[cut])
+			cut++
+		}
+	}
+
+	return cut
+}
+
+// fitContext makes the request fit into the context window of the model together with the answer,
+// by trimming the history of the conversation with the strategy of the turn.
+// The request is expected to consist of the system prompt, the history and the question.
+// Returns the usage of the requests made on the way, if any.
+func (a *App) fitContext(ctx context.Context, turn *chatTurn, request *models.OpenAIChatRequest) (*models.OpenAIUsage, error) {
+	limits, supported := a.llmProvider.Catalog().Limits(request.Model)
+	if !supported || limits.ContextWindow == 0 {
+		return nil, nil
+	}
+
+	counter, err := tokenizer.ForModel(request.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := min(limits.MaxOutputTokens, defaultReservedOutputTokens)
+	if request.MaxTokens != nil {
+		reserved = *request.MaxTokens
+	}
+	budget := limits.ContextWindow - reserved - toolTokens(counter, request.Tools)
+
+	promptTokens := counter.CountMessages(request.Messages)
+	if promptTokens <= budget {
+		return nil, nil
+	}
+
+	last := len(request.Messages) - 1
+	system, history, question := request.Messages[0], request.Messages[1:last], request.Messages[last]
+
+	fixedTokens := counter.CountMessages([]models.OpenAIMessage{system, question})
+	if fixedTokens > budget {
+		return nil, fmt.Errorf("%w: the question takes %d tokens, model %s accepts at most %d with %d tokens reserved for the answer",
+			errPromptTooLong, fixedTokens, request.Model, budget, reserved)
+	}
+
+	available := budget - fixedTokens
+	if turn.contextStrategy == contextSummarize {
+		available -= summaryMaxTokens + summaryOverheadTokens
+	}
+
+	cut := turnsToDrop(counter, history, available)
+	messages := []models.OpenAIMessage{system}
+
+	var usage *models.OpenAIUsage
+	if turn.contextStrategy == contextSummarize && cut > 0 {
+		var summary string
+		summary, usage, err = a.summarize(ctx, turn.redaction, counter, request.Model, history[:cut], budget)
+		if err != nil {
+			// Dropping the turns is still better than failing the request.
+			log.Logger.Warn("failed to summarise %d messages, dropping them instead: %v", cut, err)
+		} else {
+			messages = append(messages, models.OpenAIMessage{
+				Role:    "system",
+				Content: "Summary of the earlier part of the conversation:\n" + summary,
+			})
+		}
+	}
+
+	messages = append(append(messages, history[cut:]...), question)
+	request.Messages = messages
+
+	log.Logger.Info("trimmed %d of %d messages of the history with %s strategy, the prompt of %d tokens didn't fit into %d",
+		cut, len(history), turn.contextStrategy, promptTokens, budget)
+
+	return usage, nil
+}
+
+// summarize asks the model for a summary of the messages.
+// The oldest messages are left out if even the transcript doesn't fit into the budget.
+// The transcript is redacted like the rest of the turn, the summary is returned restored.
+func (a *App) summarize(ctx context.Context, session *redact.Session, counter *tokenizer.Tokenizer, model string,
+	messages []models.OpenAIMessage, budget int) (string, *models.OpenAIUsage, error) {
+	lines := make([]string, 0, len(messages))
+	for _, message := range messages {
+		switch {
+		case len(message.ToolCalls) > 0:
+			for _, call := range message.ToolCalls {
+				lines = append(lines, fmt.Sprintf("assistant called %s with %s", call.Function.Name, call.Function.Arguments))
+			}
+		case message.Role == "tool":
+			lines = append(lines, "tool result: "+message.Content)
+		default:
+			lines = append(lines, message.Role+": "+message.Content)
+		}
+	}
+
+	available := budget - counter.Count(summarySystemPrompt) - summaryOverheadTokens
+	tokens := counter.Count(strings.Join(lines, "\n"))
+	for tokens > available && len(lines) > 1 {
+		tokens -= counter.Count(lines[0]) + 1
+		lines = lines[1:]
+	}
+
+	maxTokens := summaryMaxTokens
+	request := a.llmProvider.Catalog().NewChatRequest([]models.OpenAIMessage{
+		{Role: "user", Content: strings.Join(lines, "\n")},
+	}, &models.OpenAIParams{
+		Model:        model,
+		MaxTokens:    &maxTokens,
+		SystemPrompt: summarySystemPrompt,
+	})
+
+	result, err := a.llmProvider.Chat(ctx, redactRequest(session, request))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+		return "", nil, fmt.Errorf("%s: the summary is empty", a.llmProvider.Name())
+	}
+
+	usage := result.Usage
+	if usage == nil {
+		usage = estimateUsage(request, &result.Choices[0].Message)
+	}
+
+	return session.Restore(result.Choices[0].Message.Content), usage, nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/tokenizer"
+)
+
+func TestTurnsToDrop(t *testing.T) {
+	counter, err := tokenizer.Get(tokenizer.Cl100kBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("word ", 100)
+	history := []models.OpenAIMessage{
+		{Role: "user", Content: long},
+		{Role: "assistant", ToolCalls: []models.OpenAIToolCall{{ID: "call_1", Function: models.OpenAIFunctionCall{Name: "calculator", Arguments: "{}"}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "short"},
+		{Role: "assistant", Content: "short"},
+	}
+
+	tests := []struct {
+		available int
+		expected  int
+	}{
+		// Everything fits.
+		{available: 10000, expected: 0},
+		// The first turn goes as a whole, together with its tool call and result.
+		{available: 100, expected: 4},
+		// Nothing fits.
+		{available: 0, expected: len(history)},
+	}
+	for _, test := range tests {
+		if cut := turnsToDrop(counter, history, test.available); cut != test.expected {
+			t.Errorf("%d tokens available: expected %d messages dropped, got %d", test.available, test.expected, cut)
+		}
+	}
+}
@@ -5,10 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/isnastish/openai/pkg/api/models"
 	"github.com/isnastish/openai/pkg/auth"
 	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/redact"
+	"github.com/isnastish/openai/pkg/schema"
+	"github.com/isnastish/openai/pkg/tools"
 	"github.com/isnastish/openai/pkg/validator"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -20,7 +27,9 @@ import (
 // So we can easily switch between those things.
 // For example replace fiber with Echo etc.
 
-func unmarshalRequestData[T models.UserData | models.OpenAIRequest](requestBody []byte) (*T, error) {
+func unmarshalRequestData[T models.UserData | models.OpenAIRequest | models.ConversationRequest | models.EmbeddingRequest | models.ChatSocketRequest |
+	models.PromptTemplateRequest | models.PromptTemplateVersionRequest | models.PromptTemplateRunRequest | models.AssistantRequest |
+	models.PasswordResetRequest | models.ChangePasswordRequest](requestBody []byte) (*T, error) {
 	var data T
 	if err := json.Unmarshal(requestBody, &data); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request data: %v", err)
@@ -28,18 +37,414 @@ func unmarshalRequestData[T models.UserData | models.OpenAIRequest](requestBody
 	return &data, nil
 }
 
-func (a *App) openaiController(ctx context.Context, requestBody []byte) (*models.OpenAIResp, error) {
+// chatTurn is a question of the user validated and ready to be sent to the model.
+type chatTurn struct {
+	owner   string
+	query   *models.OpenAIRequest
+	history []models.OpenAIMessage
+	tools   []models.OpenAITool
+	// Set when the client asked for a structured answer.
+	responseSchema *schema.Schema
+	// Chunks of the user's documents retrieved for the question, the model is asked to cite them.
+	sources []models.VectorMatch
+	// How the history is trimmed if it doesn't fit into the context window.
+	contextStrategy string
+	// Replaces the personal data with placeholders before it's sent to the provider, nil if redaction is disabled.
+	redaction *redact.Session
+	// Messages of the conversation the turn replaces once it's answered, set when an answer is regenerated.
+	replaces []string
+}
+
+// chatAnswer is the final answer of the model to a question.
+type chatAnswer struct {
+	*models.OpenAIResp
+	// The answer validated against the response schema, nil if the client didn't supply one.
+	Object json.RawMessage
+	// The sources the answer refers to, nil unless the client asked for retrieval.
+	Citations []models.Citation
+	// Whether the answer came from the cache, reported to the client in the X-Cache header.
+	CacheStatus string
+}
+
+// prepareTurn validates the parameters and loads the previous turns of the conversation.
+// The parameters the client omitted are taken from the assistant the conversation was started with.
+// The streaming route calls it before the stream is opened, so that the errors can still be
+// reported with a proper status code.
+func (a *App) prepareTurn(ctx context.Context, owner string, query *models.OpenAIRequest, streaming bool) (*chatTurn, error) {
+	var conversation *models.Conversation
+	if query.ConversationID != "" {
+		var err error
+		conversation, err = a.getConversationController(ctx, owner, query.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+
+		assistant, err := a.assistantOf(ctx, conversation)
+		if err != nil {
+			return nil, err
+		}
+		if assistant != nil {
+			query = withAssistant(query, assistant)
+		}
+	}
+
+	if err := a.llmProvider.Catalog().ValidateParams(&query.OpenAIParams); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	toolDefinitions, err := a.toolDefinitions(&query.OpenAIParams)
+	if err != nil {
+		return nil, err
+	}
+
+	contextStrategy := query.ContextStrategy
+	if contextStrategy == "" {
+		contextStrategy = a.contextStrategy
+	}
+	if !validContextStrategy(contextStrategy) {
+		return nil, fmt.Errorf("%w: context_strategy must be either %s or %s", errBadRequest, contextDropOldest, contextSummarize)
+	}
+
+	// A partial json document is of no use, and an invalid one cannot be taken back once streamed.
+	// Checked once the assistant is applied, the schema may come from it.
+	if streaming && len(query.ResponseSchema) > 0 {
+		return nil, fmt.Errorf("%w: response_schema is not supported when streaming", errBadRequest)
+	}
+
+	var responseSchema *schema.Schema
+	if len(query.ResponseSchema) > 0 {
+		responseSchema, err = schema.Compile(query.ResponseSchema)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid response_schema, %v", errBadRequest, err)
+		}
+	}
+
+	var redaction *redact.Session
+	if a.redactor != nil {
+		redaction = a.redactor.NewSession()
+	}
+
+	// The moderation endpoint is run by OpenAI too, so it only gets to see the redacted prompt.
+	if err := a.moderate(ctx, owner, query.ConversationID, models.ModerationInput, redaction.Redact(moderatedPrompt(query))); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkQuota(ctx, owner); err != nil {
+		return nil, err
+	}
+
+	history := conversationHistory(conversation)
+
+	var sources []models.VectorMatch
+	if query.Retrieval != nil {
+		sources, err = a.retrieve(ctx, owner, query.OpenaiQuestion, query.Retrieval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &chatTurn{
+		owner:           owner,
+		query:           query,
+		history:         history,
+		tools:           toolDefinitions,
+		responseSchema:  responseSchema,
+		sources:         sources,
+		contextStrategy: contextStrategy,
+		redaction:       redaction,
+	}, nil
+}
+
+// newChatRequest builds the first request of the turn, the returned messages are the ones to be stored
+// in the conversation, they grow with every round of tool calls.
+func (a *App) newChatRequest(turn *chatTurn) (*models.OpenAIChatRequest, []models.OpenAIMessage) {
+	question := models.OpenAIMessage{
+		Role:    "user",
+		Content: turn.query.OpenaiQuestion,
+	}
+
+	// The sources are only sent along with the question, the conversation keeps the question itself.
+	sent := question
+	if len(turn.sources) > 0 {
+		sent.Content = groundedQuestion(question.Content, turn.sources)
+	}
+
+	messages := append(append([]models.OpenAIMessage(nil), turn.history...), sent)
+
+	request := a.llmProvider.Catalog().NewChatRequest(messages, &turn.query.OpenAIParams)
+	request.Tools = turn.tools
+
+	return request, []models.OpenAIMessage{question}
+}
+
+// complete sends the request and runs the tools the model asks for until it answers.
+// The tool calls, their results and the answer are appended to messages.
+// The usage of every round is added to usage as soon as it's answered, so the rounds
+// answered before a failure are accounted for too, on behalf of the model which answered.
+// Reports whether every round was answered from the cache.
+func (a *App) complete(ctx context.Context, turn *chatTurn, request *models.OpenAIChatRequest, messages []models.OpenAIMessage,
+	useCache bool, usage *turnUsage) (*models.OpenAIResp, []models.OpenAIMessage, bool, error) {
+	cached := true
+
+	for round := 0; ; round++ {
+		result, hit, err := a.chat(ctx, turn, request, useCache)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		cached = cached && hit
+
+		var answer models.OpenAIMessage
+		if len(result.Choices) > 0 {
+			answer = result.Choices[0].Message
+		}
+		answer.Role = "assistant"
+
+		if result.Usage == nil {
+			result.Usage = estimateUsage(request, &answer)
+		}
+		usage.add(result.Model, result.Usage)
+
+		if len(result.Choices) == 0 {
+			return nil, nil, false, fmt.Errorf("%s: response doesn't contain any choices", a.llmProvider.Name())
+		}
+
+		messages = append(messages, answer)
+
+		if len(answer.ToolCalls) == 0 {
+			return result, messages, cached, nil
+		}
+
+		if round == maxToolRounds {
+			return nil, nil, false, fmt.Errorf("%w: the model didn't answer after %d rounds of tool calls", errBadGateway, maxToolRounds)
+		}
+
+		results, err := a.callTools(ctx, answer.ToolCalls, nil)
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		messages = append(messages, results...)
+		request.Messages = append(append(request.Messages, answer), results...)
+	}
+}
+
+// extractJSON strips the markdown code block models like to wrap json documents in.
+func extractJSON(content string) []byte {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") && strings.HasSuffix(content, "```") {
+		content = strings.TrimSuffix(content, "```")
+		// Drops the opening fence together with the language tag.
+		if _, body, found := strings.Cut(content, "\n"); found {
+			content = body
+		}
+	}
+	return []byte(strings.TrimSpace(content))
+}
+
+func (a *App) openaiController(ctx context.Context, owner string, requestBody []byte) (*chatAnswer, error) {
 	query, err := unmarshalRequestData[models.OpenAIRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	return a.answerQuestion(ctx, owner, query)
+}
+
+// answerQuestion answers the question in full, the answer is stored in the conversation if the query refers to one.
+// The tokens used on the way are recorded whether or not the question is answered.
+func (a *App) answerQuestion(ctx context.Context, owner string, query *models.OpenAIRequest) (answer *chatAnswer, err error) {
+	turn, err := a.prepareTurn(ctx, owner, query, false)
+	if err != nil {
+		return nil, err
+	}
+
+	request, messages := a.newChatRequest(turn)
+	ctx = tools.WithCaller(ctx, owner)
+
+	usage := turnUsage{model: request.Model}
+	defer func() {
+		// A failed turn which hasn't used anything isn't worth a record, a cached answer is.
+		if err == nil || usage.OpenAIUsage != (models.OpenAIUsage{}) {
+			a.recordUsage(ctx, owner, models.UsageChat, usage.model, &usage.OpenAIUsage)
+		}
+	}()
+
+	summaryUsage, err := a.fitContext(ctx, turn, request)
 	if err != nil {
 		return nil, err
 	}
+	usage.add("", summaryUsage)
+
+	useCache := a.useCache(turn, request)
 
-	result, err := a.openaiClient.AskOpenAI(ctx, query.OpenaiQuestion)
+	result, messages, cached, err := a.complete(ctx, turn, request, messages, useCache, &usage)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	answer = &chatAnswer{OpenAIResp: result}
+
+	if turn.responseSchema != nil {
+		object := extractJSON(result.Choices[0].Message.Content)
+
+		// The model gets a single chance to correct itself.
+		if err := turn.responseSchema.Validate(object); err != nil {
+			log.Logger.Warn("answer of %s is invalid, re-prompting: %v", request.Model, err)
+
+			invalid := messages[len(messages)-1]
+			request.Messages = append(request.Messages, invalid, models.OpenAIMessage{
+				Role: "user",
+				Content: fmt.Sprintf("Your response is invalid: %v. "+
+					"Respond again with only a JSON document that conforms to the schema.", err),
+			})
+
+			// Neither the invalid answer nor the correction are worth keeping in the conversation.
+			var correctionCached bool
+			result, messages, correctionCached, err = a.complete(ctx, turn, request, messages[:len(messages)-1], useCache, &usage)
+			if err != nil {
+				return nil, err
+			}
+			cached = cached && correctionCached
+
+			object = extractJSON(result.Choices[0].Message.Content)
+			if err := turn.responseSchema.Validate(object); err != nil {
+				return nil, fmt.Errorf("%w: the answer of the model is invalid: %v", errBadGateway, err)
+			}
+
+			answer.OpenAIResp = result
+		}
+
+		answer.Object = object
+	}
+
+	if query.Retrieval != nil {
+		answer.Citations = citations(answer.Choices[0].Message.Content, turn.sources)
+	}
+
+	answer.Usage = &usage.OpenAIUsage
+	answer.CacheStatus = cacheBypass
+	if useCache {
+		answer.CacheStatus = cacheMiss
+		if cached {
+			answer.CacheStatus = cacheHit
+		}
+	}
+
+	// The answer is paid for by now, but neither returned nor stored if it's rejected.
+	if err := a.moderate(ctx, owner, query.ConversationID, models.ModerationOutput,
+		turn.redaction.Redact(answer.Choices[0].Message.Content)); err != nil {
+		return nil, err
+	}
+
+	if err := a.storeTurn(ctx, turn, messages, usage.model); err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+// openaiStreamController forwards every content delta produced by the model to onDelta,
+// and every tool call made on the way together with its result to onToolCall.
+// Returning an error from a callback, or cancelling ctx, aborts the upstream request.
+// The turn is expected to be prepared with prepareTurn.
+// Returns the usage of all the requests made to answer the question, which is recorded on every exit,
+// so a client that cancels or disconnects midway still pays for what has been streamed.
+func (a *App) openaiStreamController(ctx context.Context, turn *chatTurn, onDelta func(delta string) error,
+	onToolCall func(call models.OpenAIToolCall, result string) error) (_ *models.OpenAIUsage, err error) {
+	request, messages := a.newChatRequest(turn)
+	ctx = tools.WithCaller(ctx, turn.owner)
+
+	usage := turnUsage{model: request.Model}
+	defer func() {
+		if err == nil || usage.OpenAIUsage != (models.OpenAIUsage{}) {
+			a.recordUsage(ctx, turn.owner, models.UsageChat, usage.model, &usage.OpenAIUsage)
+		}
+	}()
+
+	summaryUsage, err := a.fitContext(ctx, turn, request)
+	if err != nil {
+		return nil, err
+	}
+	usage.add("", summaryUsage)
+
+	for round := 0; ; round++ {
+		var content strings.Builder
+		var toolCalls []models.OpenAIToolCall
+		var roundUsage *models.OpenAIUsage
+		var roundModel string
+		restorer := turn.redaction.NewStreamRestorer()
+
+		forward := func(delta string) error {
+			if delta == "" {
+				return nil
+			}
+			content.WriteString(delta)
+			return onDelta(delta)
+		}
+
+		streamErr := a.llmProvider.ChatStream(ctx, redactRequest(turn.redaction, request), func(chunk *models.OpenAIStreamChunk) error {
+			if chunk.Usage != nil {
+				roundUsage = chunk.Usage
+			}
+			if chunk.Model != "" {
+				roundModel = chunk.Model
+			}
+			if len(chunk.Choices) == 0 {
+				return nil
+			}
+			delta := chunk.Choices[0].Delta
+			toolCalls = mergeToolCallDeltas(toolCalls, delta.ToolCalls)
+			return forward(restorer.Next(delta.Content))
+		})
+		if streamErr == nil {
+			streamErr = forward(restorer.Flush())
+		}
+
+		answer := models.OpenAIMessage{
+			Role:      "assistant",
+			Content:   content.String(),
+			ToolCalls: toolCalls,
+		}
+		restoreMessage(turn.redaction, &answer)
+
+		// The provider reports the usage at the end of the stream, an aborted one is estimated
+		// from what has been received. A stream which failed before anything was received is free.
+		received := answer.Content != "" || len(answer.ToolCalls) > 0
+		if roundUsage == nil && (streamErr == nil || received) {
+			roundUsage = estimateUsage(request, &answer)
+		}
+		usage.add(roundModel, roundUsage)
+
+		if streamErr != nil {
+			return nil, streamErr
+		}
+		messages = append(messages, answer)
+
+		if len(toolCalls) == 0 {
+			// The answer has already been streamed, rejecting it keeps it out of the conversation
+			// and tells the client to discard it.
+			if err := a.moderate(ctx, turn.owner, turn.query.ConversationID, models.ModerationOutput,
+				turn.redaction.Redact(answer.Content)); err != nil {
+				return nil, err
+			}
+			if err := a.storeTurn(ctx, turn, messages, usage.model); err != nil {
+				return nil, err
+			}
+			return &usage.OpenAIUsage, nil
+		}
+
+		if round == maxToolRounds {
+			return nil, fmt.Errorf("%w: the model didn't answer after %d rounds of tool calls", errBadGateway, maxToolRounds)
+		}
+
+		results, err := a.callTools(ctx, toolCalls, onToolCall)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, results...)
+		request.Messages = append(append(request.Messages, answer), results...)
+	}
 }
 
 func (a *App) loginController(ctx context.Context, requestBody []byte) (*models.Tokens, *auth.Cookie, error) {
@@ -70,14 +475,37 @@ func (a *App) loginController(ctx context.Context, requestBody []byte) (*models.
 		}
 	}
 
-	tokens, err := a.auth.GetTokens(userData.Email)
+	if a.accountEmails.requireVerification && !existingUser.EmailVerified {
+		return nil, nil, fmt.Errorf("%w: email address is not verified", errForbidden)
+	}
+
+	return a.issueTokens(ctx, existingUser, "")
+}
+
+// issueTokens signs a new pair of tokens for the user and stores the refresh token as a member of the family,
+// a login starts a new family.
+func (a *App) issueTokens(ctx context.Context, user *models.UserData, family string) (*models.Tokens, *auth.Cookie, error) {
+	tokens, claims, err := a.auth.IssueTokens(user.ID, user.Email, user.EmailVerified)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cookie := a.auth.GetCookie(tokens.RefreshToken)
+	if family == "" {
+		family = uuid.NewString()
+	}
 
-	return tokens, cookie, nil
+	err = a.dbController.CreateRefreshToken(ctx, &models.RefreshToken{
+		ID:        claims.ID,
+		Family:    family,
+		Owner:     user.ID,
+		CreatedAt: claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, a.auth.GetCookie(tokens.RefreshToken), nil
 }
 
 func (a *App) signupController(ctx context.Context, requestBody []byte, ipAddr string) error {
@@ -90,9 +518,6 @@ func (a *App) signupController(ctx context.Context, requestBody []byte, ipAddr s
 		return err
 	}
 
-	// TODO: Email validation.
-	// a.awsEmailService.SendEmail()
-
 	// Check if the user with given email address already exists.
 	existingUser, err := a.dbController.GetUserByEmail(ctx, userData.Email)
 	if err != nil {
@@ -116,15 +541,89 @@ func (a *App) signupController(ctx context.Context, requestBody []byte, ipAddr s
 		return fmt.Errorf("failed to encrypt password, %v", err)
 	}
 	userData.Password = string(passwordHash)
+	userData.ID = uuid.NewString()
+	// The user has to open the link sent to the address before it counts as verified.
+	userData.EmailVerified = false
+	sentAt := time.Now().UTC()
+	userData.VerificationSentAt = &sentAt
 
 	if err := a.dbController.AddUser(ctx, userData, geolocation); err != nil {
 		return fmt.Errorf("failed to add user, %v", userData.Email)
 	}
 	log.Logger.Info("Successfully added a new user")
 
+	// The account exists either way, the user can ask for another email.
+	if err := a.sendVerificationEmail(ctx, userData); err != nil {
+		log.Logger.Error("Failed to send the verification email to %s: %v", userData.Email, err)
+	}
+
 	return nil
 }
 
-func (a *App) refreshTokenController() (*models.Tokens, *auth.Cookie, error) {
-	return nil, nil, nil
+// refreshTokenController exchanges the refresh token for a new pair of tokens, the refresh token can only be used once.
+// A token presented again after it has been rotated is most likely stolen, either the thief or the user is
+// holding a copy, so the whole family is revoked and the user has to log in again.
+func (a *App) refreshTokenController(ctx context.Context, refreshToken string) (*models.Tokens, *auth.Cookie, error) {
+	claims, err := a.auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errUnauthorized, err)
+	}
+
+	stored, err := a.dbController.GetRefreshToken(ctx, claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored == nil || stored.Revoked {
+		return nil, nil, fmt.Errorf("%w: refresh token has been revoked", errUnauthorized)
+	}
+
+	rotated, err := a.dbController.RotateRefreshToken(ctx, stored.ID, time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		log.Logger.Warn("Refresh token %s of %s was used after it had been rotated, revoking its family", stored.ID, stored.Owner)
+		if err := a.dbController.RevokeRefreshTokenFamily(ctx, stored.Family); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%w: refresh token has already been used", errUnauthorized)
+	}
+
+	// The user is resolved by ID, so the session survives a change of the email address.
+	user, err := a.dbController.GetUserByID(ctx, stored.Owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("%w: unknown user", errUnauthorized)
+	}
+	if a.accountEmails.requireVerification && !user.EmailVerified {
+		return nil, nil, fmt.Errorf("%w: email address is not verified", errForbidden)
+	}
+
+	return a.issueTokens(ctx, user, stored.Family)
+}
+
+// logoutController revokes the family of the refresh token, so neither the token
+// nor any other one issued since the login can be used anymore.
+// An invalid or expired token has nothing to revoke.
+func (a *App) logoutController(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+
+	claims, err := a.auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil
+	}
+
+	stored, err := a.dbController.GetRefreshToken(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return nil
+	}
+
+	return a.dbController.RevokeRefreshTokenFamily(ctx, stored.Family)
 }
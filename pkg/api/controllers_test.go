@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestExtractJSON(t *testing.T) {
+	testData := []struct {
+		content  string
+		expected string
+	}{
+		{`{"name": "Ada"}`, `{"name": "Ada"}`},
+		{"  {\"name\": \"Ada\"}\n", `{"name": "Ada"}`},
+		{"```json\n{\"name\": \"Ada\"}\n```", `{"name": "Ada"}`},
+		{"```\n[1, 2]\n```", `[1, 2]`},
+		{"Here you go: {}", "Here you go: {}"},
+	}
+
+	for _, data := range testData {
+		if result := string(extractJSON(data.content)); result != data.expected {
+			t.Errorf("%q: expected %q, got %q", data.content, data.expected, result)
+		}
+	}
+}
+
+// login logs the user in with the password and returns the tokens.
+func (a *testApp) login(t *testing.T, email string, password string) *models.Tokens {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	tokens, _, err := a.loginController(context.Background(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// refreshTokenID returns the jti of the refresh token.
+func (a *testApp) refreshTokenID(t *testing.T, tokens *models.Tokens) string {
+	t.Helper()
+
+	claims, err := a.auth.ParseRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims.ID
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	app := newTestApp(t)
+	app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	ctx := context.Background()
+
+	first := app.login(t, "ada@example.com", "Secret-password-1")
+	second, _, err := app.refreshTokenController(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _ := app.db.GetRefreshToken(ctx, app.refreshTokenID(t, first))
+	issued, _ := app.db.GetRefreshToken(ctx, app.refreshTokenID(t, second))
+	if rotated.RotatedAt == nil || rotated.Revoked {
+		t.Errorf("expected the used token to be rotated, got %+v", rotated)
+	}
+	if issued == nil || issued.Family != rotated.Family || issued.RotatedAt != nil {
+		t.Errorf("expected the new token to join the family of the used one, got %+v", issued)
+	}
+
+	third, _, err := app.refreshTokenController(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected the new token to be exchanged, got %v", err)
+	}
+	if app.refreshTokenID(t, third) == app.refreshTokenID(t, second) {
+		t.Errorf("expected a new jti on every refresh")
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	app := newTestApp(t)
+	app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	ctx := context.Background()
+
+	first := app.login(t, "ada@example.com", "Secret-password-1")
+	second, _, err := app.refreshTokenController(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another session of the same user, which mustn't be affected.
+	other := app.login(t, "ada@example.com", "Secret-password-1")
+
+	if _, _, err := app.refreshTokenController(ctx, first.RefreshToken); !errors.Is(err, errUnauthorized) {
+		t.Fatalf("expected the replayed token to be rejected, got %v", err)
+	}
+
+	if issued, _ := app.db.GetRefreshToken(ctx, app.refreshTokenID(t, second)); !issued.Revoked {
+		t.Errorf("expected the family to be revoked on reuse")
+	}
+	if _, _, err := app.refreshTokenController(ctx, second.RefreshToken); !errors.Is(err, errUnauthorized) {
+		t.Errorf("expected the token issued to the thief or the user to be rejected, got %v", err)
+	}
+	if _, _, err := app.refreshTokenController(ctx, other.RefreshToken); err != nil {
+		t.Errorf("expected the other session to survive, got %v", err)
+	}
+}
+
+func TestLogoutRevokesFamily(t *testing.T) {
+	app := newTestApp(t)
+	app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	ctx := context.Background()
+
+	first := app.login(t, "ada@example.com", "Secret-password-1")
+	second, _, err := app.refreshTokenController(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.logoutController(ctx, second.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	for _, tokens := range []*models.Tokens{first, second} {
+		if stored, _ := app.db.GetRefreshToken(ctx, app.refreshTokenID(t, tokens)); !stored.Revoked {
+			t.Errorf("expected %s to be revoked on logout", stored.ID)
+		}
+	}
+	if _, _, err := app.refreshTokenController(ctx, second.RefreshToken); !errors.Is(err, errUnauthorized) {
+		t.Errorf("expected the token to be rejected after logout, got %v", err)
+	}
+
+	// There is nothing to revoke for a missing or an invalid token.
+	for _, refreshToken := range []string{"", "not-a-token", first.AccessToken} {
+		if err := app.logoutController(ctx, refreshToken); err != nil {
+			t.Errorf("expected logout with %q to succeed, got %v", refreshToken, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/auth"
+)
+
+const defaultConversationTitle = "New conversation"
+
+// callerOf returns the ID of the user who made a request to a protected route, the owner of everything they create.
+func callerOf(ctx *fiber.Ctx) string {
+	return auth.GetClaims(ctx).Subject
+}
+
+func (a *App) createConversationController(ctx context.Context, owner string, requestBody []byte) (*models.Conversation, error) {
+	request, err := unmarshalRequestData[models.ConversationRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		title = defaultConversationTitle
+	}
+
+	if request.AssistantID != "" {
+		if _, err := a.getAssistantController(ctx, owner, request.AssistantID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	conversation := &models.Conversation{
+		ID:          uuid.NewString(),
+		Owner:       owner,
+		Title:       title,
+		AssistantID: request.AssistantID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := a.dbController.CreateConversation(ctx, conversation); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// getConversationController returns the conversation together with all its messages.
+func (a *App) getConversationController(ctx context.Context, owner string, id string) (*models.Conversation, error) {
+	conversation, err := a.dbController.GetConversation(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if conversation == nil {
+		return nil, fmt.Errorf("%w: conversation %s", errNotFound, id)
+	}
+
+	messages, err := a.dbController.GetMessages(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Messages = messages
+
+	return conversation, nil
+}
+
+func (a *App) deleteConversationController(ctx context.Context, owner string, id string) error {
+	conversation, err := a.dbController.GetConversation(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if conversation == nil {
+		return fmt.Errorf("%w: conversation %s", errNotFound, id)
+	}
+
+	return a.dbController.DeleteConversation(ctx, owner, id)
+}
+
+// conversationHistory returns the previous turns of a conversation in the form expected by OpenAI.
+// A nil conversation means that the question is not a part of any conversation.
+func conversationHistory(conversation *models.Conversation) []models.OpenAIMessage {
+	if conversation == nil {
+		return nil
+	}
+
+	history := make([]models.OpenAIMessage, 0, len(conversation.Messages))
+	for _, message := range conversation.Messages {
+		history = append(history, models.OpenAIMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCalls:  message.ToolCalls,
+			ToolCallID: message.ToolCallID,
+		})
+	}
+
+	return history
+}
+
+// storeTurn appends the messages of a turn to the conversation: the user's question,
+// the tool calls made by the model together with their results, and the final answer.
+// The messages are stored only once the answer has been received in full,
+// so a failed request doesn't leave a question without an answer.
+// A regenerated turn replaces the previous one only at this point, for the same reason.
+func (a *App) storeTurn(ctx context.Context, turn *chatTurn, messages []models.OpenAIMessage, model string) error {
+	conversationID := turn.query.ConversationID
+	if conversationID == "" {
+		return nil
+	}
+
+	if len(turn.replaces) > 0 {
+		if err := a.dbController.DeleteMessages(ctx, conversationID, turn.replaces); err != nil {
+			return fmt.Errorf("failed to delete replaced messages, %v", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	for i, message := range messages {
+		stored := &models.ConversationMessage{
+			ID:             uuid.NewString(),
+			ConversationID: conversationID,
+			Role:           message.Role,
+			Content:        message.Content,
+			ToolCalls:      message.ToolCalls,
+			ToolCallID:     message.ToolCallID,
+			// Keeps the order stable even if the clock doesn't advance.
+			CreatedAt: now.Add(time.Duration(i) * time.Microsecond),
+		}
+		if message.Role == "assistant" {
+			stored.Model = model
+		}
+
+		if err := a.dbController.AddMessage(ctx, stored); err != nil {
+			return fmt.Errorf("failed to store message, %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *App) CreateConversationRoute(ctx *fiber.Ctx) error {
+	conversation, err := a.createConversationController(ctx.Context(), callerOf(ctx), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(conversation, "application/json")
+}
+
+func (a *App) ListConversationsRoute(ctx *fiber.Ctx) error {
+	conversations, err := a.dbController.ListConversations(ctx.Context(), callerOf(ctx))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(conversations, "application/json")
+}
+
+func (a *App) GetConversationRoute(ctx *fiber.Ctx) error {
+	conversation, err := a.getConversationController(ctx.Context(), callerOf(ctx), ctx.Params("id"))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(conversation, "application/json")
+}
+
+func (a *App) DeleteConversationRoute(ctx *fiber.Ctx) error {
+	if err := a.deleteConversationController(ctx.Context(), callerOf(ctx), ctx.Params("id")); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestConversations(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	accessToken := app.accessToken(t, user)
+
+	resp := app.request(t, http.MethodPost, "/protected/conversations", accessToken, map[string]string{"title": "  "})
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("expected the conversation to be created, got %d", resp.StatusCode)
+	}
+	created := decode[models.Conversation](t, resp)
+	if created.Title != defaultConversationTitle {
+		t.Errorf("expected the default title, got %q", created.Title)
+	}
+	if stored, _ := app.db.GetConversation(context.Background(), user.ID, created.ID); stored == nil {
+		t.Errorf("expected the conversation to be owned by the user")
+	}
+
+	resp = app.request(t, http.MethodPost, "/protected/conversations", accessToken, map[string]string{"assistant_id": "unknown"})
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("expected an unknown assistant to be rejected with %d, got %d", fiber.StatusNotFound, resp.StatusCode)
+	}
+
+	listed := decode[[]models.Conversation](t, app.request(t, http.MethodGet, "/protected/conversations", accessToken, nil))
+	if len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("expected the created conversation to be listed, got %+v", listed)
+	}
+
+	app.db.AddMessage(context.Background(), &models.ConversationMessage{ID: "message", ConversationID: created.ID, Role: "user", Content: "Hi"})
+	resp = app.request(t, http.MethodGet, "/protected/conversations/"+created.ID, accessToken, nil)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected the conversation to be returned, got %d", resp.StatusCode)
+	}
+	if found := decode[models.Conversation](t, resp); len(found.Messages) != 1 || found.Messages[0].Content != "Hi" {
+		t.Errorf("expected the conversation to be returned with its messages, got %+v", found.Messages)
+	}
+
+	resp = app.request(t, http.MethodDelete, "/protected/conversations/"+created.ID, accessToken, nil)
+	if resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("expected the conversation to be deleted, got %d", resp.StatusCode)
+	}
+	resp = app.request(t, http.MethodGet, "/protected/conversations/"+created.ID, accessToken, nil)
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("expected the deleted conversation not to be found, got %d", resp.StatusCode)
+	}
+	if messages, _ := app.db.GetMessages(context.Background(), created.ID); len(messages) != 0 {
+		t.Errorf("expected the messages to be deleted along with the conversation, got %+v", messages)
+	}
+}
+
+func TestConversationsOfOtherUsers(t *testing.T) {
+	app := newTestApp(t)
+	owner := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	conversation := app.addConversation(t, owner, "", "Hi", "Hello")
+	accessToken := app.accessToken(t, app.addUser(t, "grace@example.com", "Secret-password-1", true))
+
+	tests := []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{method: http.MethodGet, path: "/protected/conversations/" + conversation.ID},
+		{method: http.MethodDelete, path: "/protected/conversations/" + conversation.ID},
+		{method: http.MethodPost, path: "/protected/openai", body: map[string]string{"openai-question": "Hi", "conversation_id": conversation.ID}},
+	}
+	for _, test := range tests {
+		resp := app.request(t, test.method, test.path, accessToken, test.body)
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, fiber.StatusNotFound, resp.StatusCode)
+		}
+	}
+
+	if listed := decode[[]models.Conversation](t, app.request(t, http.MethodGet, "/protected/conversations", accessToken, nil)); len(listed) != 0 {
+		t.Errorf("expected only the conversations of the user to be listed, got %+v", listed)
+	}
+	if messages, _ := app.db.GetMessages(context.Background(), conversation.ID); len(messages) != 2 {
+		t.Errorf("expected the conversation to be left intact, got %+v", messages)
+	}
+	if count := app.provider.requestCount(); count != 0 {
+		t.Errorf("expected nothing to be sent upstream, got %d requests", count)
+	}
+}
+
+func TestConversationHistoryIsReplayed(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	conversation := app.addConversation(t, user, "", "First", "First answer")
+
+	resp := app.request(t, http.MethodPost, "/protected/openai", app.accessToken(t, user), map[string]string{
+		"openai-question": "Second",
+		"conversation_id": conversation.ID,
+	})
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected the question to be answered, got %d", resp.StatusCode)
+	}
+
+	expected := []models.OpenAIMessage{
+		{Role: "user", Content: "First"},
+		{Role: "assistant", Content: "First answer"},
+		{Role: "user", Content: "Second"},
+	}
+	sent := app.provider.request(0).Messages
+	if len(sent) < len(expected) {
+		t.Fatalf("expected the history to be sent along with the question, got %+v", sent)
+	}
+	for i, message := range sent[len(sent)-len(expected):] {
+		if message.Role != expected[i].Role || message.Content != expected[i].Content {
+			t.Errorf("message %d: expected %+v, got %+v", i, expected[i], message)
+		}
+	}
+
+	messages, _ := app.db.GetMessages(context.Background(), conversation.ID)
+	if len(messages) != 4 || messages[2].Content != "Second" || messages[3].Content != app.provider.answer {
+		t.Fatalf("expected the turn to be appended to the conversation, got %+v", messages)
+	}
+	if messages[3].Model != testModel {
+		t.Errorf("expected the answer to be stored with the model %s, got %q", testModel, messages[3].Model)
+	}
+}
@@ -0,0 +1,364 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/documents"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+const (
+	defaultCollection = "default"
+
+	defaultTopK = 5
+	maxTopK     = 20
+
+	maxDocumentNameLength = 256
+)
+
+var collectionPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// documentUpload is a file uploaded by the user together with the chunking options.
+type documentUpload struct {
+	name         string
+	contentType  string
+	data         []byte
+	collection   string
+	chunkSize    int
+	chunkOverlap int
+}
+
+// chunkID is the id the chunk of a document is stored under in the vector store.
+func chunkID(documentID string, index int) string {
+	return documentID + ":" + strconv.Itoa(index)
+}
+
+func validateCollection(collection string) error {
+	if !collectionPattern.MatchString(collection) {
+		return fmt.Errorf("%w: collection must be 1 to 128 letters, digits, '_', '.' or '-'", errBadRequest)
+	}
+	return nil
+}
+
+// embed returns the embeddings of the texts, in the same order.
+// The texts are sent in batches as large as the embeddings api allows,
+// the tokens of every batch are accounted to the owner.
+// The personal data is redacted from the texts if the deployment asks for it.
+func (a *App) embed(ctx context.Context, owner string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += maxEmbeddingInputs {
+		batch := texts[start:min(start+maxEmbeddingInputs, len(texts))]
+
+		embeddings, err := a.llmProvider.Embed(ctx, &models.EmbeddingRequest{Input: a.redactTexts(batch)})
+		if err != nil {
+			return nil, err
+		}
+		a.recordUsage(ctx, owner, models.UsageEmbeddings, a.llmProvider.Catalog().DefaultEmbeddingModel, embeddingUsage(embeddings, batch))
+
+		if len(embeddings.Data) != len(batch) {
+			return nil, fmt.Errorf("%s: expected %d embeddings, got %d", a.llmProvider.Name(), len(batch), len(embeddings.Data))
+		}
+
+		sort.Slice(embeddings.Data, func(i, j int) bool {
+			return embeddings.Data[i].Index < embeddings.Data[j].Index
+		})
+		for _, entry := range embeddings.Data {
+			vectors = append(vectors, entry.Embedding)
+		}
+	}
+
+	return vectors, nil
+}
+
+func (a *App) uploadDocumentController(ctx context.Context, owner string, upload *documentUpload) (*models.Document, error) {
+	collection := upload.collection
+	if collection == "" {
+		collection = defaultCollection
+	}
+	if err := validateCollection(collection); err != nil {
+		return nil, err
+	}
+
+	chunker, err := documents.NewChunker(upload.chunkSize, upload.chunkOverlap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	documentType, err := documents.DetectType(upload.name, upload.contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	text, err := documents.Extract(documentType, upload.data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	name := filepath.Base(upload.name)
+	if runes := []rune(name); len(runes) > maxDocumentNameLength {
+		name = string(runes[:maxDocumentNameLength])
+	}
+
+	document := &models.Document{
+		ID:          uuid.NewString(),
+		Owner:       owner,
+		Collection:  collection,
+		Name:        name,
+		ContentType: documentType,
+		Size:        len(upload.data),
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if err := a.checkQuota(ctx, owner); err != nil {
+		return nil, err
+	}
+
+	chunks := chunker.Split(text)
+	vectors, err := a.embed(ctx, owner, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.VectorRecord, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = models.VectorRecord{
+			ID:         chunkID(document.ID, i),
+			Owner:      owner,
+			Collection: collection,
+			Content:    chunk,
+			Metadata: map[string]string{
+				"document_id":   document.ID,
+				"document_name": document.Name,
+			},
+			Vector: vectors[i],
+		}
+	}
+	document.Chunks = len(records)
+
+	if err := a.vectorStore.Upsert(ctx, records); err != nil {
+		return nil, err
+	}
+
+	if err := a.dbController.CreateDocument(ctx, document); err != nil {
+		// Chunks of a document that doesn't exist could never be deleted by the user.
+		if deleteErr := a.vectorStore.Delete(ctx, owner, documentChunkIDs(document)); deleteErr != nil {
+			log.Logger.Error("failed to delete chunks of document %s: %v", document.ID, deleteErr)
+		}
+		return nil, err
+	}
+
+	log.Logger.Info("stored document %s of %d bytes as %d chunks", document.ID, document.Size, document.Chunks)
+
+	return document, nil
+}
+
+func documentChunkIDs(document *models.Document) []string {
+	ids := make([]string, document.Chunks)
+	for i := range ids {
+		ids[i] = chunkID(document.ID, i)
+	}
+	return ids
+}
+
+func (a *App) getDocumentController(ctx context.Context, owner string, id string) (*models.Document, error) {
+	document, err := a.dbController.GetDocument(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, fmt.Errorf("%w: document %s", errNotFound, id)
+	}
+	return document, nil
+}
+
+func (a *App) deleteDocumentController(ctx context.Context, owner string, id string) error {
+	document, err := a.getDocumentController(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+
+	// The chunks go first, if that fails the document is still there to retry the deletion.
+	if err := a.vectorStore.Delete(ctx, owner, documentChunkIDs(document)); err != nil {
+		return err
+	}
+
+	return a.dbController.DeleteDocument(ctx, owner, id)
+}
+
+// retrieve returns the chunks of the documents of the owner which are the most relevant to the question.
+func (a *App) retrieve(ctx context.Context, owner string, question string, params *models.RetrievalParams) ([]models.VectorMatch, error) {
+	collections := params.Collections
+	if params.Collection != "" {
+		collections = append([]string{params.Collection}, collections...)
+	}
+	if err := validateCollections(collections); err != nil {
+		return nil, err
+	}
+	// An empty collection stands for all the documents.
+	if len(collections) == 0 {
+		collections = []string{""}
+	}
+
+	topK := params.TopK
+	if topK == 0 {
+		topK = defaultTopK
+	}
+	if topK < 1 || topK > maxTopK {
+		return nil, fmt.Errorf("%w: top_k must be between 1 and %d", errBadRequest, maxTopK)
+	}
+
+	vectors, err := a.embed(ctx, owner, []string{question})
+	if err != nil {
+		return nil, err
+	}
+
+	// The best chunks of every collection are merged, so the collections compete for the same top_k places.
+	var matches []models.VectorMatch
+	searched := make(map[string]bool)
+	for _, collection := range collections {
+		if searched[collection] {
+			continue
+		}
+		searched[collection] = true
+
+		found, err := a.vectorStore.Query(ctx, owner, collection, vectors[0], topK)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, found...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches[:min(topK, len(matches))], nil
+}
+
+// groundedQuestion puts the sources into the question, numbered so the model can cite them.
+func groundedQuestion(question string, sources []models.VectorMatch) string {
+	var sb strings.Builder
+
+	sb.WriteString("Answer the question using the sources below. Refer to the sources you use by their numbers " +
+		"in square brackets, for example [1]. If the sources don't contain the answer, say so.\n\n")
+	for i, source := range sources {
+		fmt.Fprintf(&sb, "[%d] %s\n%s\n\n", i+1, source.Metadata["document_name"], source.Content)
+	}
+	sb.WriteString("Question: ")
+	sb.WriteString(question)
+
+	return sb.String()
+}
+
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// citations returns the sources the answer refers to, in the order of their first reference.
+// References to sources that don't exist are ignored.
+func citations(answer string, sources []models.VectorMatch) []models.Citation {
+	result := []models.Citation{}
+	cited := make(map[int]bool)
+
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, number := range strings.Split(match[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(number))
+			if err != nil || n < 1 || n > len(sources) || cited[n] {
+				continue
+			}
+			cited[n] = true
+
+			source := sources[n-1]
+			result = append(result, models.Citation{
+				Source:       n,
+				ChunkID:      source.ID,
+				DocumentID:   source.Metadata["document_id"],
+				DocumentName: source.Metadata["document_name"],
+				Score:        source.Score,
+			})
+		}
+	}
+
+	return result
+}
+
+// UploadDocumentRoute accepts a multipart form with the document in the `file` field,
+// and the optional `collection`, `chunk_size` and `chunk_overlap` fields.
+func (a *App) UploadDocumentRoute(ctx *fiber.Ctx) error {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "file is missing")
+	}
+
+	upload := &documentUpload{
+		name:         file.Filename,
+		contentType:  file.Header.Get(fiber.HeaderContentType),
+		collection:   ctx.FormValue("collection"),
+		chunkSize:    documents.DefaultChunkSize,
+		chunkOverlap: documents.DefaultChunkOverlap,
+	}
+
+	for field, value := range map[string]*int{"chunk_size": &upload.chunkSize, "chunk_overlap": &upload.chunkOverlap} {
+		if formValue := ctx.FormValue(field); formValue != "" {
+			if *value, err = strconv.Atoi(formValue); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s must be an integer", field))
+			}
+		}
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	defer reader.Close()
+
+	if upload.data, err = io.ReadAll(reader); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	document, err := a.uploadDocumentController(ctx.Context(), callerOf(ctx), upload)
+	if err != nil {
+		return openaiHTTPError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(document, "application/json")
+}
+
+// ListDocumentsRoute lists the documents of the user, limited to a single collection
+// with the `collection` query parameter.
+func (a *App) ListDocumentsRoute(ctx *fiber.Ctx) error {
+	documents, err := a.dbController.ListDocuments(ctx.Context(), callerOf(ctx), ctx.Query("collection"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(documents, "application/json")
+}
+
+func (a *App) GetDocumentRoute(ctx *fiber.Ctx) error {
+	document, err := a.getDocumentController(ctx.Context(), callerOf(ctx), ctx.Params("id"))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(document, "application/json")
+}
+
+func (a *App) DeleteDocumentRoute(ctx *fiber.Ctx) error {
+	if err := a.deleteDocumentController(ctx.Context(), callerOf(ctx), ctx.Params("id")); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
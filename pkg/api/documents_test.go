@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestCitations(t *testing.T) {
+	sources := []models.VectorMatch{
+		{VectorRecord: models.VectorRecord{ID: "a:0", Metadata: map[string]string{"document_id": "a", "document_name": "a.txt"}}, Score: 0.9},
+		{VectorRecord: models.VectorRecord{ID: "a:1", Metadata: map[string]string{"document_id": "a", "document_name": "a.txt"}}, Score: 0.8},
+		{VectorRecord: models.VectorRecord{ID: "b:0", Metadata: map[string]string{"document_id": "b", "document_name": "b.pdf"}}, Score: 0.7},
+	}
+
+	tests := []struct {
+		answer   string
+		expected []string
+	}{
+		{answer: "No sources were used.", expected: []string{}},
+		{answer: "It is [3], see also [1].", expected: []string{"b:0", "a:0"}},
+		{answer: "Both [1, 2] agree, as [2] says.", expected: []string{"a:0", "a:1"}},
+		{answer: "Out of range [0] and [4], or not a number [x].", expected: []string{}},
+	}
+	for _, test := range tests {
+		result := citations(test.answer, sources)
+		if len(result) != len(test.expected) {
+			t.Errorf("%q: expected %d citations, got: %v", test.answer, len(test.expected), result)
+			continue
+		}
+		for i, citation := range result {
+			if citation.ChunkID != test.expected[i] {
+				t.Errorf("%q: expected citation %d to be %s, got: %s", test.answer, i, test.expected[i], citation.ChunkID)
+			}
+		}
+	}
+
+	citation := citations("[3]", sources)[0]
+	if citation.Source != 3 || citation.DocumentID != "b" || citation.DocumentName != "b.pdf" || citation.Score != 0.7 {
+		t.Errorf("unexpected citation: %+v", citation)
+	}
+}
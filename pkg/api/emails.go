@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	emailservice "github.com/isnastish/openai/pkg/email_service"
+)
+
+// The emails sent to the users about their accounts.
+type accountEmails struct {
+	// Whether the users have to verify their email address before they can log in.
+	requireVerification bool
+	// The address the emails are sent from.
+	from string
+	// The links in the emails, the token is added as the token query parameter.
+	verificationLink string
+	resetLink        string
+}
+
+// newAccountEmails reads the settings of the account emails from the environment.
+// REQUIRE_EMAIL_VERIFICATION is true unless set otherwise, EMAIL_FROM is the sender.
+// EMAIL_VERIFICATION_URL is the page the verification links point at, the /verify-email route of the server by default,
+// PASSWORD_RESET_URL the page of the front-end where the new password is entered.
+func newAccountEmails(port int) (*accountEmails, error) {
+	emails := &accountEmails{
+		requireVerification: true,
+		from:                "no-reply@localhost",
+		verificationLink:    fmt.Sprintf("http://localhost:%d/verify-email", port),
+		resetLink:           "http://localhost:3000/reset-password",
+	}
+
+	if required, set := os.LookupEnv("REQUIRE_EMAIL_VERIFICATION"); set && required != "" {
+		value, err := strconv.ParseBool(required)
+		if err != nil {
+			return nil, fmt.Errorf("REQUIRE_EMAIL_VERIFICATION must be a boolean")
+		}
+		emails.requireVerification = value
+	}
+	if from, set := os.LookupEnv("EMAIL_FROM"); set && from != "" {
+		emails.from = from
+	}
+
+	for name, link := range map[string]*string{
+		"EMAIL_VERIFICATION_URL": &emails.verificationLink,
+		"PASSWORD_RESET_URL":     &emails.resetLink,
+	} {
+		env, set := os.LookupEnv(name)
+		if !set || env == "" {
+			continue
+		}
+		if _, err := url.Parse(env); err != nil {
+			return nil, fmt.Errorf("%s is invalid, error: %v", name, err)
+		}
+		*link = env
+	}
+
+	return emails, nil
+}
+
+// newEmailService creates the service the emails are sent with, EMAIL_BACKEND is either aws or log,
+// which only writes the emails to the log.
+func newEmailService() (emailservice.Sender, error) {
+	backend, set := os.LookupEnv("EMAIL_BACKEND")
+	if !set || backend == "" {
+		backend = "log"
+	}
+
+	switch backend {
+	case "log":
+		return &emailservice.LogEmailService{}, nil
+	case "aws":
+		service, err := emailservice.NewAWSEmailService()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize aws mailing service, %v", err)
+		}
+		return service, nil
+	}
+
+	return nil, fmt.Errorf("unknown email backend %q", backend)
+}
+
+// linkWithToken adds the token to the query of the link.
+func linkWithToken(link string, token string) (string, error) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid link, %v", err)
+	}
+	query := parsed.Query()
+	query.Set("token", token)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+func (a *App) sendEmail(ctx context.Context, user *models.UserData, subject string, body string) error {
+	recipient := emailservice.NewRecipient([]string{user.Email}, nil)
+	return a.emailService.SendEmail(ctx, body, subject, a.accountEmails.from, recipient)
+}
@@ -0,0 +1,24 @@
+package api
+
+import "testing"
+
+func TestLinkWithToken(t *testing.T) {
+	testData := []struct {
+		link     string
+		expected string
+	}{
+		{"http://localhost:8080/verify-email", "http://localhost:8080/verify-email?token=a+b%2Fc"},
+		{"https://example.com/reset?lang=en", "https://example.com/reset?lang=en&token=a+b%2Fc"},
+		{"https://example.com/reset?token=old", "https://example.com/reset?token=a+b%2Fc"},
+	}
+
+	for _, data := range testData {
+		link, err := linkWithToken(data.link, "a b/c")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", data.link, err)
+		}
+		if link != data.expected {
+			t.Errorf("%s: expected %s, got %s", data.link, data.expected, link)
+		}
+	}
+}
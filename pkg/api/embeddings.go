@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/tokenizer"
+)
+
+// The limit of the OpenAI embeddings api on the number of inputs in one request.
+const maxEmbeddingInputs = 2048
+
+// embeddingUsage returns the usage reported by the provider,
+// or counts the tokens of the input if it doesn't report any.
+func embeddingUsage(embeddings *models.EmbeddingResponse, input []string) *models.OpenAIUsage {
+	if embeddings.Usage != nil {
+		return embeddings.Usage
+	}
+
+	counter, err := tokenizer.Get(tokenizer.Cl100kBase)
+	if err != nil {
+		log.Logger.Error("failed to estimate usage: %v", err)
+		return nil
+	}
+
+	var promptTokens int
+	for _, text := range input {
+		promptTokens += counter.Count(text)
+	}
+
+	return &models.OpenAIUsage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+	}
+}
+
+func (a *App) embeddingsController(ctx context.Context, owner string, requestBody []byte) (*models.EmbeddingResponse, error) {
+	request, err := unmarshalRequestData[models.EmbeddingRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	if len(request.Input) == 0 {
+		return nil, fmt.Errorf("%w: input is empty", errBadRequest)
+	}
+	if len(request.Input) > maxEmbeddingInputs {
+		return nil, fmt.Errorf("%w: input has %d entries, at most %d are allowed", errBadRequest, len(request.Input), maxEmbeddingInputs)
+	}
+	for i, input := range request.Input {
+		if input == "" {
+			return nil, fmt.Errorf("%w: input %d is empty", errBadRequest, i)
+		}
+	}
+
+	// Vectors are only comparable if they come from the same model,
+	// so everything stored by the server is embedded with the default one.
+	embeddingModel := a.llmProvider.Catalog().DefaultEmbeddingModel
+	if request.Model != "" && request.Model != embeddingModel {
+		return nil, fmt.Errorf("%w: embedding model %q is not supported", errBadRequest, request.Model)
+	}
+
+	if err := a.checkQuota(ctx, owner); err != nil {
+		return nil, err
+	}
+
+	embeddings, err := a.llmProvider.Embed(ctx, &models.EmbeddingRequest{
+		Model: request.Model,
+		Input: a.redactTexts(request.Input),
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.recordUsage(ctx, owner, models.UsageEmbeddings, embeddingModel, embeddingUsage(embeddings, request.Input))
+
+	return embeddings, nil
+}
+
+// EmbeddingsRoute returns the embeddings of the input strings, in the same order.
+func (a *App) EmbeddingsRoute(ctx *fiber.Ctx) error {
+	embeddings, err := a.embeddingsController(ctx.Context(), callerOf(ctx), ctx.Body())
+	if err != nil {
+		return openaiHTTPError(ctx, err)
+	}
+
+	return ctx.JSON(embeddings, "application/json")
+}
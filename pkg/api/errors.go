@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+// Controllers don't know anything about fiber,
+// they wrap these errors to tell the routes which status code to respond with.
+var (
+	errNotFound   = errors.New("not found")
+	errBadRequest = errors.New("bad request")
+	// The refresh token is invalid, expired or has been revoked.
+	errUnauthorized = errors.New("unauthorized")
+	// The resource is visible to the user, but only its owner can modify it.
+	errForbidden = errors.New("forbidden")
+	// The model misbehaved, for example kept calling tools instead of answering.
+	errBadGateway = errors.New("bad gateway")
+	// The question doesn't fit into the context window even without the history of the conversation.
+	errPromptTooLong = errors.New("prompt is too long")
+	// The user has used up the tokens or the budget of the current period.
+	errQuotaExceeded = errors.New("quota exceeded")
+	// The same request was made too soon after the previous one, like resending an email.
+	errTooManyRequests = errors.New("too many requests")
+	// The prompt or the answer was rejected by moderation.
+	errContentBlocked = errors.New("content blocked")
+)
+
+// errorStatus picks the status code which describes the error best for our clients.
+// Errors that don't wrap any of the known errors are reported with the fallback status.
+func errorStatus(err error, fallback int) int {
+	var rateLimitError *llm.RateLimitError
+	var invalidRequestError *llm.InvalidRequestError
+	var authenticationError *llm.AuthenticationError
+	var serverError *llm.ServerError
+	var contextLengthError *llm.ContextLengthExceededError
+	var netError net.Error
+
+	switch {
+	case errors.Is(err, errNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, errBadRequest):
+		return fiber.StatusBadRequest
+	case errors.Is(err, errUnauthorized):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, errForbidden):
+		return fiber.StatusForbidden
+	case errors.Is(err, errBadGateway):
+		return fiber.StatusBadGateway
+	case errors.Is(err, errQuotaExceeded), errors.Is(err, errTooManyRequests):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, errContentBlocked):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, llm.ErrNotSupported):
+		return fiber.StatusNotImplemented
+
+	// The prompt has to be shortened by the client.
+	case errors.Is(err, errPromptTooLong), errors.As(err, &contextLengthError):
+		return fiber.StatusRequestEntityTooLarge
+	case errors.As(err, &rateLimitError):
+		return fiber.StatusTooManyRequests
+	case errors.As(err, &invalidRequestError):
+		return fiber.StatusBadRequest
+	// The api key belongs to the server, so it's not the client who is unauthorized.
+	case errors.As(err, &authenticationError):
+		return fiber.StatusBadGateway
+	case errors.As(err, &serverError):
+		return fiber.StatusBadGateway
+	case errors.As(err, &netError):
+		if netError.Timeout() {
+			return fiber.StatusGatewayTimeout
+		}
+		return fiber.StatusBadGateway
+
+	default:
+		return fallback
+	}
+}
+
+// httpError converts an error returned by a controller into a fiber error.
+func httpError(err error, fallback int) error {
+	return fiber.NewError(errorStatus(err, fallback), err.Error())
+}
+
+// openaiHTTPError is httpError for the routes which call the llm provider,
+// it passes the delay requested by the provider on to the client,
+// or the time left until the exceeded quota resets.
+func openaiHTTPError(ctx *fiber.Ctx, err error) error {
+	var rateLimitError *llm.RateLimitError
+	var quotaError *quotaExceededError
+
+	var retryAfter time.Duration
+	switch {
+	case errors.As(err, &rateLimitError):
+		retryAfter = rateLimitError.RetryAfter
+	case errors.As(err, &quotaError):
+		retryAfter = time.Until(quotaError.resetAt)
+	}
+	if retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	}
+
+	return httpError(err, fiber.StatusInternalServerError)
+}
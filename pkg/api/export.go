@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/export"
+)
+
+// exportFormat returns the format asked for in the query, json unless told otherwise.
+func exportFormat(ctx *fiber.Ctx) (string, string, error) {
+	format := ctx.Query("format", export.FormatJSON)
+	contentType, ok := export.ContentType(format)
+	if !ok {
+		return "", "", fmt.Errorf("%w: unknown export format %q, expected json, markdown or jsonl", errBadRequest, format)
+	}
+	return format, contentType, nil
+}
+
+// exportConversationsController returns all the conversations of the owner together with their messages.
+func (a *App) exportConversationsController(ctx context.Context, owner string) ([]models.Conversation, error) {
+	conversations, err := a.dbController.ListConversations(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range conversations {
+		messages, err := a.dbController.GetMessages(ctx, conversations[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		conversations[i].Messages = messages
+	}
+
+	return conversations, nil
+}
+
+// importConversationsController recreates the exported conversations under new ids.
+// The whole document is validated before anything is stored,
+// so a malformed import doesn't leave half of the conversations behind.
+func (a *App) importConversationsController(ctx context.Context, owner string, requestBody []byte) ([]models.Conversation, error) {
+	exported, err := export.Parse(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	now := time.Now().UTC()
+	imported := make([]models.Conversation, 0, len(exported.Conversations))
+
+	for _, exportedConversation := range exported.Conversations {
+		title := exportedConversation.Title
+		if title == "" {
+			title = defaultConversationTitle
+		}
+
+		createdAt := exportedConversation.CreatedAt.UTC()
+		if createdAt.IsZero() && len(exportedConversation.Messages) > 0 {
+			createdAt = exportedConversation.Messages[0].CreatedAt.UTC()
+		}
+		if createdAt.IsZero() || createdAt.After(now) {
+			createdAt = now
+		}
+
+		conversation := &models.Conversation{
+			ID:        uuid.NewString(),
+			Owner:     owner,
+			Title:     title,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		}
+		if err := a.dbController.CreateConversation(ctx, conversation); err != nil {
+			return nil, err
+		}
+
+		// Messages are ordered by the time they were created at,
+		// so the timestamps are kept only as long as they preserve the order of the export.
+		// Like the conversation itself, a message can't have been created in the future.
+		last := createdAt
+		for i, exportedMessage := range exportedConversation.Messages {
+			createdAt := exportedMessage.CreatedAt.UTC()
+			if createdAt.After(now) {
+				createdAt = now
+			}
+			switch {
+			case i > 0 && !createdAt.After(last):
+				createdAt = last.Add(time.Microsecond)
+			case createdAt.Before(last):
+				createdAt = last
+			}
+			last = createdAt
+
+			message := models.ConversationMessage{
+				ID:             uuid.NewString(),
+				ConversationID: conversation.ID,
+				Role:           exportedMessage.Role,
+				Content:        exportedMessage.Content,
+				CreatedAt:      createdAt,
+				Model:          exportedMessage.Model,
+				ToolCalls:      exportedMessage.ToolCalls,
+				ToolCallID:     exportedMessage.ToolCallID,
+			}
+			if err := a.dbController.AddMessage(ctx, &message); err != nil {
+				return nil, fmt.Errorf("failed to store message, %v", err)
+			}
+			conversation.Messages = append(conversation.Messages, message)
+		}
+		conversation.UpdatedAt = last
+
+		imported = append(imported, *conversation)
+	}
+
+	return imported, nil
+}
+
+func sendExport(ctx *fiber.Ctx, format string, contentType string, name string, conversations []models.Conversation) error {
+	data, err := export.Render(format, conversations, time.Now().UTC())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Attachment(name + "." + export.Extension(format))
+	return ctx.Send(data)
+}
+
+func (a *App) ExportConversationsRoute(ctx *fiber.Ctx) error {
+	format, contentType, err := exportFormat(ctx)
+	if err != nil {
+		return httpError(err, fiber.StatusBadRequest)
+	}
+
+	conversations, err := a.exportConversationsController(ctx.Context(), callerOf(ctx))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return sendExport(ctx, format, contentType, "conversations", conversations)
+}
+
+func (a *App) ExportConversationRoute(ctx *fiber.Ctx) error {
+	format, contentType, err := exportFormat(ctx)
+	if err != nil {
+		return httpError(err, fiber.StatusBadRequest)
+	}
+
+	conversation, err := a.getConversationController(ctx.Context(), callerOf(ctx), ctx.Params("id"))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return sendExport(ctx, format, contentType, "conversation-"+conversation.ID, []models.Conversation{*conversation})
+}
+
+func (a *App) ImportConversationsRoute(ctx *fiber.Ctx) error {
+	conversations, err := a.importConversationsController(ctx.Context(), callerOf(ctx), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(conversations, "application/json")
+}
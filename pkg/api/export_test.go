@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/export"
+)
+
+func TestImportClampsFutureTimestamps(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+
+	past := time.Now().UTC().Add(-time.Hour)
+	future := time.Now().UTC().Add(24 * time.Hour)
+	resp := app.request(t, http.MethodPost, "/protected/conversations/import", app.accessToken(t, user), models.ConversationExport{
+		Version: export.Version,
+		Conversations: []models.ExportedConversation{{
+			Title:     "Greetings",
+			CreatedAt: past,
+			Messages: []models.ExportedMessage{
+				{Role: "user", Content: "Hi", CreatedAt: past},
+				{Role: "assistant", Content: "Hello", CreatedAt: future},
+				{Role: "user", Content: "Bye", CreatedAt: future.Add(time.Minute)},
+			},
+		}},
+	})
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("expected the conversations to be imported, got %d", resp.StatusCode)
+	}
+	imported := decode[[]models.Conversation](t, resp)
+
+	messages, _ := app.db.GetMessages(context.Background(), imported[0].ID)
+	if len(messages) != 3 || messages[0].Content != "Hi" || messages[1].Content != "Hello" || messages[2].Content != "Bye" {
+		t.Fatalf("expected the order of the export to be kept, got %+v", messages)
+	}
+	if !messages[0].CreatedAt.Equal(past) {
+		t.Errorf("expected the timestamp in the past to be kept, got %v", messages[0].CreatedAt)
+	}
+	// The later messages are only moved apart to keep their order.
+	for _, message := range messages[1:] {
+		if message.CreatedAt.After(time.Now().UTC().Add(time.Second)) {
+			t.Errorf("expected the message %q not to be created in the future, got %v", message.Content, message.CreatedAt)
+		}
+	}
+	if conversation, _ := app.db.GetConversation(context.Background(), user.ID, imported[0].ID); conversation.UpdatedAt.After(time.Now().UTC().Add(time.Second)) {
+		t.Errorf("expected the conversation not to be updated in the future, got %v", conversation.UpdatedAt)
+	}
+}
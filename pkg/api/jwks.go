@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/auth"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// Only used when neither a signing key nor a secret is configured, it's meant for development.
+const developmentJwtSecret = "my-dummy-secret"
+
+// newAuthManager creates the manager of the tokens from the environment.
+// JWT_SIGNING_KEY is the path of the PEM file with the RSA or Ed25519 private key the tokens are signed with,
+// JWT_VERIFICATION_KEYS a comma-separated list of the paths of the keys of the previous rotations,
+// which are still accepted. Without a signing key, the tokens are signed with JWT_SECRET using HS256.
+func newAuthManager(accessTokenTTL time.Duration) (*auth.AuthManager, error) {
+	signingKeyPath, set := os.LookupEnv("JWT_SIGNING_KEY")
+	if !set || signingKeyPath == "" {
+		secret, set := os.LookupEnv("JWT_SECRET")
+		if !set || secret == "" {
+			log.Logger.Warn("Neither JWT_SIGNING_KEY nor JWT_SECRET is set, signing tokens with the development secret")
+			secret = developmentJwtSecret
+		}
+		return auth.NewAuthManager([]byte(secret), accessTokenTTL), nil
+	}
+
+	signingKey, err := auth.LoadKey(signingKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var verificationKeys []*auth.Key
+	if paths, set := os.LookupEnv("JWT_VERIFICATION_KEYS"); set && paths != "" {
+		for _, path := range strings.Split(paths, ",") {
+			key, err := auth.LoadKey(strings.TrimSpace(path))
+			if err != nil {
+				return nil, err
+			}
+			verificationKeys = append(verificationKeys, key)
+		}
+	}
+
+	manager, err := auth.NewAsymmetricAuthManager(signingKey, verificationKeys, accessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_SIGNING_KEY, %v", err)
+	}
+
+	log.Logger.Info("signing tokens with %s key %s, %d verification keys", signingKey.Method.Alg(), signingKey.ID, len(verificationKeys))
+
+	return manager, nil
+}
+
+// JWKSRoute publishes the public keys the tokens can be verified with.
+// The keys change rarely, but the verifiers should pick up a rotation within minutes.
+func (a *App) JWKSRoute(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return ctx.JSON(a.auth.JWKS(), "application/json")
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// A named set of defaults questions are answered with: the system prompt, the model and its parameters,
+// the tools the model can call and the documents the answers are grounded in.
+// Conversations started with an assistant use its defaults, the parameters of a question take precedence.
+type Assistant struct {
+	ID string `json:"id" bson:"_id"`
+	// The user who owns the assistant, never exposed to the client.
+	Owner       string       `json:"-" bson:"owner"`
+	Name        string       `json:"name" bson:"name"`
+	Description string       `json:"description" bson:"description"`
+	Params      OpenAIParams `json:"params" bson:"params"`
+	// Collections of documents the answers are retrieved from, none disables retrieval.
+	Collections []string  `json:"collections" bson:"collections"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// A request made from the frontend to create an assistant or to modify it.
+// Omitted fields of a modification are left unchanged, the parameters are replaced as a whole.
+type AssistantRequest struct {
+	Name        *string       `json:"name,omitempty"`
+	Description *string       `json:"description,omitempty"`
+	Params      *OpenAIParams `json:"params,omitempty"`
+	Collections *[]string     `json:"collections,omitempty"`
+}
@@ -1,10 +1,17 @@
 package models
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The subject of both the access and the refresh tokens is the ID of the user.
 type Claims struct {
-	// TODO: We should use user ID instead of email address.
-	Email string `json:"email"`
+	// Only set on access tokens, the organisation of the user is derived from it.
+	Email string `json:"email,omitempty"`
+	// Only set on access tokens, the protected routes can require it.
+	EmailVerified bool `json:"email_verified,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -12,3 +19,43 @@ type Tokens struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+// A refresh token issued to a user, kept on the server so it can be revoked.
+// Every refresh rotates the token: the presented one is marked as rotated and a new one
+// of the same family is issued. The family starts at login and ends at logout.
+type RefreshToken struct {
+	// The jti claim of the token.
+	ID     string `json:"id" bson:"_id"`
+	Family string `json:"family" bson:"family"`
+	// The ID of the user, the subject of the token.
+	Owner     string    `json:"owner" bson:"owner"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	// Set once the token has been exchanged for a new one, it's never accepted again.
+	RotatedAt *time.Time `json:"rotated_at,omitempty" bson:"rotated_at,omitempty"`
+	Revoked   bool       `json:"revoked" bson:"revoked"`
+}
+
+// A token sent in a password reset email, it can only be used once.
+// Only the hash of the token is stored, the token itself is never accepted from the database.
+type PasswordResetToken struct {
+	// The hex encoded SHA-256 hash of the token.
+	ID string `json:"id" bson:"_id"`
+	// The ID of the user whose password can be reset.
+	Owner     string    `json:"owner" bson:"owner"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	// Set once the password has been reset with the token.
+	UsedAt *time.Time `json:"used_at,omitempty" bson:"used_at,omitempty"`
+}
+
+type PasswordResetRequest struct {
+	// The token from the link in the password reset email.
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type Conversation struct {
+	ID string `json:"id" bson:"_id"`
+	// The user who owns the conversation, never exposed to the client.
+	Owner     string    `json:"-" bson:"owner"`
+	Title     string    `json:"title" bson:"title"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// The assistant the conversation was started with, empty for the server's defaults.
+	AssistantID string `json:"assistant_id,omitempty" bson:"assistant_id,omitempty"`
+	// Populated only when a single conversation is requested.
+	Messages []ConversationMessage `json:"messages,omitempty" bson:"-"`
+}
+
+// A single message stored as part of a conversation: a question of the user,
+// an answer of the model, a request of the model to call tools, or a result of a tool call.
+type ConversationMessage struct {
+	ID             string    `json:"id" bson:"_id"`
+	ConversationID string    `json:"conversation_id" bson:"conversation_id"`
+	Role           string    `json:"role" bson:"role"`
+	Content        string    `json:"content" bson:"content"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	// The model which produced an assistant message, empty for user messages.
+	Model string `json:"model,omitempty" bson:"model,omitempty"`
+	// Set on assistant messages which asked for tools to be called.
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty" bson:"tool_calls,omitempty"`
+	// Set on tool messages, the id of the call the message holds the result of.
+	ToolCallID string `json:"tool_call_id,omitempty" bson:"tool_call_id,omitempty"`
+}
+
+// A request made from the frontend to create a new conversation.
+type ConversationRequest struct {
+	Title string `json:"title"`
+	// Optional, the assistant the questions asked in the conversation are answered by.
+	AssistantID string `json:"assistant_id,omitempty"`
+}
+
+// Conversations exported in the json format, the same document is accepted by the import.
+// Ids aren't exported, imported conversations and messages get new ones.
+type ConversationExport struct {
+	Version       int                    `json:"version"`
+	ExportedAt    time.Time              `json:"exported_at"`
+	Conversations []ExportedConversation `json:"conversations"`
+}
+
+type ExportedConversation struct {
+	Title     string            `json:"title"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Messages  []ExportedMessage `json:"messages"`
+}
+
+type ExportedMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	Model      string           `json:"model,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// A file uploaded by a user, its text is split into chunks which are stored in the vector store.
+type Document struct {
+	ID string `json:"id" bson:"_id"`
+	// The user who owns the document, never exposed to the client.
+	Owner string `json:"-" bson:"owner"`
+	// Documents of a user are grouped into collections, questions can be asked about a single one.
+	Collection string `json:"collection" bson:"collection"`
+	Name       string `json:"name" bson:"name"`
+	// One of the types supported by the documents package.
+	ContentType string `json:"content_type" bson:"content_type"`
+	// The size of the uploaded file in bytes.
+	Size int `json:"size" bson:"size"`
+	// The chunks are stored under the ids <document id>:<chunk index>.
+	Chunks    int       `json:"chunks" bson:"chunks"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Asks the server to answer a question using the documents of the user.
+type RetrievalParams struct {
+	// Empty stands for all the documents of the user.
+	Collection string `json:"collection,omitempty"`
+	// Searched together with the collection above, if both are empty all the documents of the user are.
+	Collections []string `json:"collections,omitempty"`
+	// The number of chunks put into the prompt, the server's default if omitted.
+	TopK int `json:"top_k,omitempty"`
+}
+
+// A chunk of a document the model referred to in its answer.
+type Citation struct {
+	// The number the chunk was given in the prompt, the answer refers to it as [n].
+	Source       int     `json:"source"`
+	ChunkID      string  `json:"chunk_id"`
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name"`
+	Score        float64 `json:"score"`
+}
@@ -0,0 +1,18 @@
+package models
+
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingEntry struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+type EmbeddingResponse struct {
+	Model string           `json:"model"`
+	Data  []EmbeddingEntry `json:"data"`
+	// Only the prompt tokens are set, nil if the provider didn't report the usage.
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// See https://platform.openai.com/docs/api-reference/moderations
+type ModerationRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type ModerationResult struct {
+	Flagged bool `json:"flagged"`
+	// Category name -> whether the input violates it.
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
+
+// Where in the pipeline the text was moderated.
+const (
+	ModerationInput  = "input"
+	ModerationOutput = "output"
+)
+
+// A prompt or an answer flagged by moderation, kept for review by the admins.
+type ModerationEvent struct {
+	ID             string   `json:"id" bson:"_id"`
+	Owner          string   `json:"owner" bson:"owner"`
+	ConversationID string   `json:"conversation_id,omitempty" bson:"conversation_id,omitempty"`
+	Stage          string   `json:"stage" bson:"stage"`
+	Categories     []string `json:"categories" bson:"categories"`
+	// Whether the request was rejected, flagged categories which aren't blocked are only recorded.
+	Blocked   bool      `json:"blocked" bson:"blocked"`
+	Text      string    `json:"text" bson:"text"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
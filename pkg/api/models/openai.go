@@ -1,22 +1,147 @@
 package models
 
+import "encoding/json"
+
 type OpenAIMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Set on assistant messages which ask for tools to be called instead of answering.
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+	// Set on tool messages, the id of the call the message holds the result of.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+type OpenAIFunctionCall struct {
+	Name string `json:"name,omitempty" bson:"name"`
+	// Json encoded arguments generated by the model, they are not guaranteed to be valid.
+	Arguments string `json:"arguments" bson:"arguments"`
+}
+
+type OpenAIToolCall struct {
+	// Only set in stream chunks, where a single call is split across several chunks.
+	Index    *int               `json:"index,omitempty" bson:"-"`
+	ID       string             `json:"id,omitempty" bson:"id"`
+	Type     string             `json:"type,omitempty" bson:"type"`
+	Function OpenAIFunctionCall `json:"function" bson:"function"`
+}
+
+type OpenAIFunctionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Json schema of the arguments.
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// A tool the model is allowed to call, only functions are supported by OpenAI.
+type OpenAITool struct {
+	Type     string                   `json:"type"`
+	Function OpenAIFunctionDefinition `json:"function"`
+}
+
+type OpenAIJSONSchemaFormat struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	// Strict mode only accepts a subset of json schema, so it's left off for schemas supplied by the clients.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Constrains the output of the model, see https://platform.openai.com/docs/guides/structured-outputs
+type OpenAIResponseFormat struct {
+	// `json_schema` is the only type the server uses.
+	Type       string                  `json:"type"`
+	JSONSchema *OpenAIJSONSchemaFormat `json:"json_schema,omitempty"`
 }
 
 type OpenAIChoiceEntry struct {
 	Index   int           `json:"index"`
 	Message OpenAIMessage `json:"message"`
+	// `tool_calls` when the model asks for tools to be called.
+	FinishReason string `json:"finish_reason,omitempty"`
+}
+
+// Token counts of a chat completion.
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type OpenAIResp struct {
 	Model   string              `json:"model"`
 	Choices []OpenAIChoiceEntry `json:"choices"`
+	// Nil if the provider didn't report the usage.
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+}
+
+type OpenAIStreamOptions struct {
+	// Asks OpenAI to send the usage in an extra chunk without choices before `[DONE]`.
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// The body of a chat completion request sent to OpenAI api.
+type OpenAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []OpenAIMessage       `json:"messages"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	TopP           *float64              `json:"top_p,omitempty"`
+	MaxTokens      *int                  `json:"max_tokens,omitempty"`
+	Stop           []string              `json:"stop,omitempty"`
+	Seed           *int64                `json:"seed,omitempty"`
+	Tools          []OpenAITool          `json:"tools,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *OpenAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// Generation parameters which can be set per request by the frontend.
+// Every parameter is optional, unset parameters fall back to the defaults
+// of the model, an empty model and system prompt fall back to the server's defaults.
+type OpenAIParams struct {
+	Model        string   `json:"model,omitempty" bson:"model,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty" bson:"temperature,omitempty"`
+	TopP         *float64 `json:"top_p,omitempty" bson:"top_p,omitempty"`
+	MaxTokens    *int     `json:"max_tokens,omitempty" bson:"max_tokens,omitempty"`
+	Stop         []string `json:"stop,omitempty" bson:"stop,omitempty"`
+	Seed         *int64   `json:"seed,omitempty" bson:"seed,omitempty"`
+	SystemPrompt string   `json:"system_prompt,omitempty" bson:"system_prompt,omitempty"`
+	// Names of the server-side tools the model is allowed to call.
+	// If omitted, all the tools are available, an empty list disables tool calling.
+	// Not omitted when empty, so the difference survives storing the parameters of an assistant.
+	Tools []string `json:"tools" bson:"tools"`
+	// Json schema the answer has to conform to, the answer is returned as a parsed json document
+	// rather than a string.
+	ResponseSchema json.RawMessage `json:"response_schema,omitempty" bson:"response_schema,omitempty"`
 }
 
 // This is not a request to OpenAI api, it's a request made from our frontend
 // to the backend server
 type OpenAIRequest struct {
 	OpenaiQuestion string `json:"openai-question"`
+	// Optional, when set, the question is sent together with the previous turns
+	// of the conversation, and both the question and the answer are stored in it.
+	ConversationID string `json:"conversation_id,omitempty"`
+	// Optional, when set, the question is answered using the most relevant chunks
+	// of the documents uploaded by the user.
+	Retrieval *RetrievalParams `json:"retrieval,omitempty"`
+	// How the history of the conversation is trimmed when it doesn't fit into the context window
+	// of the model, either drop_oldest or summarize. The server's default if omitted.
+	ContextStrategy string `json:"context_strategy,omitempty"`
+	// Skips the response cache, so a deterministic prompt is answered by the model again.
+	NoCache bool `json:"no_cache,omitempty"`
+	OpenAIParams
+}
+
+// A single server-sent event emitted by OpenAI when a chat completion
+// is requested with `stream: true`. Only the delta is set on each choice.
+type OpenAIStreamChoiceEntry struct {
+	Index        int           `json:"index"`
+	Delta        OpenAIMessage `json:"delta"`
+	FinishReason *string       `json:"finish_reason"`
+}
+
+type OpenAIStreamChunk struct {
+	Model   string                    `json:"model"`
+	Choices []OpenAIStreamChoiceEntry `json:"choices"`
+	// Set only on the last chunk, if the provider reports the usage at all.
+	Usage *OpenAIUsage `json:"usage,omitempty"`
 }
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// A full-text search over the messages of a user's conversations.
+type MessageSearchQuery struct {
+	Text string
+	// Zero values leave the range open on that side, From is inclusive and To is exclusive.
+	From time.Time
+	To   time.Time
+	// Empty values match messages of any model or role.
+	Model string
+	Role  string
+	Limit int
+	// Set to continue the search after the last hit of the previous page.
+	After *MessageSearchCursor
+}
+
+// The position of a hit in the results, which are ordered by rank,
+// then by creation time with the newest first, then by message id.
+type MessageSearchCursor struct {
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+	MessageID string    `json:"message_id"`
+}
+
+type MessageSearchHit struct {
+	ConversationID    string `json:"conversation_id"`
+	ConversationTitle string `json:"conversation_title"`
+	MessageID         string `json:"message_id"`
+	Role              string `json:"role"`
+	Model             string `json:"model,omitempty"`
+	// Html escaped fragment of the message, with the matched words wrapped into <mark> tags.
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MessageSearchResponse struct {
+	Hits []MessageSearchHit `json:"hits"`
+	// Passed as the cursor to get the next page, empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Who a prompt template is visible to.
+const (
+	TemplatePrivate      = "private"
+	TemplateOrganization = "organization"
+)
+
+// A prompt stored to be reused. The prompt itself is kept in versions, which are never modified,
+// a template only points at the latest one.
+type PromptTemplate struct {
+	ID string `json:"id" bson:"_id"`
+	// The user who created the template, the only one allowed to modify it, never exposed to the client.
+	Owner       string `json:"-" bson:"owner"`
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description" bson:"description"`
+	Visibility  string `json:"visibility" bson:"visibility"`
+	// The organisation of the owner at the time the template was created, empty if the owner has none.
+	Organization  string    `json:"organization,omitempty" bson:"organization"`
+	LatestVersion int       `json:"latest_version" bson:"latest_version"`
+	CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updated_at"`
+	// Populated only when a single template is requested, oldest first.
+	Versions []PromptTemplateVersion `json:"versions,omitempty" bson:"-"`
+}
+
+type PromptTemplateVersion struct {
+	TemplateID string `json:"template_id" bson:"template_id"`
+	Version    int    `json:"version" bson:"version"`
+	// The prompt in the syntax of Go's text/template, variables are referred to as {{.name}}.
+	Content string `json:"content" bson:"content"`
+	// The variables the content refers to.
+	Variables []string  `json:"variables" bson:"variables"`
+	CreatedBy string    `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// A request made from the frontend to create a template together with its first version,
+// or to modify it. Omitted fields of a modification are left unchanged.
+type PromptTemplateRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Visibility  *string `json:"visibility,omitempty"`
+	// Only read when the template is created.
+	Content string `json:"content,omitempty"`
+}
+
+type PromptTemplateVersionRequest struct {
+	Content string `json:"content"`
+}
+
+// A request to render a template and send the result to the model as the question.
+// The rest of the parameters are the ones of an ordinary question, except the question itself.
+type PromptTemplateRunRequest struct {
+	// The latest version if omitted.
+	Version   int            `json:"version,omitempty"`
+	Variables map[string]any `json:"variables"`
+	OpenAIRequest
+}
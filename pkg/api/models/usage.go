@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Kinds of requests made to the llm provider.
+const (
+	UsageChat       = "chat"
+	UsageEmbeddings = "embeddings"
+)
+
+// The tokens used by a single request of a user, and what they cost.
+type UsageRecord struct {
+	ID string `json:"id" bson:"_id"`
+	// The user who made the request, never exposed to the client.
+	Owner            string `json:"-" bson:"owner"`
+	Kind             string `json:"kind" bson:"kind"`
+	Model            string `json:"model" bson:"model"`
+	PromptTokens     int    `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens" bson:"completion_tokens"`
+	// In US dollars.
+	Cost      float64   `json:"cost" bson:"cost"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// The usage of a single model summed over a period of time.
+type ModelUsage struct {
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// The usage of a user over a period of time, together with the quota of the period.
+type UsagePeriod struct {
+	From             time.Time    `json:"from"`
+	To               time.Time    `json:"to"`
+	PromptTokens     int          `json:"prompt_tokens"`
+	CompletionTokens int          `json:"completion_tokens"`
+	TotalTokens      int          `json:"total_tokens"`
+	Cost             float64      `json:"cost"`
+	Models           []ModelUsage `json:"models"`
+	// Omitted when the deployment doesn't limit the period.
+	TokenQuota int64   `json:"token_quota,omitempty"`
+	CostQuota  float64 `json:"cost_quota,omitempty"`
+}
+
+type Usage struct {
+	Daily   UsagePeriod `json:"daily"`
+	Monthly UsagePeriod `json:"monthly"`
+}
@@ -1,11 +1,20 @@
 package models
 
+import "time"
+
 // TODO: Create mongodb data wrapper instead of specifying mongodb specific tags here.
 type UserData struct {
+	// Assigned on signup, the subject of the tokens and the owner of everything the user creates.
+	// Unlike the email address, it never changes.
+	ID        string `json:"id" bson:"_id"`
 	FirstName string `json:"first_name" bson:"first_name"`
 	LastName  string `json:"last_name" bson:"last_name"`
 	Email     string `json:"email" bson:"email"`
 	Password  string `json:"password" bson:"password"`
+	// Users added before emails were verified count as verified.
+	EmailVerified bool `json:"-" bson:"email_verified"`
+	// When the last verification email was sent, resending is throttled.
+	VerificationSentAt *time.Time `json:"-" bson:"verification_sent_at,omitempty"`
 	// Geolocation data
 	// Country string `json:"-"`
 	// City    string `json:"-"`
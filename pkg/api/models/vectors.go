@@ -0,0 +1,21 @@
+package models
+
+// A piece of text stored in a vector store together with its embedding.
+type VectorRecord struct {
+	// Unique per owner.
+	ID string `json:"id"`
+	// The user who owns the record, never exposed to the client.
+	Owner string `json:"-"`
+	// Groups the records of a user, queries can be limited to a single collection.
+	Collection string            `json:"collection"`
+	Content    string            `json:"content"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Vector     []float32         `json:"-"`
+}
+
+// A record returned by a similarity query.
+type VectorMatch struct {
+	VectorRecord
+	// Cosine similarity between the record and the query, from -1 to 1.
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Types of the messages sent by the client over the chat socket.
+const (
+	// Asks a question, the answer is streamed back.
+	ChatSocketSend = "send"
+	// Aborts the answer being streamed, nothing is stored in the conversation.
+	ChatSocketCancel = "cancel"
+	// Answers the last question of the conversation again, replacing the previous answer.
+	ChatSocketRegenerate = "regenerate"
+)
+
+// A message sent by the client over the chat socket.
+// The question and the parameters are only read from send and regenerate messages,
+// regenerate takes the question from the conversation.
+type ChatSocketRequest struct {
+	Type string `json:"type"`
+	// Optional, echoed back in every event produced while answering the message.
+	RequestID string `json:"request_id,omitempty"`
+	OpenAIRequest
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/moderation"
+	"github.com/isnastish/openai/pkg/openai"
+)
+
+// moderationPolicy decides which of the flagged prompts and answers are rejected.
+type moderationPolicy struct {
+	moderator moderation.Moderator
+	// Categories which get the request rejected, all of them if empty.
+	// The prompts and answers flagged for other categories are only recorded.
+	blocked map[string]bool
+	// Whether the answers are moderated too, not only the prompts.
+	checkOutput bool
+}
+
+// newModerationPolicy creates the policy configured with MODERATION_BACKEND, either none, openai or rules.
+// The rules backend reads the rules from the json file at MODERATION_RULES.
+// MODERATION_BLOCKED_CATEGORIES is a comma-separated list of the categories to reject,
+// MODERATION_CHECK_OUTPUT=true enables moderation of the answers.
+// Returns nil if moderation is disabled.
+func newModerationPolicy() (*moderationPolicy, error) {
+	backend, set := os.LookupEnv("MODERATION_BACKEND")
+	if !set || backend == "" {
+		backend = "none"
+	}
+
+	policy := &moderationPolicy{
+		blocked: make(map[string]bool),
+	}
+
+	switch backend {
+	case "none":
+		return nil, nil
+
+	case "openai":
+		// Moderation is only offered by OpenAI, so it needs an OpenAI key whichever llm backend answers the questions.
+		client, err := openai.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an OpenAI client for moderation, error: %v", err)
+		}
+		policy.moderator = moderation.NewOpenAI(client, os.Getenv("MODERATION_MODEL"))
+
+	case "rules":
+		rulesPath, set := os.LookupEnv("MODERATION_RULES")
+		if !set || rulesPath == "" {
+			return nil, fmt.Errorf("MODERATION_RULES is not set")
+		}
+		rules, err := moderation.LoadRules(rulesPath)
+		if err != nil {
+			return nil, err
+		}
+		policy.moderator = rules
+
+	default:
+		return nil, fmt.Errorf("unknown moderation backend %q", backend)
+	}
+
+	if categories, set := os.LookupEnv("MODERATION_BLOCKED_CATEGORIES"); set {
+		for _, category := range strings.Split(categories, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				policy.blocked[category] = true
+			}
+		}
+	}
+
+	policy.checkOutput = os.Getenv("MODERATION_CHECK_OUTPUT") == "true"
+
+	return policy, nil
+}
+
+// blockedOf returns the flagged categories which get the request rejected.
+func (p *moderationPolicy) blockedOf(categories []string) []string {
+	if len(p.blocked) == 0 {
+		return categories
+	}
+
+	var blocked []string
+	for _, category := range categories {
+		if p.blocked[category] {
+			blocked = append(blocked, category)
+		}
+	}
+	return blocked
+}
+
+// moderate checks the text against the moderation policy, the flagged texts are stored for review.
+// Returns an error wrapping errContentBlocked, which names the categories, if the text violates any of the blocked ones.
+func (a *App) moderate(ctx context.Context, owner string, conversationID string, stage string, text string) error {
+	if a.moderation == nil || (stage == models.ModerationOutput && !a.moderation.checkOutput) {
+		return nil
+	}
+
+	categories, err := a.moderation.moderator.Moderate(ctx, text)
+	if err != nil {
+		return err
+	}
+	if len(categories) == 0 {
+		return nil
+	}
+
+	blocked := a.moderation.blockedOf(categories)
+
+	event := &models.ModerationEvent{
+		ID:             uuid.NewString(),
+		Owner:          owner,
+		ConversationID: conversationID,
+		Stage:          stage,
+		Categories:     categories,
+		Blocked:        len(blocked) > 0,
+		Text:           text,
+		CreatedAt:      time.Now().UTC(),
+	}
+	// Failing to store the event for review shouldn't decide whether the request goes through.
+	if err := a.dbController.AddModerationEvent(context.WithoutCancel(ctx), event); err != nil {
+		log.Logger.Error("failed to store moderation event: %v", err)
+	}
+
+	if len(blocked) == 0 {
+		log.Logger.Warn("%s of %s is flagged for %s", stage, owner, strings.Join(categories, ", "))
+		return nil
+	}
+
+	subject := "prompt"
+	if stage == models.ModerationOutput {
+		subject = "answer"
+	}
+	return fmt.Errorf("%w: the %s violates the content policy, category: %s", errContentBlocked, subject, strings.Join(blocked, ", "))
+}
+
+// moderatedPrompt is the text of the request written by the user.
+func moderatedPrompt(query *models.OpenAIRequest) string {
+	if query.SystemPrompt == "" {
+		return query.OpenaiQuestion
+	}
+	return query.SystemPrompt + "\n\n" + query.OpenaiQuestion
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlockedOf(t *testing.T) {
+	categories := []string{"harassment", "violence"}
+
+	all := &moderationPolicy{blocked: map[string]bool{}}
+	if blocked := all.blockedOf(categories); !reflect.DeepEqual(blocked, categories) {
+		t.Errorf("expected every flagged category to be blocked, got %v", blocked)
+	}
+
+	configured := &moderationPolicy{blocked: map[string]bool{"violence": true, "hate": true}}
+	if blocked := configured.blockedOf(categories); !reflect.DeepEqual(blocked, []string{"violence"}) {
+		t.Errorf("expected only violence to be blocked, got %v", blocked)
+	}
+	if blocked := configured.blockedOf([]string{"harassment"}); len(blocked) != 0 {
+		t.Errorf("expected harassment to be only recorded, got %v", blocked)
+	}
+}
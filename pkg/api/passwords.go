@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/validator"
+)
+
+// How long the links sent to reset the passwords are valid.
+const passwordResetTTL = time.Hour
+
+// A password reset email is sent at most once per interval to the same user.
+const passwordResetEmailInterval = time.Minute
+
+// hashPasswordResetToken returns the hash the token is stored under.
+// The tokens are random and long, so unlike the passwords they don't need a slow hash.
+func hashPasswordResetToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// forgotPasswordController emails the user a link to reset their password, at most once per passwordResetEmailInterval.
+// Nothing is sent to an address no user has, nor when the email is throttled or fails to be sent,
+// but the response is always the same, so it doesn't tell whether the address has an account.
+func (a *App) forgotPasswordController(ctx context.Context, requestBody []byte) error {
+	request, err := unmarshalRequestData[models.UserData](requestBody)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if request.Email == "" {
+		return fmt.Errorf("%w: email is required", errBadRequest)
+	}
+
+	user, err := a.dbController.GetUserByEmail(ctx, request.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	recorded, err := a.dbController.RecordPasswordResetEmail(ctx, user.ID, time.Now().UTC(), passwordResetEmailInterval)
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		log.Logger.Info("Password reset email to %s has been sent recently, not sending another one", user.ID)
+		return nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate password reset token, %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	err = a.dbController.CreatePasswordResetToken(ctx, &models.PasswordResetToken{
+		ID:        hashPasswordResetToken(token),
+		Owner:     user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(a.accountEmails.resetLink, token)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. If it was you, open the link below, it expires in %v.\n\n%s\n\n"+
+		"Otherwise you can ignore this email, your password stays the same.\n", user.FirstName, passwordResetTTL, link)
+
+	if err := a.sendEmail(ctx, user, "Reset your password", body); err != nil {
+		log.Logger.Error("Failed to send the password reset email to %s: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// resetPasswordController sets the new password of the user the token was sent to and ends all of their sessions,
+// whoever had access to the account is logged out. The other links sent to the user can't be used anymore either. The access tokens which have already been issued stay valid until they expire.
+func (a *App) resetPasswordController(ctx context.Context, requestBody []byte) error {
+	request, err := unmarshalRequestData[models.PasswordResetRequest](requestBody)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if request.Token == "" {
+		return fmt.Errorf("%w: token is required", errBadRequest)
+	}
+
+	// Checked before the token is used up, so the user can pick another password with the same link.
+	if err := validator.ValidateUserPassword(request.Password); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	token, err := a.dbController.UsePasswordResetToken(ctx, hashPasswordResetToken(request.Token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return fmt.Errorf("%w: the link is invalid, has expired or has already been used", errBadRequest)
+	}
+
+	user, err := a.dbController.GetUserByID(ctx, token.Owner)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("%w: the link is no longer valid", errBadRequest)
+	}
+
+	if err := a.updatePassword(ctx, user.ID, request.Password); err != nil {
+		return err
+	}
+
+	if err := a.dbController.DeletePasswordResetTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if err := a.dbController.RevokeRefreshTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	log.Logger.Info("Password of %s has been reset, all of their sessions are revoked", user.ID)
+
+	return nil
+}
+
+// changePasswordController replaces the password of the user, who has to know the current one.
+func (a *App) changePasswordController(ctx context.Context, owner string, requestBody []byte) error {
+	request, err := unmarshalRequestData[models.ChangePasswordRequest](requestBody)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	user, err := a.dbController.GetUserByID(ctx, owner)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("%w: user doesn't exist", errNotFound)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.CurrentPassword)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return fmt.Errorf("%w: current password is wrong", errForbidden)
+		}
+		return fmt.Errorf("password validation failed, %v", err)
+	}
+
+	if err := validator.ValidateUserPassword(request.NewPassword); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	return a.updatePassword(ctx, user.ID, request.NewPassword)
+}
+
+func (a *App) updatePassword(ctx context.Context, userID string, password string) error {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt password, %v", err)
+	}
+
+	return a.dbController.UpdatePassword(ctx, userID, string(passwordHash))
+}
+
+func (a *App) ForgotPasswordRoute(ctx *fiber.Ctx) error {
+	if err := a.forgotPasswordController(ctx.Context(), ctx.Body()); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+func (a *App) ResetPasswordRoute(ctx *fiber.Ctx) error {
+	if err := a.resetPasswordController(ctx.Context(), ctx.Body()); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (a *App) ChangePasswordRoute(ctx *fiber.Ctx) error {
+	if err := a.changePasswordController(ctx.Context(), callerOf(ctx), ctx.Body()); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// resetTokenOf returns the token from the link in the password reset email.
+func resetTokenOf(t *testing.T, email fakeEmail) string {
+	t.Helper()
+
+	match := resetTokenPattern.FindStringSubmatch(email.body)
+	if match == nil {
+		t.Fatalf("no link in the email %q", email.body)
+	}
+	return match[1]
+}
+
+// addResetToken stores a reset token of the user which expires at expiresAt.
+func (a *testApp) addResetToken(t *testing.T, user *models.UserData, token string, expiresAt time.Time) {
+	t.Helper()
+
+	err := a.db.CreatePasswordResetToken(context.Background(), &models.PasswordResetToken{
+		ID:        hashPasswordResetToken(token),
+		Owner:     user.ID,
+		CreatedAt: expiresAt.Add(-passwordResetTTL),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	app := newTestApp(t)
+	app.addUser(t, "ada@example.com", "Secret-password-1", true)
+
+	forgot := func(email string) {
+		t.Helper()
+		resp := app.request(t, http.MethodPost, "/forgot-password", "", map[string]string{"email": email})
+		if resp.StatusCode != fiber.StatusAccepted {
+			t.Fatalf("expected %d for %s, got %d", fiber.StatusAccepted, email, resp.StatusCode)
+		}
+	}
+
+	forgot("nobody@example.com")
+	if sent := app.emails.sent(); len(sent) != 0 {
+		t.Errorf("expected nothing to be sent to an unknown address, got %d emails", len(sent))
+	}
+
+	forgot("ada@example.com")
+	forgot("ada@example.com")
+	if sent := app.emails.sent(); len(sent) != 1 {
+		t.Errorf("expected the second email to be throttled, got %d emails", len(sent))
+	}
+	if count := len(app.db.passwordResetTokens); count != 1 {
+		t.Errorf("expected a single token to be stored, got %d", count)
+	}
+}
+
+func TestForgotPasswordSendFailure(t *testing.T) {
+	app := newTestApp(t)
+	app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	app.emails.err = errors.New("mailbox unavailable")
+
+	resp := app.request(t, http.MethodPost, "/forgot-password", "", map[string]string{"email": "ada@example.com"})
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Errorf("expected a failed email not to tell the account exists, got %d", resp.StatusCode)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	ctx := context.Background()
+
+	if _, _, err := app.issueTokens(ctx, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	// A link sent earlier, which must not outlive the reset.
+	app.addResetToken(t, user, "earlier-link", time.Now().Add(passwordResetTTL/2))
+
+	app.request(t, http.MethodPost, "/forgot-password", "", map[string]string{"email": user.Email})
+	token := resetTokenOf(t, app.emails.sent()[0])
+
+	reset := func(token string, password string) int {
+		t.Helper()
+		resp := app.request(t, http.MethodPost, "/reset-password", "", map[string]string{"token": token, "password": password})
+		return resp.StatusCode
+	}
+
+	if status := reset(token, "New-password-2"); status != fiber.StatusOK {
+		t.Fatalf("expected the password to be reset, got %d", status)
+	}
+	stored, _ := app.db.GetUserByID(ctx, user.ID)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("New-password-2")) != nil {
+		t.Errorf("expected the new password to be stored")
+	}
+
+	if status := reset(token, "Another-password-3"); status != fiber.StatusBadRequest {
+		t.Errorf("expected the token to be accepted only once, got %d", status)
+	}
+	if status := reset("earlier-link", "Another-password-3"); status != fiber.StatusBadRequest {
+		t.Errorf("expected the other links to be invalidated, got %d", status)
+	}
+
+	for _, token := range app.db.refreshTokens {
+		if !token.Revoked {
+			t.Errorf("expected the sessions of the user to be revoked, %s is not", token.ID)
+		}
+	}
+}
+
+func TestResetPasswordExpired(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	app.addResetToken(t, user, "expired-link", time.Now().Add(-time.Minute))
+
+	resp := app.request(t, http.MethodPost, "/reset-password", "", map[string]string{"token": "expired-link", "password": "New-password-2"})
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("expected an expired token to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	accessToken := app.accessToken(t, user)
+
+	resp := app.request(t, http.MethodPost, "/protected/change-password", accessToken, map[string]string{
+		"current_password": "Wrong-password-1",
+		"new_password":     "New-password-2",
+	})
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("expected a wrong current password to be rejected with %d, got %d", fiber.StatusForbidden, resp.StatusCode)
+	}
+
+	resp = app.request(t, http.MethodPost, "/protected/change-password", accessToken, map[string]string{
+		"current_password": "Secret-password-1",
+		"new_password":     "New-password-2",
+	})
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("expected the password to be changed, got %d", resp.StatusCode)
+	}
+}
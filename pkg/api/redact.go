@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/redact"
+)
+
+// newRedactor creates the redactor of personal data if REDACT_PII=true.
+// REDACT_DETECTORS is a comma-separated list of the detectors to run: email, iban, card and phone,
+// all of them by default. Returns nil if redaction is disabled.
+func newRedactor() (*redact.Redactor, error) {
+	if os.Getenv("REDACT_PII") != "true" {
+		return nil, nil
+	}
+
+	names := redact.DefaultDetectors
+	if value, set := os.LookupEnv("REDACT_DETECTORS"); set && value != "" {
+		names = strings.Split(value, ",")
+	}
+
+	detectors := make([]redact.Detector, 0, len(names))
+	for _, name := range names {
+		newDetector, ok := redact.Detectors[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown pii detector %q", name)
+		}
+		detectors = append(detectors, newDetector())
+	}
+
+	return redact.New(detectors...)
+}
+
+// redactRequest returns a copy of the request with the personal data in the messages replaced with placeholders.
+// The request itself keeps the original values, so they can be stored in the conversation.
+func redactRequest(session *redact.Session, request *models.OpenAIChatRequest) *models.OpenAIChatRequest {
+	if session == nil {
+		return request
+	}
+
+	redacted := *request
+	redacted.Messages = make([]models.OpenAIMessage, len(request.Messages))
+	for i, message := range request.Messages {
+		message.Content = session.Redact(message.Content)
+		if len(message.ToolCalls) > 0 {
+			message.ToolCalls = append([]models.OpenAIToolCall(nil), message.ToolCalls...)
+			for j := range message.ToolCalls {
+				message.ToolCalls[j].Function.Arguments = session.Redact(message.ToolCalls[j].Function.Arguments)
+			}
+		}
+		redacted.Messages[i] = message
+	}
+
+	return &redacted
+}
+
+// restoreMessage puts the original values in place of the placeholders the model answered with,
+// including the arguments of the tools it calls.
+func restoreMessage(session *redact.Session, message *models.OpenAIMessage) {
+	message.Content = session.Restore(message.Content)
+	for i := range message.ToolCalls {
+		message.ToolCalls[i].Function.Arguments = session.Restore(message.ToolCalls[i].Function.Arguments)
+	}
+}
+
+// redactTexts replaces the personal data in the texts sent to be embedded, if redaction is enabled.
+// The placeholders never come back, so every text is redacted on its own.
+func (a *App) redactTexts(texts []string) []string {
+	if a.redactor == nil {
+		return texts
+	}
+
+	redacted := make([]string, len(texts))
+	for i, text := range texts {
+		redacted[i] = a.redactor.NewSession().Redact(text)
+	}
+	return redacted
+}
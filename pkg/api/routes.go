@@ -3,12 +3,13 @@ package api
 import (
 	"bytes"
 	"context"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/auth"
 )
 
 // TODO: There should be a clear separation between routes and
@@ -28,88 +29,116 @@ import (
 // Authorization: Bearer <token>
 
 func (a *App) OpenAIRoute(ctx *fiber.Ctx) error {
-	result, err := a.openaiController(ctx.Context(), bytes.Clone(ctx.Body()))
+	answer, err := a.openaiController(ctx.Context(), callerOf(ctx), bytes.Clone(ctx.Body()))
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return openaiHTTPError(ctx, err)
 	}
 
-	return ctx.JSON(map[string]string{
-		"openai": result.Choices[0].Message.Content,
-	}, "application/json")
+	return sendAnswer(ctx, answer)
 }
 
-func (a *App) RefreshTokensRoute(ctx *fiber.Ctx) error {
-	refreshToken := ctx.Cookies(a.auth.CookieName)
-	if refreshToken == "" {
-		return fiber.NewError(fiber.StatusInternalServerError, "cookie is not set")
-	}
-
-	// TODO: This whole token validation process should be moved into a separate function,
-	// inside an auth package.
-
-	// NOTE: We should refresh the token a bit before it will be expired,
-	// not after, the only problem is how to do that on the cline side.
-
-	// If the signature check passes we could trust the signed data.
-	claims := models.Claims{}
-	token, err := jwt.ParseWithClaims(refreshToken, &claims, func(token *jwt.Token) (interface{}, error) {
-		// NOTE: This might not work out.
-		// tokenClaims := token.Claims.(*models.Claims)
-		// Verify the signing method
-		// return a single secret we trust
-		return []byte(a.auth.JwtSecret), nil
-	})
+// sendAnswer responds with the answer of the model in the format of OpenAIRoute.
+func sendAnswer(ctx *fiber.Ctx, answer *chatAnswer) error {
+	ctx.Set("X-Cache", answer.CacheStatus)
 
-	if err != nil {
-		// TODO: Include error message?
-		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	response := map[string]any{
+		"openai": answer.Choices[0].Message.Content,
 	}
-
-	_ = token
-
-	// This should never fail if we refresh the token a little bit before it expires.
-	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Time) {
-		return fiber.NewError(fiber.StatusUnauthorized, "token has expired")
+	// A structured answer is returned as a json document rather than a string.
+	if answer.Object != nil {
+		response["openai"] = answer.Object
+	}
+	if answer.Citations != nil {
+		response["citations"] = answer.Citations
+	}
+	if answer.Usage != nil {
+		response["usage"] = answer.Usage
 	}
 
-	// NOTE: claims.Subject will contain a user ID (but in our case for now it's an email address),
-	// We should retrive that email address and make a lookup in a database, whether such user
-	// exists, and what's more important the token is not expired.
-
-	// This should probably be a use ID.
-	userEmail := claims.Subject
-
-	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	return ctx.JSON(response, "application/json")
+}
 
-	user, err := a.dbController.GetUserByEmail(dbCtx, userEmail)
+// OpenAIStreamRoute forwards the completion to the client as server-sent events,
+// one `data: {"openai": "<delta>"}` event per token chunk, followed by `data: [DONE]`.
+// Every tool call made by the model is reported with a `tool` event,
+// `{"name": "<tool>", "arguments": "<json>", "result": "<json>"}`.
+// The tokens used to answer the question are reported with a `usage` event before `[DONE]`,
+// followed by a `citations` event with the sources the answer refers to if the client asked for retrieval.
+// Failures that happen after the stream has started are reported with an `error` event,
+// which carries the status code the error would have been reported with otherwise.
+// Streamed answers are never cached.
+func (a *App) OpenAIStreamRoute(ctx *fiber.Ctx) error {
+	query, err := unmarshalRequestData[models.OpenAIRequest](bytes.Clone(ctx.Body()))
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-
-	if user == nil {
-		return fiber.NewError(fiber.StatusUnauthorized, "unknown user")
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	tokenPair, err := a.auth.GetTokens(user.Email)
+	turn, err := a.prepareTurn(ctx.Context(), callerOf(ctx), query, true)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		return openaiHTTPError(ctx, err)
 	}
 
-	// Create a cookie with a new refresh token and set it.
-	cookie := a.auth.GetCookie(tokenPair.RefreshToken)
+	ctx.Set("X-Cache", cacheBypass)
+	streamSSE(ctx, func(streamCtx context.Context, sse *sseWriter) error {
+		var content strings.Builder
+		usage, err := a.openaiStreamController(streamCtx, turn, func(delta string) error {
+			content.WriteString(delta)
+			return sse.Event("", map[string]string{
+				"openai": delta,
+			})
+		}, func(call models.OpenAIToolCall, result string) error {
+			return sse.Event("tool", map[string]string{
+				"name":      call.Function.Name,
+				"arguments": call.Function.Arguments,
+				"result":    result,
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if err := sse.Event("usage", usage); err != nil {
+			return err
+		}
+		if query.Retrieval != nil {
+			if err := sse.Event("citations", citations(content.String(), turn.sources)); err != nil {
+				return err
+			}
+		}
+		return sse.Done()
+	})
+
+	return nil
+}
 
-	// Set an actual cookie
+// setRefreshCookie sets the cookie with the refresh token, javascript won't have access to it in a web-browser.
+func setRefreshCookie(ctx *fiber.Ctx, cookie *auth.Cookie) {
 	ctx.Cookie(&fiber.Cookie{
 		Name:     cookie.Name,
 		Value:    cookie.Value,
 		Path:     cookie.Path,
 		Expires:  cookie.Expires,
 		MaxAge:   cookie.MaxAge,
-		HTTPOnly: true, // javascript won't have access to this cookie in a web-browser
+		HTTPOnly: true,
 		Secure:   true,
 		SameSite: fiber.CookieSameSiteStrictMode,
 	})
+}
+
+func (a *App) RefreshTokensRoute(ctx *fiber.Ctx) error {
+	refreshToken := ctx.Cookies(a.auth.CookieName)
+	if refreshToken == "" {
+		return fiber.NewError(fiber.StatusUnauthorized, "cookie is not set")
+	}
+
+	// NOTE: We should refresh the token a bit before it will be expired,
+	// not after, the only problem is how to do that on the cline side.
+
+	tokenPair, cookie, err := a.refreshTokenController(ctx.Context(), refreshToken)
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	setRefreshCookie(ctx, cookie)
 
 	return ctx.JSON(tokenPair, "application/json")
 }
@@ -117,27 +146,24 @@ func (a *App) RefreshTokensRoute(ctx *fiber.Ctx) error {
 func (a *App) LoginRoute(ctx *fiber.Ctx) error {
 	tokens, cookie, err := a.loginController(ctx.Context(), ctx.Body())
 	if err != nil {
-		// NOTE: Currently we don't have a way to distinguish between different error codes.
+		// NOTE: Currently we don't have a way to distinguish between most of the error codes.
 		// Because it can either be an authorization error, or a server internal error.
 		// Unauthorized(401) status should be returned when user specifies invalid username (email) or password.
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		return httpError(err, fiber.StatusInternalServerError)
 	}
 
-	ctx.Cookie(&fiber.Cookie{
-		Name:     cookie.Name,
-		Value:    cookie.Value,
-		Path:     cookie.Path,
-		Expires:  cookie.Expires,
-		MaxAge:   cookie.MaxAge,
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: fiber.CookieSameSiteStrictMode,
-	})
+	setRefreshCookie(ctx, cookie)
 
 	return ctx.JSON(tokens, "application/json")
 }
 
+// LogoutRoute revokes the refresh token on the server before the cookie is cleared,
+// the cookie is kept if that fails, so the client can try again.
 func (a *App) LogoutRoute(ctx *fiber.Ctx) error {
+	if err := a.logoutController(ctx.Context(), ctx.Cookies(a.auth.CookieName)); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
 	ctx.Cookie(&fiber.Cookie{
 		Name:     a.auth.CookieName,
 		Value:    "",
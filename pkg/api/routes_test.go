@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestStreamRejectsSchemaOfAssistant(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	app.db.CreateAssistant(ctx, &models.Assistant{
+		ID:        "structured",
+		Owner:     user.ID,
+		Name:      "Structured",
+		Params:    models.OpenAIParams{ResponseSchema: json.RawMessage(`{"type":"object"}`)},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	app.db.CreateConversation(ctx, &models.Conversation{
+		ID:          "conversation",
+		Owner:       user.ID,
+		Title:       "Structured",
+		AssistantID: "structured",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+
+	resp := app.request(t, http.MethodPost, "/protected/openai/stream", app.accessToken(t, user), map[string]any{
+		"openai-question": "Hello",
+		"conversation_id": "conversation",
+	})
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("expected the schema of the assistant to be rejected, got status %d", resp.StatusCode)
+	}
+	if count := app.provider.requestCount(); count != 0 {
+		t.Errorf("expected nothing to be sent upstream, got %d requests", count)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchLength    = 256
+)
+
+// parseSearchTime accepts either a timestamp or a date.
+// A date in the end of the range includes the whole day.
+func parseSearchTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a RFC 3339 timestamp or a date, got %q", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// The cursor is opaque to the clients, it's the position of the last hit of the page.
+func encodeSearchCursor(hit *models.MessageSearchHit) string {
+	data, _ := json.Marshal(&models.MessageSearchCursor{Rank: hit.Rank, CreatedAt: hit.CreatedAt, MessageID: hit.MessageID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(value string) (*models.MessageSearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor models.MessageSearchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.MessageID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// parseSearchQuery builds a search from the query parameters of the request:
+// q is the text to search for, from, to, model and role filter the messages,
+// limit is the size of a page, and cursor continues the search from the previous page.
+func parseSearchQuery(params map[string]string) (*models.MessageSearchQuery, error) {
+	query := &models.MessageSearchQuery{
+		Text:  strings.TrimSpace(params["q"]),
+		Model: params["model"],
+		Role:  params["role"],
+		Limit: defaultSearchLimit,
+	}
+
+	if query.Text == "" {
+		return nil, fmt.Errorf("q is required")
+	}
+	if utf8.RuneCountInString(query.Text) > maxSearchLength {
+		return nil, fmt.Errorf("q is longer than %d characters", maxSearchLength)
+	}
+
+	switch query.Role {
+	case "", "user", "assistant", "tool":
+	default:
+		return nil, fmt.Errorf("unknown role %q", query.Role)
+	}
+
+	var err error
+	if value := params["from"]; value != "" {
+		if query.From, err = parseSearchTime(value, false); err != nil {
+			return nil, fmt.Errorf("from: %v", err)
+		}
+	}
+	if value := params["to"]; value != "" {
+		if query.To, err = parseSearchTime(value, true); err != nil {
+			return nil, fmt.Errorf("to: %v", err)
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, fmt.Errorf("from has to be before to")
+	}
+
+	if value := params["limit"]; value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return nil, fmt.Errorf("limit has to be a number between 1 and %d", maxSearchLimit)
+		}
+		query.Limit = limit
+	}
+
+	if value := params["cursor"]; value != "" {
+		if query.After, err = decodeSearchCursor(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
+
+func (a *App) searchController(ctx context.Context, owner string, params map[string]string) (*models.MessageSearchResponse, error) {
+	query, err := parseSearchQuery(params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	// One more hit than asked for tells whether there is a next page.
+	limit := query.Limit
+	query.Limit++
+
+	hits, err := a.dbController.SearchMessages(ctx, owner, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.MessageSearchResponse{Hits: hits}
+	if len(hits) > limit {
+		response.Hits = hits[:limit]
+		response.NextCursor = encodeSearchCursor(&response.Hits[limit-1])
+	}
+	if response.Hits == nil {
+		response.Hits = []models.MessageSearchHit{}
+	}
+
+	return response, nil
+}
+
+func (a *App) SearchConversationsRoute(ctx *fiber.Ctx) error {
+	response, err := a.searchController(ctx.Context(), callerOf(ctx), ctx.Queries())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(response, "application/json")
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	query, err := parseSearchQuery(map[string]string{"q": " helm ", "from": "2024-11-01", "to": "2024-11-30", "limit": "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Text != "helm" || query.Limit != 5 {
+		t.Errorf("unexpected query %+v", query)
+	}
+	if !query.From.Equal(time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)) || !query.To.Equal(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the range to include the last day, got %v - %v", query.From, query.To)
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"q": "helm", "limit": "1000"},
+		{"q": "helm", "role": "system"},
+		{"q": "helm", "from": "yesterday"},
+		{"q": "helm", "from": "2024-11-02", "to": "2024-11-01"},
+		{"q": "helm", "cursor": "not a cursor"},
+	} {
+		if _, err := parseSearchQuery(params); err == nil {
+			t.Errorf("expected %v to be rejected", params)
+		}
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	hit := &models.MessageSearchHit{MessageID: "m1", Rank: float64(float32(0.1)), CreatedAt: time.Now().UTC()}
+
+	cursor, err := decodeSearchCursor(encodeSearchCursor(hit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.MessageID != hit.MessageID || cursor.Rank != hit.Rank || !cursor.CreatedAt.Equal(hit.CreatedAt) {
+		t.Errorf("cursor changed on the round trip: %+v", cursor)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// How often a comment line is written to an idle stream.
+// Writing is the only way to find out that the client went away,
+// since fasthttp doesn't notify the handler when the connection is closed.
+const sseHeartbeatInterval = 15 * time.Second
+
+// sseWriter serializes writes of server-sent events coming from
+// the stream handler and the heartbeat goroutine.
+type sseWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (s *sseWriter) write(payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.WriteString(payload); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// Event writes a single event with a json encoded data field.
+// An empty event name produces a default `message` event.
+func (s *sseWriter) Event(event string, data interface{}) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %v", err)
+	}
+
+	if event == "" {
+		return s.write(fmt.Sprintf("data: %s\n\n", bytes))
+	}
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, bytes))
+}
+
+func (s *sseWriter) Done() error {
+	return s.write("data: [DONE]\n\n")
+}
+
+// streamSSE switches the response into server-sent events mode and runs handler
+// once fiber starts writing the response body.
+// The context passed to the handler is cancelled as soon as a write fails,
+// which happens when the client disconnects.
+func streamSSE(ctx *fiber.Ctx, handler func(ctx context.Context, sse *sseWriter) error) {
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	// Disables response buffering when running behind nginx.
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		streamCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sse := &sseWriter{w: w}
+
+		go func() {
+			ticker := time.NewTicker(sseHeartbeatInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-streamCtx.Done():
+					return
+				case <-ticker.C:
+					if err := sse.write(": keep-alive\n\n"); err != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		if err := handler(streamCtx, sse); err != nil {
+			// The error can only be delivered if the client is still connected.
+			sse.Event("error", map[string]interface{}{
+				"error":  err.Error(),
+				"status": errorStatus(err, fiber.StatusInternalServerError),
+			})
+		}
+	})
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/auth"
+	"github.com/isnastish/openai/pkg/prompt"
+)
+
+const (
+	maxTemplateNameLength        = 256
+	maxTemplateDescriptionLength = 2048
+)
+
+// loadOrganizations reads the email domains which stand for organisations, users who share a domain
+// from the list belong to the same organisation. Other domains, public email providers in particular,
+// don't make an organisation, so the users of those can only keep private templates.
+func loadOrganizations() map[string]bool {
+	organizations := make(map[string]bool)
+
+	domains, set := os.LookupEnv("ORGANIZATION_DOMAINS")
+	if !set || domains == "" {
+		return organizations
+	}
+
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			organizations[domain] = true
+		}
+	}
+
+	return organizations
+}
+
+// organizationOf returns the organisation of the user with the email, empty if the user has none.
+func (a *App) organizationOf(email string) string {
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return ""
+	}
+	domain = strings.ToLower(domain)
+	if !a.organizations[domain] {
+		return ""
+	}
+	return domain
+}
+
+// organizationOfCaller returns the organisation of the user who made a request to a protected route.
+func (a *App) organizationOfCaller(ctx *fiber.Ctx) string {
+	return a.organizationOf(auth.GetClaims(ctx).Email)
+}
+
+// applyTemplateRequest validates the fields of the request and sets the ones which are present on the template.
+func applyTemplateRequest(template *models.PromptTemplate, request *models.PromptTemplateRequest, organization string) error {
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		if utf8.RuneCountInString(name) > maxTemplateNameLength {
+			return fmt.Errorf("name is longer than %d characters", maxTemplateNameLength)
+		}
+		template.Name = name
+	}
+
+	if request.Description != nil {
+		description := strings.TrimSpace(*request.Description)
+		if utf8.RuneCountInString(description) > maxTemplateDescriptionLength {
+			return fmt.Errorf("description is longer than %d characters", maxTemplateDescriptionLength)
+		}
+		template.Description = description
+	}
+
+	if request.Visibility != nil {
+		switch *request.Visibility {
+		case models.TemplatePrivate:
+		case models.TemplateOrganization:
+			if organization == "" {
+				return fmt.Errorf("you don't belong to an organisation the template could be shared with")
+			}
+		default:
+			return fmt.Errorf("visibility must be either %s or %s", models.TemplatePrivate, models.TemplateOrganization)
+		}
+		template.Visibility = *request.Visibility
+	}
+
+	// Follows the owner, who may have been added to or removed from an organisation since.
+	template.Organization = organization
+
+	return nil
+}
+
+func newTemplateVersion(templateID string, number int, content string, owner string) (*models.PromptTemplateVersion, error) {
+	parsed, err := prompt.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid template, %v", errBadRequest, err)
+	}
+
+	return &models.PromptTemplateVersion{
+		TemplateID: templateID,
+		Version:    number,
+		Content:    content,
+		Variables:  parsed.Variables(),
+		CreatedBy:  owner,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+func (a *App) createTemplateController(ctx context.Context, owner string, organization string, requestBody []byte) (*models.PromptTemplate, error) {
+	request, err := unmarshalRequestData[models.PromptTemplateRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	if request.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", errBadRequest)
+	}
+	template := &models.PromptTemplate{
+		ID:            uuid.NewString(),
+		Owner:         owner,
+		Visibility:    models.TemplatePrivate,
+		LatestVersion: 1,
+	}
+	if err := applyTemplateRequest(template, request, organization); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	version, err := newTemplateVersion(template.ID, 1, request.Content, owner)
+	if err != nil {
+		return nil, err
+	}
+	template.CreatedAt = version.CreatedAt
+	template.UpdatedAt = version.CreatedAt
+
+	if err := a.dbController.CreatePromptTemplate(ctx, template, version); err != nil {
+		return nil, err
+	}
+	template.Versions = []models.PromptTemplateVersion{*version}
+
+	return template, nil
+}
+
+// visibleTemplate returns the template if the user can see it, and fails with errNotFound otherwise.
+func (a *App) visibleTemplate(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error) {
+	template, err := a.dbController.GetPromptTemplate(ctx, owner, organization, id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, fmt.Errorf("%w: template %s", errNotFound, id)
+	}
+	return template, nil
+}
+
+// ownTemplate returns the template if the user can modify it.
+// A template shared with the user is reported as forbidden rather than not found.
+func (a *App) ownTemplate(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error) {
+	template, err := a.visibleTemplate(ctx, owner, organization, id)
+	if err != nil {
+		return nil, err
+	}
+	if template.Owner != owner {
+		return nil, fmt.Errorf("%w: only the owner can modify template %s", errForbidden, id)
+	}
+	return template, nil
+}
+
+// getTemplateController returns the template together with all its versions.
+func (a *App) getTemplateController(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error) {
+	template, err := a.visibleTemplate(ctx, owner, organization, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := a.dbController.ListPromptTemplateVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	template.Versions = versions
+
+	return template, nil
+}
+
+func (a *App) updateTemplateController(ctx context.Context, owner string, organization string, id string, requestBody []byte) (*models.PromptTemplate, error) {
+	request, err := unmarshalRequestData[models.PromptTemplateRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if request.Content != "" {
+		return nil, fmt.Errorf("%w: versions can't be modified, add a new version instead", errBadRequest)
+	}
+
+	template, err := a.ownTemplate(ctx, owner, organization, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyTemplateRequest(template, request, organization); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	// The template can't stay shared with an organisation the owner has left.
+	if template.Organization == "" {
+		template.Visibility = models.TemplatePrivate
+	}
+	template.UpdatedAt = time.Now().UTC()
+
+	if err := a.dbController.UpdatePromptTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (a *App) deleteTemplateController(ctx context.Context, owner string, organization string, id string) error {
+	if _, err := a.ownTemplate(ctx, owner, organization, id); err != nil {
+		return err
+	}
+
+	return a.dbController.DeletePromptTemplate(ctx, owner, id)
+}
+
+func (a *App) addTemplateVersionController(ctx context.Context, owner string, organization string, id string, requestBody []byte) (*models.PromptTemplateVersion, error) {
+	request, err := unmarshalRequestData[models.PromptTemplateVersionRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	template, err := a.ownTemplate(ctx, owner, organization, id)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := newTemplateVersion(id, template.LatestVersion+1, request.Content, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.dbController.AddPromptTemplateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// templateVersion returns the version of a template visible to the user, the latest one if number is zero.
+func (a *App) templateVersion(ctx context.Context, owner string, organization string, id string, number int) (*models.PromptTemplateVersion, error) {
+	template, err := a.visibleTemplate(ctx, owner, organization, id)
+	if err != nil {
+		return nil, err
+	}
+	if number == 0 {
+		number = template.LatestVersion
+	}
+
+	version, err := a.dbController.GetPromptTemplateVersion(ctx, id, number)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, fmt.Errorf("%w: version %d of template %s", errNotFound, number, id)
+	}
+
+	return version, nil
+}
+
+// runTemplateController renders the template with the variables and asks the model the rendered prompt,
+// exactly as if the user asked it with the rest of the parameters of the request.
+func (a *App) runTemplateController(ctx context.Context, owner string, organization string, id string, requestBody []byte) (*chatAnswer, error) {
+	request, err := unmarshalRequestData[models.PromptTemplateRunRequest](requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if request.OpenaiQuestion != "" {
+		return nil, fmt.Errorf("%w: the question is rendered from the template, openai-question must be omitted", errBadRequest)
+	}
+	if request.Version < 0 {
+		return nil, fmt.Errorf("%w: invalid version %d", errBadRequest, request.Version)
+	}
+
+	version, err := a.templateVersion(ctx, owner, organization, id, request.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := prompt.Parse(version.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version %d of template %s, %v", version.Version, id, err)
+	}
+
+	rendered, err := parsed.Render(request.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to render the template, %v", errBadRequest, err)
+	}
+
+	query := &request.OpenAIRequest
+	query.OpenaiQuestion = rendered
+
+	return a.answerQuestion(ctx, owner, query)
+}
+
+func (a *App) CreateTemplateRoute(ctx *fiber.Ctx) error {
+	template, err := a.createTemplateController(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(template, "application/json")
+}
+
+func (a *App) ListTemplatesRoute(ctx *fiber.Ctx) error {
+	templates, err := a.dbController.ListPromptTemplates(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(templates, "application/json")
+}
+
+func (a *App) GetTemplateRoute(ctx *fiber.Ctx) error {
+	template, err := a.getTemplateController(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Params("id"))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(template, "application/json")
+}
+
+func (a *App) UpdateTemplateRoute(ctx *fiber.Ctx) error {
+	template, err := a.updateTemplateController(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Params("id"), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(template, "application/json")
+}
+
+func (a *App) DeleteTemplateRoute(ctx *fiber.Ctx) error {
+	if err := a.deleteTemplateController(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Params("id")); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (a *App) AddTemplateVersionRoute(ctx *fiber.Ctx) error {
+	version, err := a.addTemplateVersionController(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Params("id"), ctx.Body())
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(version, "application/json")
+}
+
+func (a *App) GetTemplateVersionRoute(ctx *fiber.Ctx) error {
+	number, err := strconv.Atoi(ctx.Params("version"))
+	if err != nil || number < 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "version must be a positive number")
+	}
+
+	version, err := a.templateVersion(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Params("id"), number)
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(version, "application/json")
+}
+
+// RunTemplateRoute responds in the same format as OpenAIRoute.
+func (a *App) RunTemplateRoute(ctx *fiber.Ctx) error {
+	answer, err := a.runTemplateController(ctx.Context(), callerOf(ctx), a.organizationOfCaller(ctx), ctx.Params("id"), bytes.Clone(ctx.Body()))
+	if err != nil {
+		return openaiHTTPError(ctx, err)
+	}
+
+	return sendAnswer(ctx, answer)
+}
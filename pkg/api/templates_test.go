@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestOrganizationOf(t *testing.T) {
+	a := &App{organizations: map[string]bool{"example.com": true}}
+
+	if organization := a.organizationOf("alice@Example.com"); organization != "example.com" {
+		t.Errorf("expected example.com, got %q", organization)
+	}
+	if organization := a.organizationOf("bob@gmail.com"); organization != "" {
+		t.Errorf("expected a domain which isn't listed not to make an organisation, got %q", organization)
+	}
+}
+
+func TestApplyTemplateRequest(t *testing.T) {
+	name := "  Translate  "
+	visibility := models.TemplateOrganization
+
+	template := &models.PromptTemplate{Visibility: models.TemplatePrivate}
+	if err := applyTemplateRequest(template, &models.PromptTemplateRequest{Name: &name, Visibility: &visibility}, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if template.Name != "Translate" || template.Visibility != models.TemplateOrganization || template.Organization != "example.com" {
+		t.Errorf("unexpected template %+v", template)
+	}
+
+	if err := applyTemplateRequest(&models.PromptTemplate{}, &models.PromptTemplateRequest{Visibility: &visibility}, ""); err == nil {
+		t.Errorf("expected a template of a user without an organisation not to be shared")
+	}
+
+	empty := " "
+	if err := applyTemplateRequest(&models.PromptTemplate{}, &models.PromptTemplateRequest{Name: &empty}, ""); err == nil {
+		t.Errorf("expected an empty name to be rejected")
+	}
+}
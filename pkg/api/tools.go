@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// How many times in a row the model can ask for tools to be called before it has to answer.
+// Each round is a separate request to the model.
+const maxToolRounds = 5
+
+// toolDefinitions returns the tools the model is allowed to call during the request.
+// All of them are offered unless the client picked a subset, or the model doesn't support tools.
+func (a *App) toolDefinitions(params *models.OpenAIParams) ([]models.OpenAITool, error) {
+	names := params.Tools
+	if names == nil {
+		if !a.llmProvider.Catalog().SupportsTools {
+			return nil, nil
+		}
+		names = a.tools.Names()
+	}
+
+	definitions, err := a.tools.Definitions(names)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	return definitions, nil
+}
+
+// callTools runs the calls requested by the model and returns the tool messages with the results,
+// onResult is invoked after every call and may be nil.
+func (a *App) callTools(ctx context.Context, calls []models.OpenAIToolCall, onResult func(call models.OpenAIToolCall, result string) error) ([]models.OpenAIMessage, error) {
+	results := make([]models.OpenAIMessage, 0, len(calls))
+	for _, call := range calls {
+		result := a.tools.Call(ctx, call)
+		if onResult != nil {
+			if err := onResult(call, result); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, models.OpenAIMessage{
+			Role:       "tool",
+			Content:    result,
+			ToolCallID: call.ID,
+		})
+	}
+	return results, nil
+}
+
+// mergeToolCallDeltas assembles the tool calls out of the fragments received in stream chunks.
+// The first fragment of a call carries its id and name, the following ones pieces of its arguments.
+func mergeToolCallDeltas(calls []models.OpenAIToolCall, deltas []models.OpenAIToolCall) []models.OpenAIToolCall {
+	for _, delta := range deltas {
+		index := len(calls)
+		// Calls are streamed one after another, a gap in the indices would be a bug of the upstream.
+		if delta.Index != nil && *delta.Index >= 0 && *delta.Index <= len(calls) {
+			index = *delta.Index
+		}
+		if index == len(calls) {
+			calls = append(calls, models.OpenAIToolCall{Type: "function"})
+		}
+
+		call := &calls[index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return calls
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestMergeToolCallDeltas(t *testing.T) {
+	index := func(i int) *int { return &i }
+
+	chunks := [][]models.OpenAIToolCall{
+		{{Index: index(0), ID: "call_1", Type: "function", Function: models.OpenAIFunctionCall{Name: "calculator"}}},
+		{{Index: index(0), Function: models.OpenAIFunctionCall{Arguments: `{"expression":`}}},
+		{{Index: index(1), ID: "call_2", Function: models.OpenAIFunctionCall{Name: "current_time", Arguments: "{}"}}},
+		{{Index: index(0), Function: models.OpenAIFunctionCall{Arguments: `"6*7"}`}}},
+		// Indices beyond the next call are not trusted.
+		{{Index: index(1000), ID: "call_3", Function: models.OpenAIFunctionCall{Name: "user_profile"}}},
+	}
+
+	var calls []models.OpenAIToolCall
+	for _, chunk := range chunks {
+		calls = mergeToolCallDeltas(calls, chunk)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 calls, got: %+v", calls)
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Name != "calculator" || calls[0].Function.Arguments != `{"expression":"6*7"}` {
+		t.Errorf("unexpected first call: %+v", calls[0])
+	}
+	if calls[1].ID != "call_2" || calls[1].Type != "function" || calls[1].Function.Arguments != "{}" {
+		t.Errorf("unexpected second call: %+v", calls[1])
+	}
+	if calls[2].ID != "call_3" || calls[2].Index != nil {
+		t.Errorf("unexpected third call: %+v", calls[2])
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// quotas limit how much every user may spend on the llm provider,
+// a zero value leaves the period unlimited.
+type quotas struct {
+	dailyTokens   int64
+	monthlyTokens int64
+	dailyCost     float64
+	monthlyCost   float64
+}
+
+func (q *quotas) enabled() bool {
+	return q.dailyTokens > 0 || q.monthlyTokens > 0 || q.dailyCost > 0 || q.monthlyCost > 0
+}
+
+// loadQuotas reads the quotas from QUOTA_DAILY_TOKENS, QUOTA_MONTHLY_TOKENS,
+// QUOTA_DAILY_COST and QUOTA_MONTHLY_COST, the costs are in US dollars.
+func loadQuotas() (*quotas, error) {
+	q := &quotas{}
+
+	for name, value := range map[string]*int64{
+		"QUOTA_DAILY_TOKENS":   &q.dailyTokens,
+		"QUOTA_MONTHLY_TOKENS": &q.monthlyTokens,
+	} {
+		env, set := os.LookupEnv(name)
+		if !set || env == "" {
+			continue
+		}
+		limit, err := strconv.ParseInt(env, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative integer", name)
+		}
+		*value = limit
+	}
+
+	for name, value := range map[string]*float64{
+		"QUOTA_DAILY_COST":   &q.dailyCost,
+		"QUOTA_MONTHLY_COST": &q.monthlyCost,
+	} {
+		env, set := os.LookupEnv(name)
+		if !set || env == "" {
+			continue
+		}
+		limit, err := strconv.ParseFloat(env, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number", name)
+		}
+		*value = limit
+	}
+
+	return q, nil
+}
+
+// quotaExceededError tells the user which quota is used up and when it's renewed.
+type quotaExceededError struct {
+	period  string
+	resetAt time.Time
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota is exceeded, it resets at %s", e.period, e.resetAt.Format(time.RFC3339))
+}
+
+func (e *quotaExceededError) Unwrap() error {
+	return errQuotaExceeded
+}
+
+// usagePeriods returns the starts of the current day and month, in UTC.
+func usagePeriods(now time.Time) (dayStart time.Time, monthStart time.Time) {
+	now = now.UTC()
+	dayStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return dayStart, monthStart
+}
+
+// exceeded reports whether the usage of the period reached one of its quotas.
+func exceeded(period *models.UsagePeriod) bool {
+	if period.TokenQuota > 0 && int64(period.TotalTokens) >= period.TokenQuota {
+		return true
+	}
+	return period.CostQuota > 0 && period.Cost >= period.CostQuota
+}
+
+func (a *App) periodUsage(ctx context.Context, owner string, from time.Time, to time.Time) (*models.UsagePeriod, error) {
+	usage, err := a.dbController.GetUsage(ctx, owner, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	period := &models.UsagePeriod{
+		From:   from,
+		To:     to,
+		Models: usage,
+	}
+	for _, modelUsage := range usage {
+		period.PromptTokens += modelUsage.PromptTokens
+		period.CompletionTokens += modelUsage.CompletionTokens
+		period.Cost += modelUsage.Cost
+	}
+	period.TotalTokens = period.PromptTokens + period.CompletionTokens
+
+	return period, nil
+}
+
+func (a *App) usageController(ctx context.Context, owner string) (*models.Usage, error) {
+	dayStart, monthStart := usagePeriods(time.Now())
+
+	daily, err := a.periodUsage(ctx, owner, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	daily.TokenQuota, daily.CostQuota = a.quotas.dailyTokens, a.quotas.dailyCost
+
+	monthly, err := a.periodUsage(ctx, owner, monthStart, monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	monthly.TokenQuota, monthly.CostQuota = a.quotas.monthlyTokens, a.quotas.monthlyCost
+
+	return &models.Usage{
+		Daily:   *daily,
+		Monthly: *monthly,
+	}, nil
+}
+
+// checkQuota is called before every request to the llm provider made on behalf of the owner.
+// The request which crosses the quota is still let through, the following ones are rejected.
+func (a *App) checkQuota(ctx context.Context, owner string) error {
+	if !a.quotas.enabled() {
+		return nil
+	}
+
+	usage, err := a.usageController(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	// The monthly quota is checked first, it takes longer to reset.
+	if exceeded(&usage.Monthly) {
+		return &quotaExceededError{period: "monthly", resetAt: usage.Monthly.To}
+	}
+	if exceeded(&usage.Daily) {
+		return &quotaExceededError{period: "daily", resetAt: usage.Daily.To}
+	}
+
+	return nil
+}
+
+// turnUsage accumulates the usage of the requests made to answer a question. The router may serve a request
+// by another model than the one asked for, the turn is charged for the model which answered the last of them.
+type turnUsage struct {
+	models.OpenAIUsage
+	model string
+}
+
+// add adds the usage of a request answered by the model, an empty model leaves the previous one.
+func (u *turnUsage) add(model string, usage *models.OpenAIUsage) {
+	if model != "" {
+		u.model = model
+	}
+	addUsage(&u.OpenAIUsage, usage)
+}
+
+// recordUsage stores the tokens used by a request of the owner together with their cost.
+// The answer has already been paid for, so failing to record it is logged rather than reported to the user.
+func (a *App) recordUsage(ctx context.Context, owner string, kind string, model string, usage *models.OpenAIUsage) {
+	if usage == nil {
+		return
+	}
+
+	catalog := a.llmProvider.Catalog()
+	model = catalog.Resolve(model)
+
+	record := &models.UsageRecord{
+		ID:               uuid.NewString(),
+		Owner:            owner,
+		Kind:             kind,
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             catalog.Cost(model, usage.PromptTokens, usage.CompletionTokens),
+		CreatedAt:        time.Now().UTC(),
+	}
+
+	// The client may be gone by now, which shouldn't prevent the usage from being recorded.
+	if err := a.dbController.AddUsage(context.WithoutCancel(ctx), record); err != nil {
+		log.Logger.Error("failed to record usage of %s: %v", owner, err)
+	}
+}
+
+// UsageRoute returns the tokens used by the user and their cost over the current day and month,
+// together with the quotas of these periods.
+func (a *App) UsageRoute(ctx *fiber.Ctx) error {
+	usage, err := a.usageController(ctx.Context(), callerOf(ctx))
+	if err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.JSON(usage, "application/json")
+}
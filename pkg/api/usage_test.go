@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+func TestUsagePeriods(t *testing.T) {
+	now := time.Date(2024, time.December, 31, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+
+	dayStart, monthStart := usagePeriods(now)
+	if expected := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC); !dayStart.Equal(expected) {
+		t.Errorf("expected day to start at %v, got %v", expected, dayStart)
+	}
+	if expected := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC); !monthStart.Equal(expected) {
+		t.Errorf("expected month to start at %v, got %v", expected, monthStart)
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   models.UsagePeriod
+		expected bool
+	}{
+		{"unlimited", models.UsagePeriod{TotalTokens: 1000, Cost: 10}, false},
+		{"below token quota", models.UsagePeriod{TotalTokens: 99, TokenQuota: 100}, false},
+		{"token quota reached", models.UsagePeriod{TotalTokens: 100, TokenQuota: 100}, true},
+		{"below cost quota", models.UsagePeriod{Cost: 0.5, CostQuota: 1}, false},
+		{"cost quota reached", models.UsagePeriod{TotalTokens: 10, Cost: 1.5, TokenQuota: 100, CostQuota: 1}, true},
+	}
+
+	for _, test := range tests {
+		if actual := exceeded(&test.period); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestAbortedStreamUsageIsRecorded(t *testing.T) {
+	app := newTestApp(t)
+	app.provider.answer = "A long answer the client never waits for"
+	app.provider.setBlock(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	turn, err := app.prepareTurn(ctx, "ada", &models.OpenAIRequest{OpenaiQuestion: "Tell me everything"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		<-app.provider.started
+		cancel()
+	}()
+
+	if _, err := app.openaiStreamController(ctx, turn, func(string) error { return nil }, nil); err == nil {
+		t.Fatalf("expected the cancelled stream to fail")
+	}
+
+	if len(app.db.usage) != 1 {
+		t.Fatalf("expected the usage of the cancelled stream to be recorded, got %d records", len(app.db.usage))
+	}
+	if record := app.db.usage[0]; record.Owner != "ada" || record.PromptTokens == 0 || record.CompletionTokens == 0 {
+		t.Errorf("expected the streamed tokens to be estimated, got %+v", record)
+	}
+}
+
+func TestDisconnectedStreamUsageIsRecorded(t *testing.T) {
+	app := newTestApp(t)
+	app.provider.usage = &models.OpenAIUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}
+
+	turn, err := app.prepareTurn(context.Background(), "ada", &models.OpenAIRequest{OpenaiQuestion: "Hello"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disconnected := errors.New("client disconnected")
+	_, err = app.openaiStreamController(context.Background(), turn, func(string) error { return disconnected }, nil)
+	if !errors.Is(err, disconnected) {
+		t.Fatalf("expected the stream to be aborted, got %v", err)
+	}
+
+	if len(app.db.usage) != 1 || app.db.usage[0].CompletionTokens == 0 {
+		t.Errorf("expected the streamed tokens to be recorded, got %+v", app.db.usage)
+	}
+}
+
+func TestUsageIsChargedToModelWhichAnswered(t *testing.T) {
+	app := newTestApp(t)
+	app.provider.catalog.Models["gpt-4o"] = llm.ModelLimits{ContextWindow: 128000, MaxOutputTokens: 16384}
+	app.provider.catalog.Pricing["gpt-4o"] = llm.ModelPricing{InputPerMillion: 2.5, OutputPerMillion: 10}
+	// The snapshot of another model than the one asked for answers.
+	app.provider.model = "gpt-4o-2024-08-06"
+	app.provider.usage = &models.OpenAIUsage{PromptTokens: 1000000, CompletionTokens: 100000, TotalTokens: 1100000}
+
+	ctx := context.Background()
+	query := &models.OpenAIRequest{OpenaiQuestion: "Hi", OpenAIParams: models.OpenAIParams{Model: testModel}}
+	if _, err := app.answerQuestion(ctx, "ada", query); err != nil {
+		t.Fatal(err)
+	}
+
+	turn, err := app.prepareTurn(ctx, "ada", query, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.openaiStreamController(ctx, turn, func(string) error { return nil }, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(app.db.usage) != 2 {
+		t.Fatalf("expected the usage of both questions to be recorded, got %d records", len(app.db.usage))
+	}
+	for _, record := range app.db.usage {
+		if record.Model != "gpt-4o" || record.Cost != 3.5 {
+			t.Errorf("expected the usage to be charged to gpt-4o, got %s for %v", record.Model, record.Cost)
+		}
+	}
+}
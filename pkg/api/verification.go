@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/auth"
+)
+
+// A verification email is sent at most once per interval to the same user.
+const verificationEmailInterval = time.Minute
+
+// sendVerificationEmail sends the user a link which verifies their email address.
+// The caller is responsible for the throttling.
+func (a *App) sendVerificationEmail(ctx context.Context, user *models.UserData) error {
+	token, err := a.auth.IssueEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(a.accountEmails.verificationLink, token)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening the link below, it expires in %v.\n\n%s\n",
+		user.FirstName, a.auth.EmailVerificationTTL, link)
+
+	return a.sendEmail(ctx, user, "Verify your email address", body)
+}
+
+// verifyEmailController marks the email address of the user the link was sent to as verified.
+// Opening the link again does nothing.
+func (a *App) verifyEmailController(ctx context.Context, token string) error {
+	claims, err := a.auth.ParseEmailVerificationToken(token)
+	if err != nil {
+		return fmt.Errorf("%w: the link is invalid or has expired, %v", errBadRequest, err)
+	}
+
+	user, err := a.dbController.GetUserByID(ctx, claims.Subject)
+	if err != nil {
+		return err
+	}
+	// The address has changed since the link was sent.
+	if user == nil || user.Email != claims.Email {
+		return fmt.Errorf("%w: the link is no longer valid", errBadRequest)
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	return a.dbController.MarkEmailVerified(ctx, user.ID)
+}
+
+// resendVerificationController sends another verification email, unless the previous one was sent
+// less than verificationEmailInterval ago. Nothing is sent to the users who don't exist or have already verified their address.
+func (a *App) resendVerificationController(ctx context.Context, requestBody []byte) error {
+	request, err := unmarshalRequestData[models.UserData](requestBody)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if request.Email == "" {
+		return fmt.Errorf("%w: email is required", errBadRequest)
+	}
+
+	user, err := a.dbController.GetUserByEmail(ctx, request.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerified {
+		return nil
+	}
+
+	recorded, err := a.dbController.RecordVerificationEmail(ctx, user.ID, time.Now().UTC(), verificationEmailInterval)
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		return fmt.Errorf("%w: a verification email has been sent recently, try again later", errTooManyRequests)
+	}
+
+	return a.sendVerificationEmail(ctx, user)
+}
+
+func (a *App) VerifyEmailRoute(ctx *fiber.Ctx) error {
+	if err := a.verifyEmailController(ctx.Context(), ctx.Query("token")); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (a *App) ResendVerificationEmailRoute(ctx *fiber.Ctx) error {
+	if err := a.resendVerificationController(ctx.Context(), ctx.Body()); err != nil {
+		if errorStatus(err, fiber.StatusInternalServerError) == fiber.StatusTooManyRequests {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(verificationEmailInterval.Seconds())))
+		}
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+// VerifiedEmailMiddleware rejects the requests of the users who haven't verified their email address,
+// when the verification is required. It runs after the access token has been validated.
+func (a *App) VerifiedEmailMiddleware(ctx *fiber.Ctx) error {
+	if a.accountEmails.requireVerification && !auth.GetClaims(ctx).EmailVerified {
+		return fiber.NewError(fiber.StatusForbidden, "email address is not verified")
+	}
+
+	return ctx.Next()
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	app := newTestApp(t)
+	unverified := app.addUser(t, "ada@example.com", "Secret-password-1", false)
+	verified := app.addUser(t, "grace@example.com", "Secret-password-1", true)
+
+	tokenOf := func(userID string, email string) string {
+		token, err := app.auth.IssueEmailVerificationToken(userID, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{name: "invalid token", token: "not-a-token", expected: fiber.StatusBadRequest},
+		// The address has changed since the link was sent.
+		{name: "email mismatch", token: tokenOf(unverified.ID, "old@example.com"), expected: fiber.StatusBadRequest},
+		{name: "unknown user", token: tokenOf("id-nobody@example.com", "nobody@example.com"), expected: fiber.StatusBadRequest},
+		{name: "already verified", token: tokenOf(verified.ID, verified.Email), expected: fiber.StatusOK},
+		{name: "unverified", token: tokenOf(unverified.ID, unverified.Email), expected: fiber.StatusOK},
+		// Opening the link again does nothing.
+		{name: "opened again", token: tokenOf(unverified.ID, unverified.Email), expected: fiber.StatusOK},
+	}
+	for _, test := range tests {
+		resp := app.request(t, http.MethodGet, "/verify-email?token="+url.QueryEscape(test.token), "", nil)
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, resp.StatusCode)
+		}
+	}
+
+	if user, _ := app.db.GetUserByID(context.Background(), unverified.ID); !user.EmailVerified {
+		t.Errorf("expected the email address to be verified")
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	app := newTestApp(t)
+	app.addUser(t, "ada@example.com", "Secret-password-1", false)
+	app.addUser(t, "grace@example.com", "Secret-password-1", true)
+
+	tests := []struct {
+		email      string
+		expected   int
+		retryAfter string
+		sent       int
+	}{
+		// Nothing tells whether the address belongs to a user.
+		{email: "nobody@example.com", expected: fiber.StatusAccepted, sent: 0},
+		{email: "grace@example.com", expected: fiber.StatusAccepted, sent: 0},
+		{email: "ada@example.com", expected: fiber.StatusAccepted, sent: 1},
+		// The previous email was sent less than verificationEmailInterval ago.
+		{email: "ada@example.com", expected: fiber.StatusTooManyRequests, retryAfter: strconv.Itoa(int(verificationEmailInterval.Seconds())), sent: 1},
+	}
+	for _, test := range tests {
+		resp := app.request(t, http.MethodPost, "/verify-email/resend", "", map[string]string{"email": test.email})
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.email, test.expected, resp.StatusCode)
+		}
+		if retryAfter := resp.Header.Get(fiber.HeaderRetryAfter); retryAfter != test.retryAfter {
+			t.Errorf("%s: expected Retry-After %q, got %q", test.email, test.retryAfter, retryAfter)
+		}
+		if sent := len(app.emails.sent()); sent != test.sent {
+			t.Errorf("%s: expected %d emails sent, got %d", test.email, test.sent, sent)
+		}
+	}
+}
+
+func TestVerifiedEmailMiddleware(t *testing.T) {
+	tests := []struct {
+		name                string
+		requireVerification bool
+		verified            bool
+		expected            int
+	}{
+		{name: "required, unverified", requireVerification: true, verified: false, expected: fiber.StatusForbidden},
+		{name: "required, verified", requireVerification: true, verified: true, expected: fiber.StatusOK},
+		{name: "not required, unverified", requireVerification: false, verified: false, expected: fiber.StatusOK},
+	}
+	for _, test := range tests {
+		app := newTestApp(t)
+		app.accountEmails.requireVerification = test.requireVerification
+		user := app.addUser(t, "ada@example.com", "Secret-password-1", test.verified)
+
+		resp := app.request(t, http.MethodGet, "/protected/usage", app.accessToken(t, user), nil)
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name                string
+		requireVerification bool
+		verified            bool
+		expected            int
+	}{
+		{name: "required, unverified", requireVerification: true, verified: false, expected: fiber.StatusForbidden},
+		{name: "required, verified", requireVerification: true, verified: true, expected: fiber.StatusOK},
+		{name: "not required, unverified", requireVerification: false, verified: false, expected: fiber.StatusOK},
+	}
+	for _, test := range tests {
+		app := newTestApp(t)
+		app.accountEmails.requireVerification = test.requireVerification
+		app.addUser(t, "ada@example.com", "Secret-password-1", test.verified)
+
+		resp := app.request(t, http.MethodPost, "/login", "", map[string]string{"email": "ada@example.com", "password": "Secret-password-1"})
+		if resp.StatusCode != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/auth"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// The locals the upgrade handler passes on to the socket.
+const (
+	socketOwnerLocalsKey        = "socket_owner"
+	socketConversationLocalsKey = "socket_conversation"
+	socketExpiresAtLocalsKey    = "socket_expires_at"
+)
+
+// chatSocket is a websocket bound to a single conversation.
+// At most one answer is streamed at a time, cancel aborts it together with the upstream request.
+type chatSocket struct {
+	app            *App
+	conn           *websocket.Conn
+	owner          string
+	conversationID string
+	// The socket is closed once the access token it was opened with expires.
+	expiresAt time.Time
+
+	// Serializes the writes of the read loop and of the answer being streamed.
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// Cancels the answer being streamed, nil if there is none.
+	cancel context.CancelFunc
+	// Waited for before the socket is closed.
+	answers sync.WaitGroup
+}
+
+func (s *chatSocket) send(eventType string, requestID string, fields map[string]any) error {
+	event := map[string]any{"type": eventType}
+	if requestID != "" {
+		event["request_id"] = requestID
+	}
+	for key, value := range fields {
+		event[key] = value
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.conn.WriteJSON(event)
+}
+
+func (s *chatSocket) sendError(requestID string, err error, fallback int) error {
+	return s.send("error", requestID, map[string]any{
+		"error":  err.Error(),
+		"status": errorStatus(err, fallback),
+	})
+}
+
+// prepareRegeneration prepares the last question of the conversation to be answered again.
+// The history of the turn ends before the question, and the question, the previous answer
+// and the tool calls made on the way are replaced once the new answer is stored.
+func (a *App) prepareRegeneration(ctx context.Context, owner string, query *models.OpenAIRequest) (*chatTurn, error) {
+	conversation, err := a.getConversationController(ctx, owner, query.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	last := -1
+	for i, message := range conversation.Messages {
+		if message.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("%w: the conversation has no question to answer again", errBadRequest)
+	}
+
+	regenerated := *query
+	regenerated.OpenaiQuestion = conversation.Messages[last].Content
+
+	turn, err := a.prepareTurn(ctx, owner, &regenerated, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The history loaded by prepareTurn holds the same messages as the conversation.
+	turn.history = turn.history[:last]
+	for _, message := range conversation.Messages[last:] {
+		turn.replaces = append(turn.replaces, message.ID)
+	}
+
+	return turn, nil
+}
+
+// answer streams the answer to a send or regenerate message in the background.
+func (s *chatSocket) answer(request *models.ChatSocketRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		s.sendError(request.RequestID, errors.New("an answer is already being streamed, cancel it first"), fiber.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.answers.Add(1)
+
+	go func() {
+		defer s.answers.Done()
+		defer func() {
+			s.mu.Lock()
+			s.cancel = nil
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		err := s.stream(ctx, request)
+		switch {
+		case err == nil:
+		// Providers don't necessarily wrap the error of the cancelled context, the context itself tells.
+		case ctx.Err() != nil:
+			s.send("cancelled", request.RequestID, nil)
+		default:
+			s.sendError(request.RequestID, err, fiber.StatusInternalServerError)
+		}
+	}()
+}
+
+func (s *chatSocket) stream(ctx context.Context, request *models.ChatSocketRequest) error {
+	query := &request.OpenAIRequest
+	query.ConversationID = s.conversationID
+
+	var turn *chatTurn
+	var err error
+	if request.Type == models.ChatSocketRegenerate {
+		turn, err = s.app.prepareRegeneration(ctx, s.owner, query)
+	} else {
+		turn, err = s.app.prepareTurn(ctx, s.owner, query, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	usage, err := s.app.openaiStreamController(ctx, turn, func(delta string) error {
+		content.WriteString(delta)
+		return s.send("delta", request.RequestID, map[string]any{
+			"content": delta,
+		})
+	}, func(call models.OpenAIToolCall, result string) error {
+		return s.send("tool", request.RequestID, map[string]any{
+			"name":      call.Function.Name,
+			"arguments": call.Function.Arguments,
+			"result":    result,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.send("usage", request.RequestID, map[string]any{"usage": usage}); err != nil {
+		return err
+	}
+	if query.Retrieval != nil {
+		if err := s.send("citations", request.RequestID, map[string]any{
+			"citations": citations(content.String(), turn.sources),
+		}); err != nil {
+			return err
+		}
+	}
+	return s.send("done", request.RequestID, nil)
+}
+
+func (s *chatSocket) cancelAnswer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// ChatSocketUpgradeRoute checks the conversation before the connection is upgraded to a websocket,
+// so the errors can still be reported with a proper status code.
+// The access token is validated by the middleware guarding the protected routes, like for any other request.
+func (a *App) ChatSocketUpgradeRoute(ctx *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(ctx) {
+		return fiber.NewError(fiber.StatusUpgradeRequired, "websocket upgrade is required")
+	}
+
+	owner := callerOf(ctx)
+	conversationID := ctx.Params("id")
+	if _, err := a.getConversationController(ctx.Context(), owner, conversationID); err != nil {
+		return httpError(err, fiber.StatusInternalServerError)
+	}
+
+	ctx.Locals(socketOwnerLocalsKey, owner)
+	ctx.Locals(socketConversationLocalsKey, conversationID)
+	if expiresAt := auth.GetClaims(ctx).ExpiresAt; expiresAt != nil {
+		ctx.Locals(socketExpiresAtLocalsKey, expiresAt.Time)
+	}
+
+	return ctx.Next()
+}
+
+// ChatSocketRoute serves a websocket bound to a conversation.
+// The client sends json messages of type send, cancel and regenerate (see models.ChatSocketRequest),
+// the server answers with events of type delta, tool, usage, citations and done,
+// with cancelled once an answer is cancelled, or with error, which carries the status code
+// the error would have been reported with over http.
+func (a *App) ChatSocketRoute(conn *websocket.Conn) {
+	socket := &chatSocket{
+		app:            a,
+		conn:           conn,
+		owner:          conn.Locals(socketOwnerLocalsKey).(string),
+		conversationID: conn.Locals(socketConversationLocalsKey).(string),
+	}
+	socket.expiresAt, _ = conn.Locals(socketExpiresAtLocalsKey).(time.Time)
+
+	// The connection is closed by the middleware once the route returns, so the answer is cancelled and waited for.
+	defer socket.answers.Wait()
+	defer socket.cancelAnswer()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Logger.Warn("chat socket of %s closed: %v", socket.owner, err)
+			}
+			return
+		}
+
+		if !socket.expiresAt.IsZero() && time.Now().After(socket.expiresAt) {
+			socket.send("error", "", map[string]any{
+				"error":  "access token expired, reconnect with a new one",
+				"status": fiber.StatusUnauthorized,
+			})
+			return
+		}
+
+		request, err := unmarshalRequestData[models.ChatSocketRequest](data)
+		if err != nil {
+			socket.sendError("", fmt.Errorf("%w: %v", errBadRequest, err), fiber.StatusBadRequest)
+			continue
+		}
+
+		switch request.Type {
+		case models.ChatSocketSend, models.ChatSocketRegenerate:
+			socket.answer(request)
+		case models.ChatSocketCancel:
+			socket.cancelAnswer()
+		default:
+			socket.sendError(request.RequestID, fmt.Errorf("%w: unknown message type %q", errBadRequest, request.Type), fiber.StatusBadRequest)
+		}
+	}
+}
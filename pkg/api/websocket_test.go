@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// The events which end the answer to a message.
+var finalSocketEvents = map[string]bool{"done": true, "error": true, "cancelled": true}
+
+// listen serves the app on a random local port and returns its address.
+func (a *testApp) listen(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go a.fiberApp.Listener(listener)
+	t.Cleanup(func() {
+		a.fiberApp.Shutdown()
+	})
+
+	return listener.Addr().String()
+}
+
+// dialConversation opens the chat socket of the conversation on behalf of the user.
+func (a *testApp) dialConversation(t *testing.T, user *models.UserData, conversationID string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+a.accessToken(t, user))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+a.listen(t)+"/protected/conversations/"+conversationID+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return conn
+}
+
+func sendSocketMessage(t *testing.T, conn *websocket.Conn, message map[string]any) {
+	t.Helper()
+
+	if err := conn.WriteJSON(message); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readSocketEvents reads the events until the one which ends the answer, which is returned separately.
+func readSocketEvents(t *testing.T, conn *websocket.Conn) ([]map[string]any, map[string]any) {
+	t.Helper()
+
+	var events []map[string]any
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read event after %v: %v", events, err)
+		}
+
+		var event map[string]any
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatal(err)
+		}
+		if finalSocketEvents[event["type"].(string)] {
+			return events, event
+		}
+		events = append(events, event)
+	}
+}
+
+// addConversation adds a conversation of the user with the messages, oldest first.
+func (a *testApp) addConversation(t *testing.T, user *models.UserData, assistantID string, contents ...string) *models.Conversation {
+	t.Helper()
+
+	ctx := context.Background()
+	createdAt := time.Now().UTC().Add(-time.Hour)
+	conversation := &models.Conversation{
+		ID:          "conversation-of-" + user.ID,
+		Owner:       user.ID,
+		Title:       "Greetings",
+		AssistantID: assistantID,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+	if err := a.db.CreateConversation(ctx, conversation); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, content := range contents {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		err := a.db.AddMessage(ctx, &models.ConversationMessage{
+			ID:             conversation.ID + "-" + string(rune('a'+i)),
+			ConversationID: conversation.ID,
+			Role:           role,
+			Content:        content,
+			CreatedAt:      createdAt.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return conversation
+}
+
+func TestChatSocketSend(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	conversation := app.addConversation(t, user, "")
+	conn := app.dialConversation(t, user, conversation.ID)
+
+	sendSocketMessage(t, conn, map[string]any{"type": "send", "request_id": "r1", "openai-question": "Hi"})
+	events, final := readSocketEvents(t, conn)
+	if final["type"] != "done" || final["request_id"] != "r1" {
+		t.Fatalf("expected the answer to be done, got %v", final)
+	}
+
+	var content string
+	for _, event := range events {
+		if event["type"] == "delta" {
+			content += event["content"].(string)
+		}
+	}
+	if content != app.provider.answer {
+		t.Errorf("expected the answer to be streamed, got %q", content)
+	}
+	if last := events[len(events)-1]; last["type"] != "usage" {
+		t.Errorf("expected the usage to be reported before done, got %v", last)
+	}
+
+	messages, _ := app.db.GetMessages(context.Background(), conversation.ID)
+	if len(messages) != 2 || messages[0].Content != "Hi" || messages[1].Content != app.provider.answer {
+		t.Errorf("expected the turn to be stored, got %+v", messages)
+	}
+}
+
+func TestChatSocketCancel(t *testing.T) {
+	app := newTestApp(t)
+	app.provider.setBlock(true)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	conversation := app.addConversation(t, user, "")
+	conn := app.dialConversation(t, user, conversation.ID)
+
+	sendSocketMessage(t, conn, map[string]any{"type": "send", "request_id": "r1", "openai-question": "Hi"})
+	<-app.provider.started
+
+	// Only a single answer is streamed at a time.
+	sendSocketMessage(t, conn, map[string]any{"type": "send", "request_id": "r2", "openai-question": "Hi again"})
+	_, final := readSocketEvents(t, conn)
+	if final["type"] != "error" || final["request_id"] != "r2" || final["status"] != float64(http.StatusConflict) {
+		t.Fatalf("expected the second question to be rejected with %d, got %v", http.StatusConflict, final)
+	}
+
+	// The provider only returns once its context is cancelled.
+	sendSocketMessage(t, conn, map[string]any{"type": "cancel"})
+	_, final = readSocketEvents(t, conn)
+	if final["type"] != "cancelled" || final["request_id"] != "r1" {
+		t.Fatalf("expected the answer to be cancelled, got %v", final)
+	}
+
+	if messages, _ := app.db.GetMessages(context.Background(), conversation.ID); len(messages) != 0 {
+		t.Errorf("expected nothing to be stored for a cancelled answer, got %+v", messages)
+	}
+
+	// The socket accepts questions again once the answer is cancelled.
+	app.provider.setBlock(false)
+	sendSocketMessage(t, conn, map[string]any{"type": "send", "request_id": "r3", "openai-question": "Hi"})
+	if _, final = readSocketEvents(t, conn); final["type"] != "done" {
+		t.Errorf("expected the next question to be answered, got %v", final)
+	}
+}
+
+func TestChatSocketRegenerate(t *testing.T) {
+	app := newTestApp(t)
+	app.provider.answer = "A better answer"
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	conversation := app.addConversation(t, user, "", "First", "First answer", "Second", "Poor answer")
+	conn := app.dialConversation(t, user, conversation.ID)
+
+	sendSocketMessage(t, conn, map[string]any{"type": "regenerate", "request_id": "r1"})
+	if _, final := readSocketEvents(t, conn); final["type"] != "done" {
+		t.Fatalf("expected the answer to be regenerated, got %v", final)
+	}
+
+	request := app.provider.request(0)
+	if last := request.Messages[len(request.Messages)-1]; last.Content != "Second" || len(request.Messages) != 4 {
+		t.Errorf("expected the last question to be asked again without its answer, got %+v", request.Messages)
+	}
+
+	messages, _ := app.db.GetMessages(context.Background(), conversation.ID)
+	var contents []string
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	if len(contents) != 4 || contents[2] != "Second" || contents[3] != "A better answer" {
+		t.Errorf("expected the last turn to be replaced, got %q", contents)
+	}
+	for _, message := range messages {
+		if message.ID == conversation.ID+"-c" || message.ID == conversation.ID+"-d" {
+			t.Errorf("expected the replaced message %s to be deleted", message.ID)
+		}
+	}
+}
+
+func TestChatSocketRejectsSchemaOfAssistant(t *testing.T) {
+	app := newTestApp(t)
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	app.db.CreateAssistant(context.Background(), &models.Assistant{
+		ID:     "structured",
+		Owner:  user.ID,
+		Params: models.OpenAIParams{ResponseSchema: json.RawMessage(`{"type":"object"}`)},
+	})
+	conversation := app.addConversation(t, user, "structured")
+	conn := app.dialConversation(t, user, conversation.ID)
+
+	sendSocketMessage(t, conn, map[string]any{"type": "send", "openai-question": "Hi"})
+	if _, final := readSocketEvents(t, conn); final["type"] != "error" || final["status"] != float64(http.StatusBadRequest) {
+		t.Errorf("expected the schema of the assistant to be rejected, got %v", final)
+	}
+	if count := app.provider.requestCount(); count != 0 {
+		t.Errorf("expected nothing to be sent upstream, got %d requests", count)
+	}
+}
+
+func TestChatSocketTokenExpiry(t *testing.T) {
+	app := newTestApp(t)
+	app.auth.AccessTokenTTL = 2 * time.Second
+	user := app.addUser(t, "ada@example.com", "Secret-password-1", true)
+	conversation := app.addConversation(t, user, "")
+	conn := app.dialConversation(t, user, conversation.ID)
+
+	time.Sleep(app.auth.AccessTokenTTL + 100*time.Millisecond)
+
+	sendSocketMessage(t, conn, map[string]any{"type": "send", "openai-question": "Hi"})
+	if _, final := readSocketEvents(t, conn); final["type"] != "error" || final["status"] != float64(http.StatusUnauthorized) {
+		t.Fatalf("expected the expired token to be reported, got %v", final)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Errorf("expected the socket to be closed")
+	}
+	if count := app.provider.requestCount(); count != 0 {
+		t.Errorf("expected nothing to be sent upstream, got %d requests", count)
+	}
+}
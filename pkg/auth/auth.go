@@ -2,11 +2,13 @@ package auth
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/isnastish/openai/pkg/api/models"
 )
 
@@ -24,9 +26,19 @@ type AuthManager struct {
 	DefaultIssuer   string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
-	JwtSecret       []byte
+	// How long the links sent to verify the email addresses are valid.
+	EmailVerificationTTL time.Duration
+	// Used with HS256 when there is no signing key.
+	JwtSecret []byte
+
+	// When set, tokens are signed with the key and carry its kid,
+	// the other services can verify them with the keys published as JWKS.
+	signingKey *Key
+	// The signing key together with the keys of the previous rotations, by kid.
+	verificationKeys map[string]*Key
 }
 
+// NewAuthManager creates a manager which signs and verifies the tokens with a shared secret.
 func NewAuthManager(secret []byte, accessTokenTTL time.Duration) *AuthManager {
 	return &AuthManager{
 		CookieName:      "__host_refresh_token",
@@ -34,48 +46,165 @@ func NewAuthManager(secret []byte, accessTokenTTL time.Duration) *AuthManager {
 		AccessTokenTTL:  accessTokenTTL,
 		RefreshTokenTTL: time.Hour * 48,
 		JwtSecret:       secret,
+
+		EmailVerificationTTL: time.Hour * 24,
+	}
+}
+
+// NewAsymmetricAuthManager creates a manager which signs the tokens with the private key,
+// and accepts the tokens signed with it or with any of the verification keys.
+// To rotate the keys, the new key is added for verification everywhere before it's used for signing,
+// and the previous one is kept for verification until the tokens signed with it expire.
+func NewAsymmetricAuthManager(signingKey *Key, verificationKeys []*Key, accessTokenTTL time.Duration) (*AuthManager, error) {
+	if signingKey.Private == nil {
+		return nil, fmt.Errorf("auth: signing key %s has no private part", signingKey.ID)
 	}
+
+	a := NewAuthManager(nil, accessTokenTTL)
+	a.signingKey = signingKey
+	a.verificationKeys = map[string]*Key{signingKey.ID: signingKey}
+	for _, key := range verificationKeys {
+		a.verificationKeys[key.ID] = key
+	}
+
+	return a, nil
 }
 
-func (a *AuthManager) GetTokens(userEmail string) (*models.Tokens, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		&models.Claims{
-			Email: userEmail,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(a.AccessTokenTTL)),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				NotBefore: jwt.NewNumericDate(time.Now()),
-				Issuer:    a.DefaultIssuer,
-			},
-		})
+// JWKS returns the public keys the tokens can be verified with, none if they are signed with a shared secret.
+func (a *AuthManager) JWKS() *JWKSet {
+	set := &JWKSet{Keys: []JWK{}}
+	if a.signingKey == nil {
+		return set
+	}
 
-	signedAccessToken, err := token.SignedString(a.JwtSecret)
+	for _, key := range a.verificationKeys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	// The signing key first, then in a stable order.
+	sort.Slice(set.Keys, func(i, j int) bool {
+		if isSigning := set.Keys[i].Kid == a.signingKey.ID; isSigning != (set.Keys[j].Kid == a.signingKey.ID) {
+			return isSigning
+		}
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+// sign signs the claims with the signing key, or with the shared secret if there is none.
+func (a *AuthManager) sign(claims *models.Claims) (string, error) {
+	if a.signingKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.JwtSecret)
+	}
+
+	token := jwt.NewWithClaims(a.signingKey.Method, claims)
+	token.Header["kid"] = a.signingKey.ID
+	return token.SignedString(a.signingKey.Private)
+}
+
+// verificationKey returns the key the token has to be verified with.
+// The algorithm is fixed by the key, the one from the header is never trusted on its own,
+// a public key must not be accepted as an HMAC secret.
+func (a *AuthManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if a.signingKey == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
+		}
+		return a.JwtSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, found := a.verificationKeys[kid]
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+// GetTokens issues a pair of an access token and a refresh token for the user.
+func (a *AuthManager) GetTokens(userID string, userEmail string, emailVerified bool) (*models.Tokens, error) {
+	tokens, _, err := a.IssueTokens(userID, userEmail, emailVerified)
+	return tokens, err
+}
+
+// IssueTokens is like GetTokens, but it also returns the claims of the refresh token,
+// so the server can keep its jti and revoke it later.
+func (a *AuthManager) IssueTokens(userID string, userEmail string, emailVerified bool) (*models.Tokens, *models.Claims, error) {
+	signedAccessToken, err := a.sign(&models.Claims{
+		Email:         userEmail,
+		EmailVerified: emailVerified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(a.AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    a.DefaultIssuer,
+		},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("auth: failed to sign access token: %v", err)
-	}
-
-	// create a new refresh token with claims
-	refreshToken := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		&models.Claims{
-			RegisteredClaims: jwt.RegisteredClaims{
-				// NOTE: Supposed to be a user ID in a database
-				Subject:   userEmail,
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(a.RefreshTokenTTL)),
-			},
+		return nil, nil, fmt.Errorf("auth: failed to sign access token: %v", err)
+	}
+
+	// NOTE: The refresh token has no issuer, so it's never accepted as an access token.
+	refreshClaims := &models.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(a.RefreshTokenTTL)),
 		},
-	)
+	}
 
-	signedRefreshToken, err := refreshToken.SignedString(a.JwtSecret)
+	signedRefreshToken, err := a.sign(refreshClaims)
 	if err != nil {
-		return nil, fmt.Errorf("auth: failed to sign a refresh token: %v", err)
+		return nil, nil, fmt.Errorf("auth: failed to sign a refresh token: %v", err)
 	}
 
 	return &models.Tokens{
 		AccessToken:  signedAccessToken,
 		RefreshToken: signedRefreshToken,
-	}, nil
+	}, refreshClaims, nil
+}
+
+// The audience of the tokens in the verification links, which tells them apart from the access and refresh tokens.
+const emailVerificationAudience = "email-verification"
+
+// IssueEmailVerificationToken signs the token for the link which verifies the email address of the user.
+// It's only good for the address it was issued for, so a link sent before the address changed is of no use.
+func (a *AuthManager) IssueEmailVerificationToken(userID string, userEmail string) (string, error) {
+	signedToken, err := a.sign(&models.Claims{
+		Email: userEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(a.EmailVerificationTTL)),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("auth: failed to sign email verification token: %v", err)
+	}
+	return signedToken, nil
+}
+
+// ParseEmailVerificationToken validates the token from a verification link and returns its claims.
+func (a *AuthManager) ParseEmailVerificationToken(tokenString string) (*models.Claims, error) {
+	claims := models.Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, &claims, a.verificationKey,
+		jwt.WithAudience(emailVerificationAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("email verification token is invalid")
+	}
+	if claims.Subject == "" || claims.Email == "" {
+		return nil, fmt.Errorf("not an email verification token")
+	}
+
+	return &claims, nil
 }
 
 func (a *AuthManager) GetCookie(cookieValue string) *Cookie {
@@ -89,12 +218,14 @@ func (a *AuthManager) GetCookie(cookieValue string) *Cookie {
 }
 
 func (a *AuthManager) ValidateJwtToken(tokenString string) error {
+	_, err := a.ParseJwtToken(tokenString)
+	return err
+}
+
+// ParseJwtToken validates the token and returns its claims.
+func (a *AuthManager) ParseJwtToken(tokenString string) (*models.Claims, error) {
 	claims := models.Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
-		}
-
 		tokenClaims := token.Claims.(*models.Claims)
 		if tokenClaims.ExpiresAt == nil || time.Now().After(tokenClaims.ExpiresAt.Time) {
 			return nil, fmt.Errorf("jwt token is expired")
@@ -109,20 +240,52 @@ func (a *AuthManager) ValidateJwtToken(tokenString string) error {
 			return nil, fmt.Errorf("jwt token invalid, wrong issuer")
 		}
 
-		return []byte(a.JwtSecret), nil
+		// Tokens issued before users had IDs identify the user by the email only.
+		if tokenClaims.Subject == "" {
+			return nil, fmt.Errorf("jwt token invalid, expected a subject")
+		}
+
+		return a.verificationKey(token)
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("jwt token is invalid")
+	}
+
+	return &claims, nil
+}
+
+// ParseRefreshToken validates the refresh token and returns its claims.
+// Whether the token has been rotated or revoked is up to the caller to check.
+func (a *AuthManager) ParseRefreshToken(tokenString string) (*models.Claims, error) {
+	claims := models.Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, &claims, a.verificationKey)
+	if err != nil {
+		return nil, err
 	}
 	if !token.Valid {
-		return fmt.Errorf("jwt token is invalid")
+		return nil, fmt.Errorf("refresh token is invalid")
+	}
+
+	// Access tokens are signed with the same key, but they don't carry a jti.
+	if claims.ID == "" || claims.Subject == "" {
+		return nil, fmt.Errorf("not a refresh token")
+	}
+	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Time) {
+		return nil, fmt.Errorf("refresh token is expired")
 	}
 
-	return nil
+	return &claims, nil
 }
 
 const headerPrefix = "Bearer "
 
+// The key under which AuthorizationMiddleware stores the claims
+// of a validated access token in fiber's locals.
+const claimsLocalsKey = "claims"
+
 func getTokenFromHeader(ctx *fiber.Ctx) (*string, error) {
 	authHeaders, ok := ctx.GetReqHeaders()["Authorization"]
 	if !ok {
@@ -146,10 +309,19 @@ func (a *AuthManager) AuthorizationMiddleware(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	err = a.ValidateJwtToken(*tokenString)
+	claims, err := a.ParseJwtToken(*tokenString)
 	if err != nil {
 		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 	}
 
+	ctx.Locals(claimsLocalsKey, claims)
+
 	return ctx.Next()
 }
+
+// GetClaims returns the claims of the access token that was used to authorize the request.
+// Only valid for the routes guarded by AuthorizationMiddleware, returns nil otherwise.
+func GetClaims(ctx *fiber.Ctx) *models.Claims {
+	claims, _ := ctx.Locals(claimsLocalsKey).(*models.Claims)
+	return claims
+}
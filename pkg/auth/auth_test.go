@@ -8,12 +8,13 @@ import (
 
 const secret = "my-secret-secret"
 const email = "admin@gmail.com"
+const userID = "5f0c8a3e-2d7b-4c1e-9a6f-3b8d2e1c4a70"
 
 func TestJwtTokenExpired(t *testing.T) {
 	accessTokenTTL := time.Minute * 1
 	m := NewAuthManager([]byte(secret), accessTokenTTL)
 
-	tokenPair, err := m.GetTokens(email)
+	tokenPair, err := m.GetTokens(userID, email, true)
 	if err != nil {
 		t.Errorf("failed to get token pair %v", err)
 	}
@@ -30,7 +31,7 @@ func TestJwtTokenExpired(t *testing.T) {
 func TestSuccessfullAccessTokenValidation(t *testing.T) {
 	m := NewAuthManager([]byte(secret), time.Second*30) // 30 seconds TTL
 
-	tokenPair, err := m.GetTokens(email)
+	tokenPair, err := m.GetTokens(userID, email, true)
 	if err != nil {
 		t.Errorf("failed to get token pair %v", err)
 	}
@@ -42,3 +43,93 @@ func TestSuccessfullAccessTokenValidation(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRefreshTokenParsing(t *testing.T) {
+	m := NewAuthManager([]byte(secret), time.Minute)
+
+	tokens, refreshClaims, err := m.IssueTokens(userID, email, true)
+	if err != nil {
+		t.Fatalf("failed to issue tokens %v", err)
+	}
+
+	claims, err := m.ParseRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID == "" || claims.ID != refreshClaims.ID || claims.Subject != userID {
+		t.Errorf("unexpected refresh token claims %+v", claims)
+	}
+
+	if _, err := m.ParseRefreshToken(tokens.AccessToken); err == nil {
+		t.Errorf("expected an access token not to be accepted as a refresh token")
+	}
+	if err := m.ValidateJwtToken(tokens.RefreshToken); err == nil {
+		t.Errorf("expected a refresh token not to be accepted as an access token")
+	}
+
+	other, _, _ := m.IssueTokens(userID, email, true)
+	if otherClaims, _ := m.ParseRefreshToken(other.RefreshToken); otherClaims.ID == claims.ID {
+		t.Errorf("expected every refresh token to have its own jti")
+	}
+}
+
+func TestAccessTokenSubject(t *testing.T) {
+	m := NewAuthManager([]byte(secret), time.Minute)
+
+	tokens, err := m.GetTokens(userID, email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.ParseJwtToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != userID || claims.Email != email {
+		t.Errorf("unexpected access token claims %+v", claims)
+	}
+
+	// Issued before users had IDs.
+	legacy, err := m.GetTokens("", email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ValidateJwtToken(legacy.AccessToken); err == nil {
+		t.Errorf("expected an access token without a subject to be rejected")
+	}
+}
+
+func TestEmailVerificationToken(t *testing.T) {
+	m := NewAuthManager([]byte(secret), time.Minute)
+
+	token, err := m.IssueEmailVerificationToken(userID, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.ParseEmailVerificationToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != userID || claims.Email != email {
+		t.Errorf("unexpected email verification token claims %+v", claims)
+	}
+
+	if err := m.ValidateJwtToken(token); err == nil {
+		t.Errorf("expected an email verification token not to be accepted as an access token")
+	}
+	if _, err := m.ParseRefreshToken(token); err == nil {
+		t.Errorf("expected an email verification token not to be accepted as a refresh token")
+	}
+
+	tokens, _ := m.GetTokens(userID, email, false)
+	for _, other := range []string{tokens.AccessToken, tokens.RefreshToken} {
+		if _, err := m.ParseEmailVerificationToken(other); err == nil {
+			t.Errorf("expected %s not to be accepted as an email verification token", other)
+		}
+	}
+
+	m.EmailVerificationTTL = -time.Minute
+	expired, _ := m.IssueEmailVerificationToken(userID, email)
+	if _, err := m.ParseEmailVerificationToken(expired); err == nil {
+		t.Errorf("expected an expired email verification token to be rejected")
+	}
+}
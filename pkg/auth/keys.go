@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Shorter RSA keys can be factored in practice.
+const minRSAKeyBits = 2048
+
+// Key is an asymmetric key tokens are signed or verified with.
+// Only the signing key has the private part, the keys of the previous rotations are kept
+// for verification until the tokens signed with them expire.
+type Key struct {
+	// The kid header of the tokens signed with the key, the thumbprint of the public key.
+	ID     string
+	Method jwt.SigningMethod
+	// Nil for the keys which are only used for verification.
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// JWK is the public part of a key in the format of RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKey reads an RSA or an Ed25519 key from a PEM file.
+// A private key in PKCS #8 or PKCS #1 form can be used for signing,
+// a public key in PKIX form only for verification.
+func LoadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to read key, %v", err)
+	}
+
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("auth: invalid key %s, %v", path, err)
+	}
+
+	return key, nil
+}
+
+// ParseKey parses the first PEM block of the data as a key.
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch parsed := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private = parsed
+		key.Public = &parsed.PublicKey
+	case ed25519.PrivateKey:
+		key.Private = parsed
+		key.Public = parsed.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		key.Public = parsed
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 keys are supported", parsed)
+	}
+
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits long, got %d", minRSAKeyBits, public.N.BitLen())
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	}
+
+	key.ID = thumbprint(key.JWK())
+
+	return key, nil
+}
+
+// JWK returns the public part of the key.
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
+
+// thumbprint computes the thumbprint of the key as defined by RFC 7638,
+// so the same key always gets the same kid, whichever server loaded it.
+func thumbprint(jwk JWK) string {
+	// The required members only, in lexicographic order.
+	var members any
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, _ := json.Marshal(members)
+	hash := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func encodePrivateKey(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func encodePublicKey(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func newEd25519Key(t *testing.T) *Key {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(encodePrivateKey(t, private))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParseKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := ParseKey(encodePrivateKey(t, rsaKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private.Method != jwt.SigningMethodRS256 || private.Private == nil {
+		t.Errorf("expected an RS256 signing key, got %+v", private)
+	}
+
+	public, err := ParseKey(encodePublicKey(t, &rsaKey.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Private != nil || public.ID != private.ID {
+		t.Errorf("expected the public key to have the same kid and no private part, got %s and %s", public.ID, private.ID)
+	}
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	if key, err := ParseKey(pkcs1); err != nil || key.ID != private.ID {
+		t.Errorf("expected a PKCS #1 key to be accepted, %v", err)
+	}
+
+	shortKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := ParseKey(encodePrivateKey(t, shortKey)); err == nil {
+		t.Errorf("expected a 1024 bits RSA key to be rejected")
+	}
+
+	if _, err := ParseKey([]byte("not a key")); err == nil {
+		t.Errorf("expected data without a PEM block to be rejected")
+	}
+}
+
+func TestAsymmetricTokens(t *testing.T) {
+	oldKey := newEd25519Key(t)
+	newKey := newEd25519Key(t)
+
+	old, err := NewAsymmetricAuthManager(oldKey, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldTokens, err := old.GetTokens(userID, email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(oldTokens.AccessToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Header["kid"] != oldKey.ID || token.Header["alg"] != "EdDSA" {
+		t.Errorf("unexpected header %v", token.Header)
+	}
+
+	// The old key is kept for verification after the rotation.
+	verificationKey := &Key{ID: oldKey.ID, Method: oldKey.Method, Public: oldKey.Public}
+	rotated, err := NewAsymmetricAuthManager(newKey, []*Key{verificationKey}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rotated.ValidateJwtToken(oldTokens.AccessToken); err != nil {
+		t.Errorf("expected a token signed with the previous key to be accepted, %v", err)
+	}
+	if _, err := rotated.ParseRefreshToken(oldTokens.RefreshToken); err != nil {
+		t.Errorf("expected a refresh token signed with the previous key to be accepted, %v", err)
+	}
+
+	newTokens, _ := rotated.GetTokens(userID, email, true)
+	if err := old.ValidateJwtToken(newTokens.AccessToken); err == nil {
+		t.Errorf("expected a token signed with an unknown key to be rejected")
+	}
+
+	jwks := rotated.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != newKey.ID || jwks.Keys[0].Crv != "Ed25519" || jwks.Keys[0].X == "" {
+		t.Errorf("expected both keys to be published, the signing key first, got %+v", jwks.Keys)
+	}
+
+	if _, err := NewAsymmetricAuthManager(verificationKey, nil, time.Minute); err == nil {
+		t.Errorf("expected a public key not to be accepted as a signing key")
+	}
+}
+
+func TestAsymmetricTokensRejectHMAC(t *testing.T) {
+	key := newEd25519Key(t)
+	m, err := NewAsymmetricAuthManager(key, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token signed with a secret must not pass even with the kid of a known key.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"email": email,
+		"iss":   m.DefaultIssuer,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString([]byte(key.Public.(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ValidateJwtToken(signed); err == nil {
+		t.Errorf("expected an HS256 token to be rejected")
+	}
+
+	if len(NewAuthManager([]byte(secret), time.Minute).JWKS().Keys) != 0 {
+		t.Errorf("expected no keys to be published when tokens are signed with a secret")
+	}
+}
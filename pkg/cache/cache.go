@@ -0,0 +1,58 @@
+// Package cache stores the answers to deterministic chat completion requests,
+// so sending the same prompt again doesn't cost anything.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Bumped whenever the format of the keys or of the cached responses changes.
+const keyVersion = "v1"
+
+// Cache maps the keys returned by Key to serialized responses.
+// Entries expire after the ttl the cache was created with.
+type Cache interface {
+	// Returns false if the key is missing or has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Close() error
+}
+
+// Cacheable reports whether the request is deterministic enough for its answer to be reused,
+// which is only the case for requests made with the temperature explicitly set to 0.
+func Cacheable(request *models.OpenAIChatRequest) bool {
+	return request.Temperature != nil && *request.Temperature == 0
+}
+
+// Key returns a hash of everything in the request that affects the answer.
+// Requests which differ only in the formatting of the json documents they carry,
+// or in whether the answer is streamed, have the same key.
+func Key(request *models.OpenAIChatRequest) (string, error) {
+	keyed := *request
+	keyed.Stream = false
+	keyed.StreamOptions = nil
+
+	data, err := json.Marshal(&keyed)
+	if err != nil {
+		return "", fmt.Errorf("cache: failed to marshal request, error: %v", err)
+	}
+
+	// Round-tripping through a generic value sorts the keys of the objects
+	// and strips the whitespace of the raw json schemas.
+	var canonical any
+	if err := json.Unmarshal(data, &canonical); err != nil {
+		return "", fmt.Errorf("cache: failed to unmarshal request, error: %v", err)
+	}
+	if data, err = json.Marshal(canonical); err != nil {
+		return "", fmt.Errorf("cache: failed to marshal request, error: %v", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return "chat:" + keyVersion + ":" + hex.EncodeToString(hash[:]), nil
+}
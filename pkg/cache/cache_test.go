@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func newRequest(parameters string) *models.OpenAIChatRequest {
+	temperature := 0.0
+	return &models.OpenAIChatRequest{
+		Model:       "gpt-4o-mini",
+		Messages:    []models.OpenAIMessage{{Role: "user", Content: "hello"}},
+		Temperature: &temperature,
+		Tools: []models.OpenAITool{{
+			Type: "function",
+			Function: models.OpenAIFunctionDefinition{
+				Name:       "calculator",
+				Parameters: json.RawMessage(parameters),
+			},
+		}},
+	}
+}
+
+func TestKey(t *testing.T) {
+	key, err := Key(newRequest(`{"type":"object","required":["expression"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same := newRequest(`{ "required": [ "expression" ], "type": "object" }`)
+	same.Stream = true
+	same.StreamOptions = &models.OpenAIStreamOptions{IncludeUsage: true}
+	if sameKey, _ := Key(same); sameKey != key {
+		t.Errorf("expected requests which differ only in formatting and streaming to have the same key")
+	}
+
+	otherModel := newRequest(`{"type":"object","required":["expression"]}`)
+	otherModel.Model = "gpt-4o"
+	otherMessages := newRequest(`{"type":"object","required":["expression"]}`)
+	otherMessages.Messages[0].Content = "hello!"
+	for _, other := range []*models.OpenAIChatRequest{otherModel, otherMessages} {
+		if otherKey, _ := Key(other); otherKey == key {
+			t.Errorf("expected different requests to have different keys")
+		}
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	request := newRequest(`{}`)
+	if !Cacheable(request) {
+		t.Errorf("expected a request with temperature 0 to be cacheable")
+	}
+
+	request.Temperature = nil
+	if Cacheable(request) {
+		t.Errorf("expected a request with the default temperature not to be cacheable")
+	}
+}
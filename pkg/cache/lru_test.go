@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRU(2, 1024, time.Minute)
+
+	cache.Set(ctx, "a", []byte("1"))
+	cache.Set(ctx, "b", []byte("2"))
+	// Makes b the least recently used entry.
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	cache.Set(ctx, "c", []byte("3"))
+
+	if _, ok, _ := cache.Get(ctx, "b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := cache.Get(ctx, key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+}
+
+func TestLRUMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRU(10, 8, time.Minute)
+
+	cache.Set(ctx, "a", []byte("1234"))
+	cache.Set(ctx, "b", []byte("5678"))
+	cache.Set(ctx, "c", []byte("9"))
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Errorf("expected a to be evicted")
+	}
+
+	cache.Set(ctx, "d", []byte("too large"))
+	if _, ok, _ := cache.Get(ctx, "d"); ok {
+		t.Errorf("expected a value larger than the cache not to be cached")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestLRUExpiration(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRU(10, 1024, time.Minute)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	cache.Set(ctx, "a", []byte("1"))
+
+	now = now.Add(59 * time.Second)
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Errorf("expected a to be cached")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Errorf("expected a to expire")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected the expired entry to be removed")
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keeps the cache in Redis, or any server speaking its protocol, so it's shared
+// between the replicas of the server and survives restarts.
+// Entries expire on the server, the size of the cache is bounded by its maxmemory policy.
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+	prefix string
+}
+
+// NewRedis connects to the server at REDIS_URL, for example redis://localhost:6379/0.
+func NewRedis(ctx context.Context, ttl time.Duration) (*Redis, error) {
+	redisURL, set := os.LookupEnv("REDIS_URL")
+	if !set || redisURL == "" {
+		return nil, fmt.Errorf("redis: REDIS_URL is not set")
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to parse url, error: %v", err)
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis: failed to connect, error: %v", err)
+	}
+
+	return &Redis{
+		client: client,
+		ttl:    ttl,
+		// Keeps the entries apart from anything else stored in the same database.
+		prefix: "openai:cache:",
+	}, nil
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis: failed to get %s, error: %v", key, err)
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte) error {
+	if err := c.client.Set(ctx, c.prefix+key, value, c.ttl).Err(); err != nil {
+		return fmt.Errorf("redis: failed to set %s, error: %v", key, err)
+	}
+	return nil
+}
+
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
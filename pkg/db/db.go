@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/isnastish/openai/pkg/api/models"
 )
@@ -15,8 +16,119 @@ import (
 
 type DatabaseController interface {
 	AddUser(ctx context.Context, userData *models.UserData, geolocation *models.Geolocation) error
+	// A user that doesn't exist is returned as nil without an error.
 	GetUserByEmail(ctx context.Context, email string) (*models.UserData, error)
-	// The subject from the claims should have user ID
-	GetUserByID(ctx context.Context, id int) (*models.UserData, error)
+	// The id is the subject of the tokens.
+	GetUserByID(ctx context.Context, id string) (*models.UserData, error)
+	// Hands the data owned by the email of a user over to the ID of the user. Before users had IDs
+	// their data was owned by their email. Runs once at startup, before any request is served.
+	MigrateOwners(ctx context.Context) error
+	MarkEmailVerified(ctx context.Context, id string) error
+	// The password is the hash of the password.
+	UpdatePassword(ctx context.Context, id string, password string) error
+	// Records that a verification email is sent to the user at sentAt,
+	// unless the previous one was sent less than interval before. Returns whether it was recorded.
+	RecordVerificationEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error)
+	// Like RecordVerificationEmail, for the emails with a link to reset the password.
+	RecordPasswordResetEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error)
+
+	// Refresh tokens are looked up by their jti, a token that doesn't exist is returned as nil without an error.
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error)
+	// Marks the token as rotated unless it has been rotated or revoked already,
+	// reports whether it was marked, so a token can only be exchanged once even by concurrent requests.
+	RotateRefreshToken(ctx context.Context, id string, rotatedAt time.Time) (bool, error)
+	// Revokes all the tokens of the family, the ones which are still valid included.
+	RevokeRefreshTokenFamily(ctx context.Context, family string) error
+	// Revokes all the refresh tokens of the user, which ends every session.
+	RevokeRefreshTokens(ctx context.Context, owner string) error
+
+	// Password reset tokens are looked up by the hash of the token.
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	// Marks the token as used, unless it has been used already or has expired by usedAt.
+	// Returns the token if it was marked and nil otherwise, so a token is only ever accepted once.
+	UsePasswordResetToken(ctx context.Context, id string, usedAt time.Time) (*models.PasswordResetToken, error)
+	// Deletes all the password reset tokens of the user, so none of the links sent so far can be used anymore.
+	DeletePasswordResetTokens(ctx context.Context, owner string) error
+
+	// Conversations are always looked up together with their owner,
+	// so one user can never read or modify a conversation of another user.
+	// A conversation that doesn't exist is returned as nil without an error.
+	CreateConversation(ctx context.Context, conversation *models.Conversation) error
+	GetConversation(ctx context.Context, owner string, id string) (*models.Conversation, error)
+	ListConversations(ctx context.Context, owner string) ([]models.Conversation, error)
+	// Deletes the conversation together with all its messages.
+	DeleteConversation(ctx context.Context, owner string, id string) error
+	// Appends a message to the conversation and bumps its update time.
+	AddMessage(ctx context.Context, message *models.ConversationMessage) error
+	// Returns the messages of a conversation, oldest first.
+	GetMessages(ctx context.Context, conversationID string) ([]models.ConversationMessage, error)
+	// Removes the messages of the conversation with the given ids, the ones which don't exist are ignored.
+	DeleteMessages(ctx context.Context, conversationID string, ids []string) error
+	// Returns at most query.Limit messages of the owner's conversations which match the text,
+	// the best ranked first, starting after query.After if it's set.
+	SearchMessages(ctx context.Context, owner string, query *models.MessageSearchQuery) ([]models.MessageSearchHit, error)
+
+	// Like conversations, assistants are always looked up together with their owner.
+	// An assistant that doesn't exist is returned as nil without an error.
+	CreateAssistant(ctx context.Context, assistant *models.Assistant) error
+	GetAssistant(ctx context.Context, owner string, id string) (*models.Assistant, error)
+	// Returns the assistants of the owner, most recently updated first.
+	ListAssistants(ctx context.Context, owner string) ([]models.Assistant, error)
+	// Updates everything but the owner and the creation time.
+	UpdateAssistant(ctx context.Context, assistant *models.Assistant) error
+	// Conversations started with the assistant are kept, they fall back to the server's defaults.
+	DeleteAssistant(ctx context.Context, owner string, id string) error
+
+	CreateDocument(ctx context.Context, document *models.Document) error
+	// Returns nil if the document doesn't exist or belongs to another user.
+	GetDocument(ctx context.Context, owner string, id string) (*models.Document, error)
+	// Returns the documents of the owner, newest first. An empty collection stands for all of them.
+	ListDocuments(ctx context.Context, owner string, collection string) ([]models.Document, error)
+	DeleteDocument(ctx context.Context, owner string, id string) error
+
+	// Templates are visible to their owner, and to the members of the owner's organisation
+	// if they are shared with it. An empty organisation doesn't match any template.
+	// A template which doesn't exist or isn't visible is returned as nil without an error.
+	// Only the owner can modify a template.
+	CreatePromptTemplate(ctx context.Context, template *models.PromptTemplate, version *models.PromptTemplateVersion) error
+	GetPromptTemplate(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error)
+	// Returns the visible templates, most recently updated first.
+	ListPromptTemplates(ctx context.Context, owner string, organization string) ([]models.PromptTemplate, error)
+	// Updates the name, the description, the visibility and the organisation of the template.
+	UpdatePromptTemplate(ctx context.Context, template *models.PromptTemplate) error
+	// Deletes the template together with all its versions.
+	DeletePromptTemplate(ctx context.Context, owner string, id string) error
+	// Adds the next version of the template and makes it the latest one.
+	// Fails if a version with the same number already exists.
+	AddPromptTemplateVersion(ctx context.Context, version *models.PromptTemplateVersion) error
+	// Returns nil if the version doesn't exist.
+	GetPromptTemplateVersion(ctx context.Context, templateID string, version int) (*models.PromptTemplateVersion, error)
+	// Returns the versions of the template, oldest first.
+	ListPromptTemplateVersions(ctx context.Context, templateID string) ([]models.PromptTemplateVersion, error)
+
+	AddUsage(ctx context.Context, record *models.UsageRecord) error
+	// Returns the usage of the owner within [from, to) summed per model, ordered by model.
+	GetUsage(ctx context.Context, owner string, from time.Time, to time.Time) ([]models.ModelUsage, error)
+
+	// Stores a prompt or an answer flagged by moderation for review.
+	AddModerationEvent(ctx context.Context, event *models.ModerationEvent) error
+
+	Close(ctx context.Context) error
+}
+
+// VectorStore keeps embeddings together with the text they were computed from.
+// Like conversations, records are always looked up together with their owner.
+type VectorStore interface {
+	// Inserts the records, replacing the ones of the same owner with the same ids.
+	Upsert(ctx context.Context, records []models.VectorRecord) error
+	// Ids which don't exist are ignored.
+	Delete(ctx context.Context, owner string, ids []string) error
+	// Returns at most k records of the owner most similar to the vector by cosine similarity,
+	// the most similar first. An empty collection stands for all the collections of the owner.
+	Query(ctx context.Context, owner string, collection string, vector []float32, k int) ([]models.VectorMatch, error)
+	// Hands the records owned by the email of a user over to the ID of the user, see DatabaseController.MigrateOwners.
+	MigrateOwners(ctx context.Context) error
+
 	Close(ctx context.Context) error
 }
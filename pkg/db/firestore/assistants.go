@@ -0,0 +1,155 @@
+package firestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// The parameters of an assistant are stored as a json string,
+// the response schema is an arbitrary json document.
+
+type firestoreAssistantWrapper struct {
+	Owner       string    `firestore:"owner"`
+	Name        string    `firestore:"name"`
+	Description string    `firestore:"description"`
+	Params      string    `firestore:"params"`
+	Collections []string  `firestore:"collections"`
+	CreatedAt   time.Time `firestore:"created_at"`
+	UpdatedAt   time.Time `firestore:"updated_at"`
+}
+
+func unwrapAssistant(doc *firestore.DocumentSnapshot) (*models.Assistant, error) {
+	var wrapped firestoreAssistantWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	assistant := &models.Assistant{
+		ID:          doc.Ref.ID,
+		Owner:       wrapped.Owner,
+		Name:        wrapped.Name,
+		Description: wrapped.Description,
+		Collections: wrapped.Collections,
+		CreatedAt:   wrapped.CreatedAt,
+		UpdatedAt:   wrapped.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(wrapped.Params), &assistant.Params); err != nil {
+		return nil, fmt.Errorf("firestore: invalid params of assistant %s, %v", doc.Ref.ID, err)
+	}
+	return assistant, nil
+}
+
+func (db *FirestoreController) CreateAssistant(ctx context.Context, assistant *models.Assistant) error {
+	params, err := json.Marshal(&assistant.Params)
+	if err != nil {
+		return fmt.Errorf("firestore: failed to marshal assistant params, %v", err)
+	}
+
+	_, err = db.client.Collection("assistants").Doc(assistant.ID).Create(ctx, firestoreAssistantWrapper{
+		Owner:       assistant.Owner,
+		Name:        assistant.Name,
+		Description: assistant.Description,
+		Params:      string(params),
+		Collections: assistant.Collections,
+		CreatedAt:   assistant.CreatedAt,
+		UpdatedAt:   assistant.UpdatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to create assistant, %v", err)
+	}
+	return nil
+}
+
+func (db *FirestoreController) GetAssistant(ctx context.Context, owner string, id string) (*models.Assistant, error) {
+	doc, err := db.client.Collection("assistants").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("firestore: failed to retrieve assistant, %v", err)
+	}
+
+	assistant, err := unwrapAssistant(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if assistant.Owner != owner {
+		return nil, nil
+	}
+
+	return assistant, nil
+}
+
+func (db *FirestoreController) ListAssistants(ctx context.Context, owner string) ([]models.Assistant, error) {
+	docs, err := db.client.Collection("assistants").Where("owner", "==", owner).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve assistants, %v", err)
+	}
+
+	assistants := make([]models.Assistant, 0, len(docs))
+	for _, doc := range docs {
+		assistant, err := unwrapAssistant(doc)
+		if err != nil {
+			return nil, err
+		}
+		assistants = append(assistants, *assistant)
+	}
+
+	sort.Slice(assistants, func(i, j int) bool {
+		return assistants[i].UpdatedAt.After(assistants[j].UpdatedAt)
+	})
+
+	return assistants, nil
+}
+
+func (db *FirestoreController) UpdateAssistant(ctx context.Context, assistant *models.Assistant) error {
+	existing, err := db.GetAssistant(ctx, assistant.Owner, assistant.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+
+	params, err := json.Marshal(&assistant.Params)
+	if err != nil {
+		return fmt.Errorf("firestore: failed to marshal assistant params, %v", err)
+	}
+
+	_, err = db.client.Collection("assistants").Doc(assistant.ID).Update(ctx, []firestore.Update{
+		{Path: "name", Value: assistant.Name},
+		{Path: "description", Value: assistant.Description},
+		{Path: "params", Value: string(params)},
+		{Path: "collections", Value: assistant.Collections},
+		{Path: "updated_at", Value: assistant.UpdatedAt},
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to update assistant, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) DeleteAssistant(ctx context.Context, owner string, id string) error {
+	existing, err := db.GetAssistant(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+
+	if _, err := db.client.Collection("assistants").Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("firestore: failed to delete assistant, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Messages are stored in a subcollection of their conversation document,
+// so they can be ordered by creation time without a composite index.
+
+type firestoreConversationWrapper struct {
+	Owner       string    `firestore:"owner"`
+	Title       string    `firestore:"title"`
+	AssistantID string    `firestore:"assistant_id,omitempty"`
+	CreatedAt   time.Time `firestore:"created_at"`
+	UpdatedAt   time.Time `firestore:"updated_at"`
+}
+
+type firestoreToolCallWrapper struct {
+	ID        string `firestore:"id"`
+	Name      string `firestore:"name"`
+	Arguments string `firestore:"arguments"`
+}
+
+type firestoreMessageWrapper struct {
+	Role       string                     `firestore:"role"`
+	Content    string                     `firestore:"content"`
+	Model      string                     `firestore:"model,omitempty"`
+	ToolCalls  []firestoreToolCallWrapper `firestore:"tool_calls,omitempty"`
+	ToolCallID string                     `firestore:"tool_call_id,omitempty"`
+	CreatedAt  time.Time                  `firestore:"created_at"`
+}
+
+func (db *FirestoreController) messagesCollection(conversationID string) *firestore.CollectionRef {
+	return db.client.Collection("conversations").Doc(conversationID).Collection("messages")
+}
+
+func unwrapConversation(doc *firestore.DocumentSnapshot) (*models.Conversation, error) {
+	var wrapped firestoreConversationWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	return &models.Conversation{
+		ID:          doc.Ref.ID,
+		Owner:       wrapped.Owner,
+		Title:       wrapped.Title,
+		AssistantID: wrapped.AssistantID,
+		CreatedAt:   wrapped.CreatedAt,
+		UpdatedAt:   wrapped.UpdatedAt,
+	}, nil
+}
+
+func (db *FirestoreController) CreateConversation(ctx context.Context, conversation *models.Conversation) error {
+	_, err := db.client.Collection("conversations").Doc(conversation.ID).Create(ctx, firestoreConversationWrapper{
+		Owner:       conversation.Owner,
+		Title:       conversation.Title,
+		AssistantID: conversation.AssistantID,
+		CreatedAt:   conversation.CreatedAt,
+		UpdatedAt:   conversation.UpdatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to create conversation, %v", err)
+	}
+	return nil
+}
+
+func (db *FirestoreController) GetConversation(ctx context.Context, owner string, id string) (*models.Conversation, error) {
+	doc, err := db.client.Collection("conversations").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("firestore: failed to retrieve conversation, %v", err)
+	}
+
+	conversation, err := unwrapConversation(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.Owner != owner {
+		return nil, nil
+	}
+
+	return conversation, nil
+}
+
+func (db *FirestoreController) ListConversations(ctx context.Context, owner string) ([]models.Conversation, error) {
+	docs, err := db.client.Collection("conversations").Where("owner", "==", owner).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve conversations, %v", err)
+	}
+
+	conversations := make([]models.Conversation, 0, len(docs))
+	for _, doc := range docs {
+		conversation, err := unwrapConversation(doc)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conversation)
+	}
+
+	// NOTE: Sorting on the client side, ordering by a field other than
+	// the one used in the equality filter requires a composite index.
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+	})
+
+	return conversations, nil
+}
+
+func (db *FirestoreController) DeleteConversation(ctx context.Context, owner string, id string) error {
+	conversation, err := db.GetConversation(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if conversation == nil {
+		return nil
+	}
+
+	// Deleting a document doesn't delete its subcollections.
+	bulkWriter := db.client.BulkWriter(ctx)
+	refs, err := db.messagesCollection(id).DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve messages, %v", err)
+	}
+	for _, ref := range refs {
+		if _, err := bulkWriter.Delete(ref); err != nil {
+			return fmt.Errorf("firestore: failed to delete message, %v", err)
+		}
+	}
+	bulkWriter.End()
+
+	if _, err := db.client.Collection("conversations").Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("firestore: failed to delete conversation, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) AddMessage(ctx context.Context, message *models.ConversationMessage) error {
+	conversationRef := db.client.Collection("conversations").Doc(message.ConversationID)
+
+	wrapped := firestoreMessageWrapper{
+		Role:       message.Role,
+		Content:    message.Content,
+		Model:      message.Model,
+		ToolCallID: message.ToolCallID,
+		CreatedAt:  message.CreatedAt,
+	}
+	for _, call := range message.ToolCalls {
+		wrapped.ToolCalls = append(wrapped.ToolCalls, firestoreToolCallWrapper{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		err := tx.Create(db.messagesCollection(message.ConversationID).Doc(message.ID), wrapped)
+		if err != nil {
+			return err
+		}
+		return tx.Update(conversationRef, []firestore.Update{
+			{Path: "updated_at", Value: message.CreatedAt},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to add message, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) GetMessages(ctx context.Context, conversationID string) ([]models.ConversationMessage, error) {
+	docs, err := db.messagesCollection(conversationID).OrderBy("created_at", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve messages, %v", err)
+	}
+
+	messages := make([]models.ConversationMessage, 0, len(docs))
+	for _, doc := range docs {
+		var wrapped firestoreMessageWrapper
+		if err := doc.DataTo(&wrapped); err != nil {
+			return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+		}
+		message := models.ConversationMessage{
+			ID:             doc.Ref.ID,
+			ConversationID: conversationID,
+			Role:           wrapped.Role,
+			Content:        wrapped.Content,
+			Model:          wrapped.Model,
+			ToolCallID:     wrapped.ToolCallID,
+			CreatedAt:      wrapped.CreatedAt,
+		}
+		for _, call := range wrapped.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, models.OpenAIToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: models.OpenAIFunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (db *FirestoreController) DeleteMessages(ctx context.Context, conversationID string, ids []string) error {
+	bulkWriter := db.client.BulkWriter(ctx)
+	for _, id := range ids {
+		// Deleting a document which doesn't exist succeeds.
+		if _, err := bulkWriter.Delete(db.messagesCollection(conversationID).Doc(id)); err != nil {
+			return fmt.Errorf("firestore: failed to delete message, %v", err)
+		}
+	}
+	bulkWriter.End()
+
+	return nil
+}
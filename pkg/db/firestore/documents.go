@@ -0,0 +1,122 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+type firestoreDocumentWrapper struct {
+	Owner       string    `firestore:"owner"`
+	Collection  string    `firestore:"collection"`
+	Name        string    `firestore:"name"`
+	ContentType string    `firestore:"content_type"`
+	Size        int       `firestore:"size"`
+	Chunks      int       `firestore:"chunks"`
+	CreatedAt   time.Time `firestore:"created_at"`
+}
+
+func unwrapDocument(doc *firestore.DocumentSnapshot) (*models.Document, error) {
+	var wrapped firestoreDocumentWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	return &models.Document{
+		ID:          doc.Ref.ID,
+		Owner:       wrapped.Owner,
+		Collection:  wrapped.Collection,
+		Name:        wrapped.Name,
+		ContentType: wrapped.ContentType,
+		Size:        wrapped.Size,
+		Chunks:      wrapped.Chunks,
+		CreatedAt:   wrapped.CreatedAt,
+	}, nil
+}
+
+func (db *FirestoreController) CreateDocument(ctx context.Context, document *models.Document) error {
+	_, err := db.client.Collection("documents").Doc(document.ID).Create(ctx, firestoreDocumentWrapper{
+		Owner:       document.Owner,
+		Collection:  document.Collection,
+		Name:        document.Name,
+		ContentType: document.ContentType,
+		Size:        document.Size,
+		Chunks:      document.Chunks,
+		CreatedAt:   document.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to create document, %v", err)
+	}
+	return nil
+}
+
+func (db *FirestoreController) GetDocument(ctx context.Context, owner string, id string) (*models.Document, error) {
+	doc, err := db.client.Collection("documents").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("firestore: failed to retrieve document, %v", err)
+	}
+
+	document, err := unwrapDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if document.Owner != owner {
+		return nil, nil
+	}
+
+	return document, nil
+}
+
+func (db *FirestoreController) ListDocuments(ctx context.Context, owner string, collection string) ([]models.Document, error) {
+	query := db.client.Collection("documents").Where("owner", "==", owner)
+	if collection != "" {
+		query = query.Where("collection", "==", collection)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve documents, %v", err)
+	}
+
+	documents := make([]models.Document, 0, len(docs))
+	for _, doc := range docs {
+		document, err := unwrapDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, *document)
+	}
+
+	// NOTE: Sorting on the client side for the same reason as the conversations.
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].CreatedAt.After(documents[j].CreatedAt)
+	})
+
+	return documents, nil
+}
+
+func (db *FirestoreController) DeleteDocument(ctx context.Context, owner string, id string) error {
+	document, err := db.GetDocument(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	if document == nil {
+		return nil
+	}
+
+	if _, err := db.client.Collection("documents").Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("firestore: failed to delete document, %v", err)
+	}
+
+	return nil
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/isnastish/openai/pkg/api/models"
 	"github.com/isnastish/openai/pkg/log"
@@ -22,10 +25,33 @@ type FirestoreController struct {
 type firestoreUserDataWrapper struct {
 	FirstName string `firestore:"first_name"`
 	LastName  string `firestore:"last_name"`
-	Email     string `firestor:"email"`
+	Email     string `firestore:"email"`
 	Password  string `firestore:"password"`
 	Country   string `firestore:"country"`
 	City      string `firestore:"city"`
+	// Missing for the users added before emails were verified, they count as verified.
+	EmailVerified       *bool      `firestore:"email_verified"`
+	VerificationSentAt  *time.Time `firestore:"verification_sent_at"`
+	PasswordResetSentAt *time.Time `firestore:"password_reset_sent_at"`
+}
+
+func unwrapUser(doc *firestore.DocumentSnapshot) (*models.UserData, error) {
+	var wrappedUserData firestoreUserDataWrapper
+	if err := doc.DataTo(&wrappedUserData); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+
+	// TODO: Retrieve country and city information
+	return &models.UserData{
+		// Users added before they had IDs were given a generated document ID.
+		ID:                 doc.Ref.ID,
+		FirstName:          wrappedUserData.FirstName,
+		LastName:           wrappedUserData.LastName,
+		Email:              wrappedUserData.Email,
+		Password:           wrappedUserData.Password,
+		EmailVerified:      wrappedUserData.EmailVerified == nil || *wrappedUserData.EmailVerified,
+		VerificationSentAt: wrappedUserData.VerificationSentAt,
+	}, nil
 }
 
 func (db *FirestoreController) Close(_ context.Context) error {
@@ -63,13 +89,16 @@ func NewFirestoreController(ctx context.Context) (*FirestoreController, error) {
 }
 
 func (db *FirestoreController) AddUser(ctx context.Context, userData *models.UserData, geolocation *models.Geolocation) error {
-	_, _, err := db.client.Collection("users").Add(ctx, map[string]interface{}{
+	_, err := db.client.Collection("users").Doc(userData.ID).Create(ctx, map[string]interface{}{
 		"first_name": userData.FirstName,
 		"last_name":  userData.LastName,
 		"email":      userData.Email,
 		"password":   userData.Password,
 		"country":    geolocation.Country,
 		"city":       geolocation.City,
+
+		"email_verified":       userData.EmailVerified,
+		"verification_sent_at": userData.VerificationSentAt,
 	})
 	if err != nil {
 		return fmt.Errorf("firestore: failed to add user, %v", err)
@@ -81,33 +110,224 @@ func (db *FirestoreController) AddUser(ctx context.Context, userData *models.Use
 }
 
 func (db *FirestoreController) GetUserByEmail(ctx context.Context, email string) (*models.UserData, error) {
-	var wrappedUserData firestoreUserDataWrapper
-
 	// TODO: Use WhereEntity instead.
-	iter := db.client.Collection("users").Where("email", "==", email).Documents(ctx)
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
+	iter := db.client.Collection("users").Where("email", "==", email).Limit(1).Documents(ctx)
+	doc, err := iter.Next()
+	if err == iterator.Done {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve document, %v", err)
+	}
+
+	return unwrapUser(doc)
+}
+
+func (db *FirestoreController) GetUserByID(ctx context.Context, id string) (*models.UserData, error) {
+	doc, err := db.client.Collection("users").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
 			return nil, nil
 		}
+		return nil, fmt.Errorf("firestore: failed to retrieve user, %v", err)
+	}
+
+	return unwrapUser(doc)
+}
+
+func (db *FirestoreController) MarkEmailVerified(ctx context.Context, id string) error {
+	_, err := db.client.Collection("users").Doc(id).Update(ctx, []firestore.Update{{Path: "email_verified", Value: true}})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("firestore: failed to mark email verified, %v", err)
+	}
+	return nil
+}
+
+func (db *FirestoreController) UpdatePassword(ctx context.Context, id string, password string) error {
+	_, err := db.client.Collection("users").Doc(id).Update(ctx, []firestore.Update{{Path: "password", Value: password}})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to update password, %v", err)
+	}
+	return nil
+}
+
+func (db *FirestoreController) RecordVerificationEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	ref := db.client.Collection("users").Doc(id)
+
+	var recorded bool
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// The transaction may be retried.
+		recorded = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+
+		user, err := unwrapUser(doc)
 		if err != nil {
-			return nil, fmt.Errorf("firestore: failed to retrieve document, %v", err)
+			return err
 		}
-		if err := doc.DataTo(&wrappedUserData); err != nil {
-			return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+		if user.VerificationSentAt != nil && user.VerificationSentAt.After(sentAt.Add(-interval)) {
+			return nil
 		}
-		break
+
+		recorded = true
+		return tx.Update(ref, []firestore.Update{{Path: "verification_sent_at", Value: sentAt}})
+	})
+	if err != nil {
+		return false, fmt.Errorf("firestore: failed to record verification email, %v", err)
 	}
 
-	// TODO: Retrieve country and city information
-	return &models.UserData{
-		FirstName: wrappedUserData.FirstName,
-		LastName:  wrappedUserData.LastName,
-		Email:     wrappedUserData.Email,
-		Password:  wrappedUserData.Password,
-	}, nil
+	return recorded, nil
+}
+
+func (db *FirestoreController) RecordPasswordResetEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	ref := db.client.Collection("users").Doc(id)
+
+	var recorded bool
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// The transaction may be retried.
+		recorded = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+
+		var wrapped firestoreUserDataWrapper
+		if err := doc.DataTo(&wrapped); err != nil {
+			return fmt.Errorf("firestore: failed to convert document, %v", err)
+		}
+		if wrapped.PasswordResetSentAt != nil && wrapped.PasswordResetSentAt.After(sentAt.Add(-interval)) {
+			return nil
+		}
+
+		recorded = true
+		return tx.Update(ref, []firestore.Update{{Path: "password_reset_sent_at", Value: sentAt}})
+	})
+	if err != nil {
+		return false, fmt.Errorf("firestore: failed to record password reset email, %v", err)
+	}
+
+	return recorded, nil
 }
 
-func (db *FirestoreController) GetUserByID(ctx context.Context, id int) (*models.UserData, error) {
-	return nil, nil
+// Firestore can't join collections, so the data of every user is looked up by their email.
+// The migration is recorded once it's completed, so the users are only ever scanned once.
+func (db *FirestoreController) MigrateOwners(ctx context.Context) error {
+	marker := db.client.Collection("migrations").Doc("owners")
+	if _, err := marker.Get(ctx); err == nil {
+		return nil
+	} else if status.Code(err) != codes.NotFound {
+		return fmt.Errorf("firestore: failed to retrieve migration, %v", err)
+	}
+
+	users, err := db.client.Collection("users").Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve users, %v", err)
+	}
+	for _, doc := range users {
+		user, err := unwrapUser(doc)
+		if err != nil {
+			return err
+		}
+		if err := db.reassignOwner(ctx, user.Email, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := marker.Set(ctx, map[string]interface{}{"completed_at": time.Now().UTC()}); err != nil {
+		return fmt.Errorf("firestore: failed to record migration, %v", err)
+	}
+
+	return nil
+}
+
+// endBulkWriter flushes the writes and returns the first one which failed.
+func endBulkWriter(bulkWriter *firestore.BulkWriter, jobs []*firestore.BulkWriterJob) error {
+	bulkWriter.End()
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NOTE: Firestore has no multi-document updates, the documents are rewritten one by one.
+func (db *FirestoreController) reassignOwner(ctx context.Context, from string, to string) error {
+	queries := []struct {
+		query firestore.Query
+		field string
+	}{
+		{db.client.Collection("conversations").Where("owner", "==", from), "owner"},
+		{db.client.Collection("documents").Where("owner", "==", from), "owner"},
+		{db.client.Collection("moderation_events").Where("owner", "==", from), "owner"},
+		{db.client.Collection("prompt_templates").Where("owner", "==", from), "owner"},
+		{db.client.CollectionGroup("versions").Where("created_by", "==", from), "created_by"},
+		{db.client.Collection("assistants").Where("owner", "==", from), "owner"},
+		{db.client.Collection("refresh_tokens").Where("owner", "==", from), "owner"},
+	}
+
+	// Usage records are keyed by their owner, so they are copied to the subcollection of the new one.
+	records, err := db.usageCollection(from).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve usage, %v", err)
+	}
+
+	bulkWriter := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+
+	for _, q := range queries {
+		docs, err := q.query.Documents(ctx).GetAll()
+		if err != nil {
+			bulkWriter.End()
+			return fmt.Errorf("firestore: failed to retrieve documents, %v", err)
+		}
+		for _, doc := range docs {
+			job, err := bulkWriter.Update(doc.Ref, []firestore.Update{{Path: q.field, Value: to}})
+			if err != nil {
+				bulkWriter.End()
+				return fmt.Errorf("firestore: failed to reassign owner, %v", err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	for _, record := range records {
+		job, err := bulkWriter.Set(db.usageCollection(to).Doc(record.Ref.ID), record.Data())
+		if err != nil {
+			bulkWriter.End()
+			return fmt.Errorf("firestore: failed to reassign usage, %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to reassign owner, %v", err)
+	}
+
+	// The copies are in place, only now the originals can go without losing any usage.
+	bulkWriter = db.client.BulkWriter(ctx)
+	jobs = jobs[:0]
+	for _, record := range records {
+		job, err := bulkWriter.Delete(record.Ref)
+		if err != nil {
+			bulkWriter.End()
+			return fmt.Errorf("firestore: failed to reassign usage, %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to reassign usage, %v", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,35 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+type firestoreModerationEventWrapper struct {
+	Owner          string    `firestore:"owner"`
+	ConversationID string    `firestore:"conversation_id"`
+	Stage          string    `firestore:"stage"`
+	Categories     []string  `firestore:"categories"`
+	Blocked        bool      `firestore:"blocked"`
+	Text           string    `firestore:"text"`
+	CreatedAt      time.Time `firestore:"created_at"`
+}
+
+func (db *FirestoreController) AddModerationEvent(ctx context.Context, event *models.ModerationEvent) error {
+	_, err := db.client.Collection("moderation_events").Doc(event.ID).Create(ctx, firestoreModerationEventWrapper{
+		Owner:          event.Owner,
+		ConversationID: event.ConversationID,
+		Stage:          event.Stage,
+		Categories:     event.Categories,
+		Blocked:        event.Blocked,
+		Text:           event.Text,
+		CreatedAt:      event.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to add moderation event, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/search"
+)
+
+// NOTE: Firestore has no full-text search, so the messages of the owner's conversations
+// are read within the requested time range and ranked on the client side.
+// This is fine for the history of a single user, an external index would be needed beyond that.
+func (db *FirestoreController) SearchMessages(ctx context.Context, owner string, query *models.MessageSearchQuery) ([]models.MessageSearchHit, error) {
+	terms := search.Terms(query.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	conversations, err := db.ListConversations(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []models.MessageSearchHit{}
+	for _, conversation := range conversations {
+		// Conversations which weren't updated since the beginning of the range can't have matching messages.
+		if !query.From.IsZero() && conversation.UpdatedAt.Before(query.From) {
+			continue
+		}
+
+		// Model and role are filtered on the client side, equality filters
+		// combined with a range on another field require a composite index.
+		messagesQuery := db.messagesCollection(conversation.ID).Query
+		if !query.From.IsZero() {
+			messagesQuery = messagesQuery.Where("created_at", ">=", query.From)
+		}
+		if !query.To.IsZero() {
+			messagesQuery = messagesQuery.Where("created_at", "<", query.To)
+		}
+
+		docs, err := messagesQuery.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("firestore: failed to retrieve messages, %v", err)
+		}
+
+		for _, doc := range docs {
+			var wrapped firestoreMessageWrapper
+			if err := doc.DataTo(&wrapped); err != nil {
+				return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+			}
+			if (query.Model != "" && wrapped.Model != query.Model) || (query.Role != "" && wrapped.Role != query.Role) {
+				continue
+			}
+
+			rank := search.Score(wrapped.Content, terms)
+			if rank == 0 {
+				continue
+			}
+
+			hit := models.MessageSearchHit{
+				ConversationID:    conversation.ID,
+				ConversationTitle: conversation.Title,
+				MessageID:         doc.Ref.ID,
+				Role:              wrapped.Role,
+				Model:             wrapped.Model,
+				Rank:              rank,
+				CreatedAt:         wrapped.CreatedAt,
+			}
+			if !search.After(&hit, query.After) {
+				continue
+			}
+			hit.Snippet = search.Snippet(wrapped.Content, terms)
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return search.Less(&hits[i], &hits[j])
+	})
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+
+	return hits, nil
+}
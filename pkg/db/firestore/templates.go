@@ -0,0 +1,279 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Versions are stored in a subcollection of their template document, keyed by the version number,
+// so creating a version which already exists fails.
+
+type firestoreTemplateWrapper struct {
+	Owner         string    `firestore:"owner"`
+	Name          string    `firestore:"name"`
+	Description   string    `firestore:"description"`
+	Visibility    string    `firestore:"visibility"`
+	Organization  string    `firestore:"organization"`
+	LatestVersion int       `firestore:"latest_version"`
+	CreatedAt     time.Time `firestore:"created_at"`
+	UpdatedAt     time.Time `firestore:"updated_at"`
+}
+
+type firestoreTemplateVersionWrapper struct {
+	Version   int       `firestore:"version"`
+	Content   string    `firestore:"content"`
+	Variables []string  `firestore:"variables"`
+	CreatedBy string    `firestore:"created_by"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+func (db *FirestoreController) templateVersionsCollection(templateID string) *firestore.CollectionRef {
+	return db.client.Collection("prompt_templates").Doc(templateID).Collection("versions")
+}
+
+func wrapTemplateVersion(version *models.PromptTemplateVersion) firestoreTemplateVersionWrapper {
+	return firestoreTemplateVersionWrapper{
+		Version:   version.Version,
+		Content:   version.Content,
+		Variables: version.Variables,
+		CreatedBy: version.CreatedBy,
+		CreatedAt: version.CreatedAt,
+	}
+}
+
+func unwrapTemplate(doc *firestore.DocumentSnapshot) (*models.PromptTemplate, error) {
+	var wrapped firestoreTemplateWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	return &models.PromptTemplate{
+		ID:            doc.Ref.ID,
+		Owner:         wrapped.Owner,
+		Name:          wrapped.Name,
+		Description:   wrapped.Description,
+		Visibility:    wrapped.Visibility,
+		Organization:  wrapped.Organization,
+		LatestVersion: wrapped.LatestVersion,
+		CreatedAt:     wrapped.CreatedAt,
+		UpdatedAt:     wrapped.UpdatedAt,
+	}, nil
+}
+
+func unwrapTemplateVersion(templateID string, doc *firestore.DocumentSnapshot) (*models.PromptTemplateVersion, error) {
+	var wrapped firestoreTemplateVersionWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	return &models.PromptTemplateVersion{
+		TemplateID: templateID,
+		Version:    wrapped.Version,
+		Content:    wrapped.Content,
+		Variables:  wrapped.Variables,
+		CreatedBy:  wrapped.CreatedBy,
+		CreatedAt:  wrapped.CreatedAt,
+	}, nil
+}
+
+// visible reports whether the template can be seen by the owner or the members of the organisation.
+func visible(template *models.PromptTemplate, owner string, organization string) bool {
+	return template.Owner == owner ||
+		(organization != "" && template.Organization == organization && template.Visibility == models.TemplateOrganization)
+}
+
+func (db *FirestoreController) CreatePromptTemplate(ctx context.Context, template *models.PromptTemplate, version *models.PromptTemplateVersion) error {
+	templateRef := db.client.Collection("prompt_templates").Doc(template.ID)
+
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		err := tx.Create(templateRef, firestoreTemplateWrapper{
+			Owner:         template.Owner,
+			Name:          template.Name,
+			Description:   template.Description,
+			Visibility:    template.Visibility,
+			Organization:  template.Organization,
+			LatestVersion: template.LatestVersion,
+			CreatedAt:     template.CreatedAt,
+			UpdatedAt:     template.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Create(db.templateVersionsCollection(template.ID).Doc(strconv.Itoa(version.Version)), wrapTemplateVersion(version))
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to create prompt template, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) GetPromptTemplate(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error) {
+	doc, err := db.client.Collection("prompt_templates").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("firestore: failed to retrieve prompt template, %v", err)
+	}
+
+	template, err := unwrapTemplate(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if !visible(template, owner, organization) {
+		return nil, nil
+	}
+
+	return template, nil
+}
+
+func (db *FirestoreController) ListPromptTemplates(ctx context.Context, owner string, organization string) ([]models.PromptTemplate, error) {
+	collection := db.client.Collection("prompt_templates")
+
+	queries := []firestore.Query{collection.Where("owner", "==", owner)}
+	if organization != "" {
+		queries = append(queries, collection.
+			Where("organization", "==", organization).
+			Where("visibility", "==", models.TemplateOrganization))
+	}
+
+	// The owner's shared templates are matched by both queries.
+	seen := make(map[string]bool)
+	templates := []models.PromptTemplate{}
+
+	for _, query := range queries {
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("firestore: failed to retrieve prompt templates, %v", err)
+		}
+		for _, doc := range docs {
+			if seen[doc.Ref.ID] {
+				continue
+			}
+			seen[doc.Ref.ID] = true
+
+			template, err := unwrapTemplate(doc)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, *template)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].UpdatedAt.After(templates[j].UpdatedAt)
+	})
+
+	return templates, nil
+}
+
+func (db *FirestoreController) UpdatePromptTemplate(ctx context.Context, template *models.PromptTemplate) error {
+	existing, err := db.GetPromptTemplate(ctx, template.Owner, "", template.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+
+	_, err = db.client.Collection("prompt_templates").Doc(template.ID).Update(ctx, []firestore.Update{
+		{Path: "name", Value: template.Name},
+		{Path: "description", Value: template.Description},
+		{Path: "visibility", Value: template.Visibility},
+		{Path: "organization", Value: template.Organization},
+		{Path: "updated_at", Value: template.UpdatedAt},
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to update prompt template, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) DeletePromptTemplate(ctx context.Context, owner string, id string) error {
+	template, err := db.GetPromptTemplate(ctx, owner, "", id)
+	if err != nil {
+		return err
+	}
+	if template == nil {
+		return nil
+	}
+
+	// Deleting a document doesn't delete its subcollections.
+	bulkWriter := db.client.BulkWriter(ctx)
+	refs, err := db.templateVersionsCollection(id).DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve prompt template versions, %v", err)
+	}
+	for _, ref := range refs {
+		if _, err := bulkWriter.Delete(ref); err != nil {
+			return fmt.Errorf("firestore: failed to delete prompt template version, %v", err)
+		}
+	}
+	bulkWriter.End()
+
+	if _, err := db.client.Collection("prompt_templates").Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("firestore: failed to delete prompt template, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) AddPromptTemplateVersion(ctx context.Context, version *models.PromptTemplateVersion) error {
+	templateRef := db.client.Collection("prompt_templates").Doc(version.TemplateID)
+
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		versionRef := db.templateVersionsCollection(version.TemplateID).Doc(strconv.Itoa(version.Version))
+		if err := tx.Create(versionRef, wrapTemplateVersion(version)); err != nil {
+			return err
+		}
+		return tx.Update(templateRef, []firestore.Update{
+			{Path: "latest_version", Value: version.Version},
+			{Path: "updated_at", Value: version.CreatedAt},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to add prompt template version, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) GetPromptTemplateVersion(ctx context.Context, templateID string, version int) (*models.PromptTemplateVersion, error) {
+	doc, err := db.templateVersionsCollection(templateID).Doc(strconv.Itoa(version)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("firestore: failed to retrieve prompt template version, %v", err)
+	}
+
+	return unwrapTemplateVersion(templateID, doc)
+}
+
+func (db *FirestoreController) ListPromptTemplateVersions(ctx context.Context, templateID string) ([]models.PromptTemplateVersion, error) {
+	docs, err := db.templateVersionsCollection(templateID).OrderBy("version", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve prompt template versions, %v", err)
+	}
+
+	versions := make([]models.PromptTemplateVersion, 0, len(docs))
+	for _, doc := range docs {
+		version, err := unwrapTemplateVersion(templateID, doc)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *version)
+	}
+
+	return versions, nil
+}
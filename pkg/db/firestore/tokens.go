@@ -0,0 +1,302 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+type firestoreRefreshTokenWrapper struct {
+	Family    string     `firestore:"family"`
+	Owner     string     `firestore:"owner"`
+	CreatedAt time.Time  `firestore:"created_at"`
+	ExpiresAt time.Time  `firestore:"expires_at"`
+	RotatedAt *time.Time `firestore:"rotated_at"`
+	Revoked   bool       `firestore:"revoked"`
+}
+
+func unwrapRefreshToken(doc *firestore.DocumentSnapshot) (*models.RefreshToken, error) {
+	var wrapped firestoreRefreshTokenWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	return &models.RefreshToken{
+		ID:        doc.Ref.ID,
+		Family:    wrapped.Family,
+		Owner:     wrapped.Owner,
+		CreatedAt: wrapped.CreatedAt,
+		ExpiresAt: wrapped.ExpiresAt,
+		RotatedAt: wrapped.RotatedAt,
+		Revoked:   wrapped.Revoked,
+	}, nil
+}
+
+type firestorePasswordResetTokenWrapper struct {
+	Owner     string     `firestore:"owner"`
+	CreatedAt time.Time  `firestore:"created_at"`
+	ExpiresAt time.Time  `firestore:"expires_at"`
+	UsedAt    *time.Time `firestore:"used_at"`
+}
+
+func unwrapPasswordResetToken(doc *firestore.DocumentSnapshot) (*models.PasswordResetToken, error) {
+	var wrapped firestorePasswordResetTokenWrapper
+	if err := doc.DataTo(&wrapped); err != nil {
+		return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+	}
+	return &models.PasswordResetToken{
+		ID:        doc.Ref.ID,
+		Owner:     wrapped.Owner,
+		CreatedAt: wrapped.CreatedAt,
+		ExpiresAt: wrapped.ExpiresAt,
+		UsedAt:    wrapped.UsedAt,
+	}, nil
+}
+
+// NOTE: The expired tokens of the owner are removed whenever a new one is issued.
+// They are filtered on the client side, a range filter on another field requires a composite index.
+func (db *FirestoreController) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	collection := db.client.Collection("refresh_tokens")
+
+	docs, err := collection.Where("owner", "==", token.Owner).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve refresh tokens, %v", err)
+	}
+
+	bulkWriter := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, doc := range docs {
+		existing, err := unwrapRefreshToken(doc)
+		if err != nil {
+			bulkWriter.End()
+			return err
+		}
+		if existing.ExpiresAt.Before(token.CreatedAt) {
+			job, err := bulkWriter.Delete(doc.Ref)
+			if err != nil {
+				bulkWriter.End()
+				return fmt.Errorf("firestore: failed to delete refresh token, %v", err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to delete expired refresh tokens, %v", err)
+	}
+
+	_, err = collection.Doc(token.ID).Create(ctx, firestoreRefreshTokenWrapper{
+		Family:    token.Family,
+		Owner:     token.Owner,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+		RotatedAt: token.RotatedAt,
+		Revoked:   token.Revoked,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to create refresh token, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	doc, err := db.client.Collection("refresh_tokens").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("firestore: failed to retrieve refresh token, %v", err)
+	}
+	return unwrapRefreshToken(doc)
+}
+
+func (db *FirestoreController) RotateRefreshToken(ctx context.Context, id string, rotatedAt time.Time) (bool, error) {
+	ref := db.client.Collection("refresh_tokens").Doc(id)
+
+	rotated := false
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// The transaction may be retried.
+		rotated = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+
+		token, err := unwrapRefreshToken(doc)
+		if err != nil {
+			return err
+		}
+		if token.RotatedAt != nil || token.Revoked {
+			return nil
+		}
+
+		rotated = true
+		return tx.Update(ref, []firestore.Update{{Path: "rotated_at", Value: rotatedAt}})
+	})
+	if err != nil {
+		return false, fmt.Errorf("firestore: failed to rotate refresh token, %v", err)
+	}
+
+	return rotated, nil
+}
+
+func (db *FirestoreController) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	docs, err := db.client.Collection("refresh_tokens").Where("family", "==", family).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve refresh tokens, %v", err)
+	}
+
+	bulkWriter := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, doc := range docs {
+		job, err := bulkWriter.Update(doc.Ref, []firestore.Update{{Path: "revoked", Value: true}})
+		if err != nil {
+			bulkWriter.End()
+			return fmt.Errorf("firestore: failed to revoke refresh token, %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to revoke refresh token family, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) RevokeRefreshTokens(ctx context.Context, owner string) error {
+	docs, err := db.client.Collection("refresh_tokens").Where("owner", "==", owner).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve refresh tokens, %v", err)
+	}
+
+	bulkWriter := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, doc := range docs {
+		job, err := bulkWriter.Update(doc.Ref, []firestore.Update{{Path: "revoked", Value: true}})
+		if err != nil {
+			bulkWriter.End()
+			return fmt.Errorf("firestore: failed to revoke refresh token, %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to revoke refresh tokens, %v", err)
+	}
+
+	return nil
+}
+
+// NOTE: Like with the refresh tokens, the expired tokens of the owner are removed whenever a new one is issued.
+func (db *FirestoreController) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	collection := db.client.Collection("password_reset_tokens")
+
+	docs, err := collection.Where("owner", "==", token.Owner).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve password reset tokens, %v", err)
+	}
+
+	bulkWriter := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, doc := range docs {
+		existing, err := unwrapPasswordResetToken(doc)
+		if err != nil {
+			bulkWriter.End()
+			return err
+		}
+		if existing.ExpiresAt.Before(token.CreatedAt) {
+			job, err := bulkWriter.Delete(doc.Ref)
+			if err != nil {
+				bulkWriter.End()
+				return fmt.Errorf("firestore: failed to delete password reset token, %v", err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to delete expired password reset tokens, %v", err)
+	}
+
+	_, err = collection.Doc(token.ID).Create(ctx, firestorePasswordResetTokenWrapper{
+		Owner:     token.Owner,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+		UsedAt:    token.UsedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to create password reset token, %v", err)
+	}
+
+	return nil
+}
+
+func (db *FirestoreController) UsePasswordResetToken(ctx context.Context, id string, usedAt time.Time) (*models.PasswordResetToken, error) {
+	ref := db.client.Collection("password_reset_tokens").Doc(id)
+
+	var used *models.PasswordResetToken
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// The transaction may be retried.
+		used = nil
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+
+		token, err := unwrapPasswordResetToken(doc)
+		if err != nil {
+			return err
+		}
+		if token.UsedAt != nil || !token.ExpiresAt.After(usedAt) {
+			return nil
+		}
+
+		token.UsedAt = &usedAt
+		used = token
+		return tx.Update(ref, []firestore.Update{{Path: "used_at", Value: usedAt}})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to use password reset token, %v", err)
+	}
+
+	return used, nil
+}
+
+func (db *FirestoreController) DeletePasswordResetTokens(ctx context.Context, owner string) error {
+	docs, err := db.client.Collection("password_reset_tokens").Where("owner", "==", owner).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("firestore: failed to retrieve password reset tokens, %v", err)
+	}
+
+	bulkWriter := db.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, doc := range docs {
+		job, err := bulkWriter.Delete(doc.Ref)
+		if err != nil {
+			bulkWriter.End()
+			return fmt.Errorf("firestore: failed to delete password reset token, %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := endBulkWriter(bulkWriter, jobs); err != nil {
+		return fmt.Errorf("firestore: failed to delete password reset tokens, %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Usage records are stored in a subcollection per user,
+// so they can be filtered by creation time without a composite index.
+
+type firestoreUsageWrapper struct {
+	Kind             string    `firestore:"kind"`
+	Model            string    `firestore:"model"`
+	PromptTokens     int       `firestore:"prompt_tokens"`
+	CompletionTokens int       `firestore:"completion_tokens"`
+	Cost             float64   `firestore:"cost"`
+	CreatedAt        time.Time `firestore:"created_at"`
+}
+
+func (db *FirestoreController) usageCollection(owner string) *firestore.CollectionRef {
+	return db.client.Collection("usage").Doc(owner).Collection("records")
+}
+
+func (db *FirestoreController) AddUsage(ctx context.Context, record *models.UsageRecord) error {
+	_, err := db.usageCollection(record.Owner).Doc(record.ID).Create(ctx, firestoreUsageWrapper{
+		Kind:             record.Kind,
+		Model:            record.Model,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		Cost:             record.Cost,
+		CreatedAt:        record.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("firestore: failed to add usage, %v", err)
+	}
+	return nil
+}
+
+func (db *FirestoreController) GetUsage(ctx context.Context, owner string, from time.Time, to time.Time) ([]models.ModelUsage, error) {
+	docs, err := db.usageCollection(owner).
+		Where("created_at", ">=", from).
+		Where("created_at", "<", to).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("firestore: failed to retrieve usage, %v", err)
+	}
+
+	// NOTE: Firestore has no grouping, the records are summed on the client side.
+	perModel := make(map[string]*models.ModelUsage)
+	for _, doc := range docs {
+		var wrapped firestoreUsageWrapper
+		if err := doc.DataTo(&wrapped); err != nil {
+			return nil, fmt.Errorf("firestore: failed to convert document, %v", err)
+		}
+		usage, ok := perModel[wrapped.Model]
+		if !ok {
+			usage = &models.ModelUsage{Model: wrapped.Model}
+			perModel[wrapped.Model] = usage
+		}
+		usage.Requests++
+		usage.PromptTokens += wrapped.PromptTokens
+		usage.CompletionTokens += wrapped.CompletionTokens
+		usage.Cost += wrapped.Cost
+	}
+
+	usage := make([]models.ModelUsage, 0, len(perModel))
+	for _, modelUsage := range perModel {
+		usage = append(usage, *modelUsage)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Model < usage[j].Model
+	})
+
+	return usage, nil
+}
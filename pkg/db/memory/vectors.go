@@ -0,0 +1,143 @@
+// Package memory implements the storage interfaces in memory,
+// for tests and for running the server without any database.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+type vectorEntry struct {
+	record models.VectorRecord
+	norm   float64
+}
+
+// VectorStore is an exact k-NN index, every query is compared against all the records of the owner.
+// That's fast enough for per-user collections of up to tens of thousands of records.
+type VectorStore struct {
+	mu sync.RWMutex
+	// owner -> id -> entry
+	entries map[string]map[string]*vectorEntry
+}
+
+func NewVectorStore() *VectorStore {
+	return &VectorStore{
+		entries: make(map[string]map[string]*vectorEntry),
+	}
+}
+
+func norm(vector []float32) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
+
+func (s *VectorStore) Upsert(_ context.Context, records []models.VectorRecord) error {
+	for _, record := range records {
+		if record.ID == "" || record.Owner == "" {
+			return fmt.Errorf("memory: record must have an id and an owner")
+		}
+		if len(record.Vector) == 0 {
+			return fmt.Errorf("memory: record %s has an empty vector", record.ID)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		owned, ok := s.entries[record.Owner]
+		if !ok {
+			owned = make(map[string]*vectorEntry)
+			s.entries[record.Owner] = owned
+		}
+
+		// The caller might reuse the slice.
+		record.Vector = append([]float32(nil), record.Vector...)
+		owned[record.ID] = &vectorEntry{record: record, norm: norm(record.Vector)}
+	}
+
+	return nil
+}
+
+func (s *VectorStore) Delete(_ context.Context, owner string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owned := s.entries[owner]
+	for _, id := range ids {
+		delete(owned, id)
+	}
+
+	return nil
+}
+
+func (s *VectorStore) Query(_ context.Context, owner string, collection string, vector []float32, k int) ([]models.VectorMatch, error) {
+	if len(vector) == 0 {
+		return nil, fmt.Errorf("memory: query vector is empty")
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	queryNorm := norm(vector)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []models.VectorMatch
+	for _, entry := range s.entries[owner] {
+		if collection != "" && entry.record.Collection != collection {
+			continue
+		}
+		if len(entry.record.Vector) != len(vector) {
+			return nil, fmt.Errorf("memory: record %s has %d dimensions, the query has %d",
+				entry.record.ID, len(entry.record.Vector), len(vector))
+		}
+
+		var dot float64
+		for i, v := range vector {
+			dot += float64(v) * float64(entry.record.Vector[i])
+		}
+
+		var score float64
+		if queryNorm > 0 && entry.norm > 0 {
+			score = dot / (queryNorm * entry.norm)
+		}
+
+		match := models.VectorMatch{VectorRecord: entry.record, Score: score}
+		// Vectors are not returned by the queries, there is no need to hand out the stored slice.
+		match.Vector = nil
+		matches = append(matches, match)
+	}
+
+	// Ties are broken by id to keep the results stable.
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	return matches, nil
+}
+
+// The store starts out empty, there is nothing owned by an email to migrate.
+func (s *VectorStore) MigrateOwners(_ context.Context) error {
+	return nil
+}
+
+func (s *VectorStore) Close(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func record(owner, collection, id string, vector ...float32) models.VectorRecord {
+	return models.VectorRecord{ID: id, Owner: owner, Collection: collection, Content: id, Vector: vector}
+}
+
+func ids(matches []models.VectorMatch) string {
+	var result []string
+	for _, match := range matches {
+		result = append(result, match.ID)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	store := NewVectorStore()
+
+	err := store.Upsert(ctx, []models.VectorRecord{
+		record("ada", "notes", "x", 1, 0),
+		record("ada", "notes", "xy", 1, 1),
+		record("ada", "notes", "y", 0, 1),
+		record("ada", "papers", "-x", -1, 0),
+		record("bob", "notes", "bob-x", 1, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := store.Query(ctx, "ada", "", []float32{2, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(matches) != "x,xy,y,-x" {
+		t.Errorf("expected the records of the owner ordered by similarity, got: %s", ids(matches))
+	}
+	if math.Abs(matches[0].Score-1) > 1e-9 || math.Abs(matches[1].Score-math.Sqrt2/2) > 1e-6 || math.Abs(matches[3].Score+1) > 1e-9 {
+		t.Errorf("unexpected scores: %+v", matches)
+	}
+	if matches[0].Vector != nil {
+		t.Errorf("expected vectors not to be returned")
+	}
+
+	matches, _ = store.Query(ctx, "ada", "notes", []float32{0, 1}, 2)
+	if ids(matches) != "y,xy" {
+		t.Errorf("expected the top 2 records of the collection, got: %s", ids(matches))
+	}
+}
+
+func TestUpsertAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := NewVectorStore()
+
+	store.Upsert(ctx, []models.VectorRecord{record("ada", "notes", "a", 1, 0), record("bob", "notes", "a", 1, 0)})
+	// Replaces the record of ada only.
+	store.Upsert(ctx, []models.VectorRecord{record("ada", "notes", "a", 0, 1)})
+
+	matches, _ := store.Query(ctx, "ada", "", []float32{0, 1}, 1)
+	if len(matches) != 1 || matches[0].Score < 0.99 {
+		t.Errorf("expected the record to be replaced, got: %+v", matches)
+	}
+	matches, _ = store.Query(ctx, "bob", "", []float32{1, 0}, 1)
+	if len(matches) != 1 || matches[0].Score < 0.99 {
+		t.Errorf("expected the record of another owner to stay intact, got: %+v", matches)
+	}
+
+	store.Delete(ctx, "ada", []string{"a", "missing"})
+	if matches, _ := store.Query(ctx, "ada", "", []float32{0, 1}, 1); len(matches) != 0 {
+		t.Errorf("expected the record to be deleted, got: %+v", matches)
+	}
+	if matches, _ := store.Query(ctx, "bob", "", []float32{0, 1}, 1); len(matches) != 1 {
+		t.Errorf("expected the record of another owner to stay intact")
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	ctx := context.Background()
+	store := NewVectorStore()
+
+	if err := store.Upsert(ctx, []models.VectorRecord{record("ada", "notes", "a")}); err == nil {
+		t.Errorf("expected an empty vector to be rejected")
+	}
+	if err := store.Upsert(ctx, []models.VectorRecord{record("", "notes", "a", 1)}); err == nil {
+		t.Errorf("expected a record without an owner to be rejected")
+	}
+
+	store.Upsert(ctx, []models.VectorRecord{record("ada", "notes", "a", 1, 0)})
+	if _, err := store.Query(ctx, "ada", "", []float32{1, 0, 0}, 1); err == nil {
+		t.Errorf("expected mismatching dimensions to be reported")
+	}
+	if matches, err := store.Query(ctx, "ada", "", []float32{1, 0}, 0); err != nil || matches != nil {
+		t.Errorf("expected no matches for k = 0, got: %v, %v", matches, err)
+	}
+}
@@ -0,0 +1,67 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func (db *MondgodbController) CreateAssistant(ctx context.Context, assistant *models.Assistant) error {
+	if _, err := db.assistants.InsertOne(ctx, assistant); err != nil {
+		return fmt.Errorf("mongodb: failed to create assistant, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) GetAssistant(ctx context.Context, owner string, id string) (*models.Assistant, error) {
+	var assistant models.Assistant
+	if err := db.assistants.FindOne(ctx, bson.M{"_id": id, "owner": owner}).Decode(&assistant); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find assistant, error: %v", err)
+	}
+	return &assistant, nil
+}
+
+func (db *MondgodbController) ListAssistants(ctx context.Context, owner string) ([]models.Assistant, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := db.assistants.Find(ctx, bson.M{"owner": owner}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to find assistants, error: %v", err)
+	}
+
+	assistants := []models.Assistant{}
+	if err := cursor.All(ctx, &assistants); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode assistants, error: %v", err)
+	}
+
+	return assistants, nil
+}
+
+func (db *MondgodbController) UpdateAssistant(ctx context.Context, assistant *models.Assistant) error {
+	update := bson.M{"$set": bson.M{
+		"name":        assistant.Name,
+		"description": assistant.Description,
+		"params":      assistant.Params,
+		"collections": assistant.Collections,
+		"updated_at":  assistant.UpdatedAt,
+	}}
+	if _, err := db.assistants.UpdateOne(ctx, bson.M{"_id": assistant.ID, "owner": assistant.Owner}, update); err != nil {
+		return fmt.Errorf("mongodb: failed to update assistant, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) DeleteAssistant(ctx context.Context, owner string, id string) error {
+	if _, err := db.assistants.DeleteOne(ctx, bson.M{"_id": id, "owner": owner}); err != nil {
+		return fmt.Errorf("mongodb: failed to delete assistant, error: %v", err)
+	}
+	return nil
+}
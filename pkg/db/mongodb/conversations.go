@@ -0,0 +1,101 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func (db *MondgodbController) CreateConversation(ctx context.Context, conversation *models.Conversation) error {
+	if _, err := db.conversations.InsertOne(ctx, conversation); err != nil {
+		return fmt.Errorf("mongodb: failed to create conversation, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) GetConversation(ctx context.Context, owner string, id string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := db.conversations.FindOne(ctx, bson.M{"_id": id, "owner": owner}).Decode(&conversation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find conversation, error: %v", err)
+	}
+	return &conversation, nil
+}
+
+func (db *MondgodbController) ListConversations(ctx context.Context, owner string) ([]models.Conversation, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := db.conversations.Find(ctx, bson.M{"owner": owner}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to find conversations, error: %v", err)
+	}
+
+	conversations := []models.Conversation{}
+	if err := cursor.All(ctx, &conversations); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode conversations, error: %v", err)
+	}
+
+	return conversations, nil
+}
+
+func (db *MondgodbController) DeleteConversation(ctx context.Context, owner string, id string) error {
+	result, err := db.conversations.DeleteOne(ctx, bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return fmt.Errorf("mongodb: failed to delete conversation, error: %v", err)
+	}
+
+	// Never touch messages if the conversation belongs to someone else.
+	if result.DeletedCount == 0 {
+		return nil
+	}
+
+	if _, err := db.messages.DeleteMany(ctx, bson.M{"conversation_id": id}); err != nil {
+		return fmt.Errorf("mongodb: failed to delete messages, error: %v", err)
+	}
+
+	return nil
+}
+
+func (db *MondgodbController) AddMessage(ctx context.Context, message *models.ConversationMessage) error {
+	if _, err := db.messages.InsertOne(ctx, message); err != nil {
+		return fmt.Errorf("mongodb: failed to add message, error: %v", err)
+	}
+
+	update := bson.M{"$set": bson.M{"updated_at": message.CreatedAt}}
+	if _, err := db.conversations.UpdateByID(ctx, message.ConversationID, update); err != nil {
+		return fmt.Errorf("mongodb: failed to update conversation, error: %v", err)
+	}
+
+	return nil
+}
+
+func (db *MondgodbController) GetMessages(ctx context.Context, conversationID string) ([]models.ConversationMessage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := db.messages.Find(ctx, bson.M{"conversation_id": conversationID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to find messages, error: %v", err)
+	}
+
+	messages := []models.ConversationMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode messages, error: %v", err)
+	}
+
+	return messages, nil
+}
+
+func (db *MondgodbController) DeleteMessages(ctx context.Context, conversationID string, ids []string) error {
+	filter := bson.M{"conversation_id": conversationID, "_id": bson.M{"$in": ids}}
+	if _, err := db.messages.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("mongodb: failed to delete messages, error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func (db *MondgodbController) CreateDocument(ctx context.Context, document *models.Document) error {
+	if _, err := db.documents.InsertOne(ctx, document); err != nil {
+		return fmt.Errorf("mongodb: failed to create document, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) GetDocument(ctx context.Context, owner string, id string) (*models.Document, error) {
+	var document models.Document
+	if err := db.documents.FindOne(ctx, bson.M{"_id": id, "owner": owner}).Decode(&document); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find document, error: %v", err)
+	}
+	return &document, nil
+}
+
+func (db *MondgodbController) ListDocuments(ctx context.Context, owner string, collection string) ([]models.Document, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	filter := bson.M{"owner": owner}
+	if collection != "" {
+		filter["collection"] = collection
+	}
+
+	cursor, err := db.documents.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to find documents, error: %v", err)
+	}
+
+	documents := []models.Document{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode documents, error: %v", err)
+	}
+
+	return documents, nil
+}
+
+func (db *MondgodbController) DeleteDocument(ctx context.Context, owner string, id string) error {
+	if _, err := db.documents.DeleteOne(ctx, bson.M{"_id": id, "owner": owner}); err != nil {
+		return fmt.Errorf("mongodb: failed to delete document, error: %v", err)
+	}
+	return nil
+}
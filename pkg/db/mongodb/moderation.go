@@ -0,0 +1,15 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func (db *MondgodbController) AddModerationEvent(ctx context.Context, event *models.ModerationEvent) error {
+	if _, err := db.moderationEvents.InsertOne(ctx, event); err != nil {
+		return fmt.Errorf("mongodb: failed to add moderation event, error: %v", err)
+	}
+	return nil
+}
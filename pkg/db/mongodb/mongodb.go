@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
@@ -20,6 +22,21 @@ type MondgodbController struct {
 	// TODO: Do we need to store a collection?
 	// Most likely we only need a client.
 	collection *mongo.Collection
+
+	conversations *mongo.Collection
+	messages      *mongo.Collection
+	documents     *mongo.Collection
+	usage         *mongo.Collection
+	// Flagged prompts and answers, kept for review.
+	moderationEvents *mongo.Collection
+
+	promptTemplates        *mongo.Collection
+	promptTemplateVersions *mongo.Collection
+
+	assistants *mongo.Collection
+
+	refreshTokens       *mongo.Collection
+	passwordResetTokens *mongo.Collection
 }
 
 func (db *MondgodbController) Close(ctx context.Context) error {
@@ -45,11 +62,41 @@ func NewMongodbController(ctx context.Context) (*MondgodbController, error) {
 	// 	return nil, fmt.Errorf("mongodb: server is unavailable, error: %v", err)
 	// }
 
-	usersCollection := client.Database("users_database").Collection("users")
+	database := client.Database("users_database")
+
+	messages := database.Collection("messages")
+	if _, err := messages.Indexes().CreateOne(ctx, messagesTextIndex); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to create text index on messages, error: %v", err)
+	}
+
+	promptTemplateVersions := database.Collection("prompt_template_versions")
+	if _, err := promptTemplateVersions.Indexes().CreateOne(ctx, templateVersionsIndex); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to create index on prompt template versions, error: %v", err)
+	}
+
+	refreshTokens := database.Collection("refresh_tokens")
+	if _, err := refreshTokens.Indexes().CreateMany(ctx, refreshTokensIndexes); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to create indexes on refresh tokens, error: %v", err)
+	}
+
+	passwordResetTokens := database.Collection("password_reset_tokens")
+	if _, err := passwordResetTokens.Indexes().CreateOne(ctx, passwordResetTokensIndex); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to create index on password reset tokens, error: %v", err)
+	}
 
 	return &MondgodbController{
-		collection: usersCollection,
-		client:     client,
+		collection:             database.Collection("users"),
+		conversations:          database.Collection("conversations"),
+		messages:               messages,
+		documents:              database.Collection("documents"),
+		usage:                  database.Collection("usage"),
+		moderationEvents:       database.Collection("moderation_events"),
+		promptTemplates:        database.Collection("prompt_templates"),
+		promptTemplateVersions: promptTemplateVersions,
+		assistants:             database.Collection("assistants"),
+		refreshTokens:          refreshTokens,
+		passwordResetTokens:    passwordResetTokens,
+		client:                 client,
 	}, nil
 }
 
@@ -68,9 +115,29 @@ func (db *MondgodbController) AddUser(ctx context.Context, userData *models.User
 	return nil
 }
 
+// Users added before emails were verified don't have the field and count as verified,
+// the decoder leaves the fields missing from the document as they are.
+func decodeUser(result *mongo.SingleResult) (*models.UserData, error) {
+	user := models.UserData{EmailVerified: true}
+	if err := result.Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// userIDFilter matches the user with the id. Users added before they had IDs were given an ObjectID,
+// which is decoded as its hex string.
+func userIDFilter(id string) bson.M {
+	ids := bson.A{id}
+	if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+		ids = append(ids, objectID)
+	}
+	return bson.M{"_id": bson.M{"$in": ids}}
+}
+
 func (db *MondgodbController) GetUserByEmail(ctx context.Context, email string) (*models.UserData, error) {
-	var result models.UserData
-	if err := db.collection.FindOne(ctx, bson.M{"email": email}).Decode(&result); err != nil {
+	result, err := decodeUser(db.collection.FindOne(ctx, bson.M{"email": email}))
+	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			// NOTE: It's not an error if a user is not found,
 			// so we just return nil for the user and for the error.
@@ -79,11 +146,103 @@ func (db *MondgodbController) GetUserByEmail(ctx context.Context, email string)
 		}
 		return nil, err
 	}
-	return &result, nil
+	return result, nil
+}
+
+func (db *MondgodbController) GetUserByID(ctx context.Context, id string) (*models.UserData, error) {
+	result, err := decodeUser(db.collection.FindOne(ctx, userIDFilter(id)))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find user, error: %v", err)
+	}
+	return result, nil
 }
 
-func (db *MondgodbController) GetUserByID(ctx context.Context, id int) (*models.UserData, error) {
-	// TODO: We would have to reconsider this function since an id in mongo's collection
-	// is represented as a string.
-	return nil, nil
+func (db *MondgodbController) MarkEmailVerified(ctx context.Context, id string) error {
+	if _, err := db.collection.UpdateOne(ctx, userIDFilter(id), bson.M{"$set": bson.M{"email_verified": true}}); err != nil {
+		return fmt.Errorf("mongodb: failed to mark email verified, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) UpdatePassword(ctx context.Context, id string, password string) error {
+	if _, err := db.collection.UpdateOne(ctx, userIDFilter(id), bson.M{"$set": bson.M{"password": password}}); err != nil {
+		return fmt.Errorf("mongodb: failed to update password, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) RecordVerificationEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	filter := userIDFilter(id)
+	filter["$or"] = bson.A{
+		bson.M{"verification_sent_at": bson.M{"$exists": false}},
+		bson.M{"verification_sent_at": bson.M{"$lte": sentAt.Add(-interval)}},
+	}
+
+	result, err := db.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"verification_sent_at": sentAt}})
+	if err != nil {
+		return false, fmt.Errorf("mongodb: failed to record verification email, error: %v", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (db *MondgodbController) RecordPasswordResetEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	filter := userIDFilter(id)
+	filter["$or"] = bson.A{
+		bson.M{"password_reset_sent_at": bson.M{"$exists": false}},
+		bson.M{"password_reset_sent_at": bson.M{"$lte": sentAt.Add(-interval)}},
+	}
+
+	result, err := db.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"password_reset_sent_at": sentAt}})
+	if err != nil {
+		return false, fmt.Errorf("mongodb: failed to record password reset email, error: %v", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// MongoDB can't update across collections, so the distinct owners which look like emails
+// are looked up among the users. IDs never contain an @, so the query matches nothing once migrated.
+func (db *MondgodbController) MigrateOwners(ctx context.Context) error {
+	updates := []struct {
+		collection *mongo.Collection
+		field      string
+	}{
+		{db.conversations, "owner"},
+		{db.documents, "owner"},
+		{db.usage, "owner"},
+		{db.moderationEvents, "owner"},
+		{db.promptTemplates, "owner"},
+		{db.promptTemplateVersions, "created_by"},
+		{db.assistants, "owner"},
+		{db.refreshTokens, "owner"},
+	}
+
+	for _, update := range updates {
+		emails, err := update.collection.Distinct(ctx, update.field, bson.M{update.field: bson.M{"$regex": "@"}})
+		if err != nil {
+			return fmt.Errorf("mongodb: failed to retrieve owners, error: %v", err)
+		}
+
+		for _, email := range emails {
+			email, ok := email.(string)
+			if !ok {
+				continue
+			}
+			user, err := db.GetUserByEmail(ctx, email)
+			if err != nil {
+				return fmt.Errorf("mongodb: failed to retrieve owner, error: %v", err)
+			}
+			if user == nil {
+				continue
+			}
+
+			filter := bson.M{update.field: email}
+			if _, err := update.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{update.field: user.ID}}); err != nil {
+				return fmt.Errorf("mongodb: failed to migrate owners, error: %v", err)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,112 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/search"
+)
+
+// A collection can have only one text index, this one covers the content of messages.
+var messagesTextIndex = mongo.IndexModel{
+	Keys: bson.D{{Key: "content", Value: "text"}},
+}
+
+func (db *MondgodbController) SearchMessages(ctx context.Context, owner string, query *models.MessageSearchQuery) ([]models.MessageSearchHit, error) {
+	// Messages don't know their owner, so the search is limited to the ids of the owner's conversations.
+	conversations, err := db.ListConversations(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, nil
+	}
+
+	titles := make(map[string]string, len(conversations))
+	ids := make([]string, 0, len(conversations))
+	for _, conversation := range conversations {
+		titles[conversation.ID] = conversation.Title
+		ids = append(ids, conversation.ID)
+	}
+
+	// $text has to be a part of the first stage of the pipeline.
+	match := bson.M{
+		"$text":           bson.M{"$search": query.Text},
+		"conversation_id": bson.M{"$in": ids},
+	}
+	createdAt := bson.M{}
+	if !query.From.IsZero() {
+		createdAt["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		createdAt["$lt"] = query.To
+	}
+	if len(createdAt) > 0 {
+		match["created_at"] = createdAt
+	}
+	if query.Model != "" {
+		match["model"] = query.Model
+	}
+	if query.Role != "" {
+		match["role"] = query.Role
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"rank": bson.M{"$meta": "textScore"}}}},
+	}
+	if after := query.After; after != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"rank": bson.M{"$lt": after.Rank}},
+			bson.M{"rank": after.Rank, "created_at": bson.M{"$lt": after.CreatedAt}},
+			bson.M{"rank": after.Rank, "created_at": after.CreatedAt, "_id": bson.M{"$gt": after.MessageID}},
+		}}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "rank", Value: -1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: query.Limit}},
+	)
+
+	cursor, err := db.messages.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to search messages, error: %v", err)
+	}
+
+	var found []struct {
+		ID             string    `bson:"_id"`
+		ConversationID string    `bson:"conversation_id"`
+		Role           string    `bson:"role"`
+		Content        string    `bson:"content"`
+		Model          string    `bson:"model"`
+		CreatedAt      time.Time `bson:"created_at"`
+		Rank           float64   `bson:"rank"`
+	}
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode messages, error: %v", err)
+	}
+
+	// Mongodb doesn't highlight the matches, and stems words differently,
+	// so the snippets only mark the words which start with a term of the query.
+	terms := search.Terms(query.Text)
+
+	hits := make([]models.MessageSearchHit, 0, len(found))
+	for _, message := range found {
+		hits = append(hits, models.MessageSearchHit{
+			ConversationID:    message.ConversationID,
+			ConversationTitle: titles[message.ConversationID],
+			MessageID:         message.ID,
+			Role:              message.Role,
+			Model:             message.Model,
+			Snippet:           search.Snippet(message.Content, terms),
+			Rank:              message.Rank,
+			CreatedAt:         message.CreatedAt,
+		})
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,144 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Rejects a version added concurrently with the same number.
+var templateVersionsIndex = mongo.IndexModel{
+	Keys:    bson.D{{Key: "template_id", Value: 1}, {Key: "version", Value: 1}},
+	Options: options.Index().SetUnique(true),
+}
+
+// templateVisible returns the filter matching the templates visible to the owner and the organisation.
+func templateVisible(owner string, organization string) bson.M {
+	if organization == "" {
+		return bson.M{"owner": owner}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"owner": owner},
+		bson.M{"organization": organization, "visibility": models.TemplateOrganization},
+	}}
+}
+
+// NOTE: The template and its first version are inserted without a transaction,
+// transactions require a replica set. A template without versions is never returned,
+// since the version is inserted first.
+func (db *MondgodbController) CreatePromptTemplate(ctx context.Context, template *models.PromptTemplate, version *models.PromptTemplateVersion) error {
+	if _, err := db.promptTemplateVersions.InsertOne(ctx, version); err != nil {
+		return fmt.Errorf("mongodb: failed to add prompt template version, error: %v", err)
+	}
+	if _, err := db.promptTemplates.InsertOne(ctx, template); err != nil {
+		return fmt.Errorf("mongodb: failed to create prompt template, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) GetPromptTemplate(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error) {
+	filter := bson.M{"$and": bson.A{bson.M{"_id": id}, templateVisible(owner, organization)}}
+
+	var template models.PromptTemplate
+	if err := db.promptTemplates.FindOne(ctx, filter).Decode(&template); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find prompt template, error: %v", err)
+	}
+	return &template, nil
+}
+
+func (db *MondgodbController) ListPromptTemplates(ctx context.Context, owner string, organization string) ([]models.PromptTemplate, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := db.promptTemplates.Find(ctx, templateVisible(owner, organization), opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to find prompt templates, error: %v", err)
+	}
+
+	templates := []models.PromptTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode prompt templates, error: %v", err)
+	}
+
+	return templates, nil
+}
+
+func (db *MondgodbController) UpdatePromptTemplate(ctx context.Context, template *models.PromptTemplate) error {
+	update := bson.M{"$set": bson.M{
+		"name":         template.Name,
+		"description":  template.Description,
+		"visibility":   template.Visibility,
+		"organization": template.Organization,
+		"updated_at":   template.UpdatedAt,
+	}}
+	if _, err := db.promptTemplates.UpdateOne(ctx, bson.M{"_id": template.ID, "owner": template.Owner}, update); err != nil {
+		return fmt.Errorf("mongodb: failed to update prompt template, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) DeletePromptTemplate(ctx context.Context, owner string, id string) error {
+	result, err := db.promptTemplates.DeleteOne(ctx, bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return fmt.Errorf("mongodb: failed to delete prompt template, error: %v", err)
+	}
+
+	// Never touch versions if the template belongs to someone else.
+	if result.DeletedCount == 0 {
+		return nil
+	}
+
+	if _, err := db.promptTemplateVersions.DeleteMany(ctx, bson.M{"template_id": id}); err != nil {
+		return fmt.Errorf("mongodb: failed to delete prompt template versions, error: %v", err)
+	}
+
+	return nil
+}
+
+func (db *MondgodbController) AddPromptTemplateVersion(ctx context.Context, version *models.PromptTemplateVersion) error {
+	if _, err := db.promptTemplateVersions.InsertOne(ctx, version); err != nil {
+		return fmt.Errorf("mongodb: failed to add prompt template version, error: %v", err)
+	}
+
+	update := bson.M{"$set": bson.M{"latest_version": version.Version, "updated_at": version.CreatedAt}}
+	if _, err := db.promptTemplates.UpdateByID(ctx, version.TemplateID, update); err != nil {
+		return fmt.Errorf("mongodb: failed to update prompt template, error: %v", err)
+	}
+
+	return nil
+}
+
+func (db *MondgodbController) GetPromptTemplateVersion(ctx context.Context, templateID string, version int) (*models.PromptTemplateVersion, error) {
+	var templateVersion models.PromptTemplateVersion
+	filter := bson.M{"template_id": templateID, "version": version}
+	if err := db.promptTemplateVersions.FindOne(ctx, filter).Decode(&templateVersion); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find prompt template version, error: %v", err)
+	}
+	return &templateVersion, nil
+}
+
+func (db *MondgodbController) ListPromptTemplateVersions(ctx context.Context, templateID string) ([]models.PromptTemplateVersion, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+
+	cursor, err := db.promptTemplateVersions.Find(ctx, bson.M{"template_id": templateID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to find prompt template versions, error: %v", err)
+	}
+
+	versions := []models.PromptTemplateVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode prompt template versions, error: %v", err)
+	}
+
+	return versions, nil
+}
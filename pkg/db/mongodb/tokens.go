@@ -0,0 +1,100 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Expired tokens are removed by mongodb itself, and families are revoked as a whole.
+var refreshTokensIndexes = []mongo.IndexModel{
+	{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	},
+	{
+		Keys: bson.D{{Key: "family", Value: 1}},
+	},
+}
+
+// Expired password reset tokens are removed by mongodb as well.
+var passwordResetTokensIndex = mongo.IndexModel{
+	Keys:    bson.D{{Key: "expires_at", Value: 1}},
+	Options: options.Index().SetExpireAfterSeconds(0),
+}
+
+func (db *MondgodbController) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	if _, err := db.refreshTokens.InsertOne(ctx, token); err != nil {
+		return fmt.Errorf("mongodb: failed to create refresh token, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := db.refreshTokens.FindOne(ctx, bson.M{"_id": id}).Decode(&token); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to find refresh token, error: %v", err)
+	}
+	return &token, nil
+}
+
+func (db *MondgodbController) RotateRefreshToken(ctx context.Context, id string, rotatedAt time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "rotated_at": bson.M{"$exists": false}, "revoked": false}
+	result, err := db.refreshTokens.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"rotated_at": rotatedAt}})
+	if err != nil {
+		return false, fmt.Errorf("mongodb: failed to rotate refresh token, error: %v", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (db *MondgodbController) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	if _, err := db.refreshTokens.UpdateMany(ctx, bson.M{"family": family}, bson.M{"$set": bson.M{"revoked": true}}); err != nil {
+		return fmt.Errorf("mongodb: failed to revoke refresh tokens, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) RevokeRefreshTokens(ctx context.Context, owner string) error {
+	if _, err := db.refreshTokens.UpdateMany(ctx, bson.M{"owner": owner}, bson.M{"$set": bson.M{"revoked": true}}); err != nil {
+		return fmt.Errorf("mongodb: failed to revoke refresh tokens, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	if _, err := db.passwordResetTokens.InsertOne(ctx, token); err != nil {
+		return fmt.Errorf("mongodb: failed to create password reset token, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) UsePasswordResetToken(ctx context.Context, id string, usedAt time.Time) (*models.PasswordResetToken, error) {
+	filter := bson.M{"_id": id, "used_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": usedAt}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var token models.PasswordResetToken
+	err := db.passwordResetTokens.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"used_at": usedAt}}, opts).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("mongodb: failed to use password reset token, error: %v", err)
+	}
+	return &token, nil
+}
+
+func (db *MondgodbController) DeletePasswordResetTokens(ctx context.Context, owner string) error {
+	if _, err := db.passwordResetTokens.DeleteMany(ctx, bson.M{"owner": owner}); err != nil {
+		return fmt.Errorf("mongodb: failed to delete password reset tokens, error: %v", err)
+	}
+	return nil
+}
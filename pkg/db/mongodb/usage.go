@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func (db *MondgodbController) AddUsage(ctx context.Context, record *models.UsageRecord) error {
+	if _, err := db.usage.InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("mongodb: failed to add usage, error: %v", err)
+	}
+	return nil
+}
+
+func (db *MondgodbController) GetUsage(ctx context.Context, owner string, from time.Time, to time.Time) ([]models.ModelUsage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"owner": owner, "created_at": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               "$model",
+			"requests":          bson.M{"$sum": 1},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+			"cost":              bson.M{"$sum": "$cost"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := db.usage.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: failed to aggregate usage, error: %v", err)
+	}
+
+	var groups []struct {
+		Model            string  `bson:"_id"`
+		Requests         int     `bson:"requests"`
+		PromptTokens     int     `bson:"prompt_tokens"`
+		CompletionTokens int     `bson:"completion_tokens"`
+		Cost             float64 `bson:"cost"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("mongodb: failed to decode usage, error: %v", err)
+	}
+
+	usage := make([]models.ModelUsage, 0, len(groups))
+	for _, group := range groups {
+		usage = append(usage, models.ModelUsage(group))
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var assistantTables = []string{
+	`CREATE TABLE IF NOT EXISTS "assistants" (
+		"id" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"name" VARCHAR(256) NOT NULL,
+		"description" TEXT NOT NULL,
+		"params" JSONB NOT NULL,
+		"collections" TEXT[] NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		"updated_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "assistants_owner_idx" ON "assistants" ("owner", "updated_at" DESC);`,
+}
+
+func scanAssistant(row pgx.CollectableRow) (models.Assistant, error) {
+	var a models.Assistant
+	var params []byte
+	if err := row.Scan(&a.ID, &a.Owner, &a.Name, &a.Description, &params, &a.Collections, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal(params, &a.Params); err != nil {
+		return a, fmt.Errorf("invalid params of assistant %s: %v", a.ID, err)
+	}
+	return a, nil
+}
+
+func (pc *PostgresController) CreateAssistant(ctx context.Context, assistant *models.Assistant) error {
+	params, err := json.Marshal(&assistant.Params)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to marshal assistant params, error: %v", err)
+	}
+
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `INSERT INTO "assistants" (
+		"id", "owner", "name", "description", "params", "collections", "created_at", "updated_at"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	if _, err := conn.Exec(ctx, query, assistant.ID, assistant.Owner, assistant.Name, assistant.Description,
+		params, assistant.Collections, assistant.CreatedAt, assistant.UpdatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to create assistant, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetAssistant(ctx context.Context, owner string, id string) (*models.Assistant, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "name", "description", "params", "collections", "created_at", "updated_at"
+	FROM "assistants" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	rows, _ := conn.Query(ctx, query, id, owner)
+	assistant, err := pgx.CollectOneRow(rows, scanAssistant)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to select assistant, error: %v", err)
+	}
+
+	return &assistant, nil
+}
+
+func (pc *PostgresController) ListAssistants(ctx context.Context, owner string) ([]models.Assistant, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "name", "description", "params", "collections", "created_at", "updated_at"
+	FROM "assistants" WHERE "owner" = ($1) ORDER BY "updated_at" DESC;`
+
+	rows, _ := conn.Query(ctx, query, owner)
+	assistants, err := pgx.CollectRows(rows, scanAssistant)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select assistants, error: %v", err)
+	}
+
+	return assistants, nil
+}
+
+func (pc *PostgresController) UpdateAssistant(ctx context.Context, assistant *models.Assistant) error {
+	params, err := json.Marshal(&assistant.Params)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to marshal assistant params, error: %v", err)
+	}
+
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `UPDATE "assistants" SET "name" = ($1), "description" = ($2), "params" = ($3), "collections" = ($4), "updated_at" = ($5)
+	WHERE "id" = ($6) AND "owner" = ($7);`
+
+	if _, err := conn.Exec(ctx, query, assistant.Name, assistant.Description, params, assistant.Collections,
+		assistant.UpdatedAt, assistant.ID, assistant.Owner); err != nil {
+		return fmt.Errorf("postgres: failed to update assistant, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) DeleteAssistant(ctx context.Context, owner string, id string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `DELETE FROM "assistants" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	if _, err := conn.Exec(ctx, query, id, owner); err != nil {
+		return fmt.Errorf("postgres: failed to delete assistant, error: %v", err)
+	}
+
+	return nil
+}
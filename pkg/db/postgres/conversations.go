@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var conversationTables = []string{
+	`CREATE TABLE IF NOT EXISTS "conversations" (
+		"id" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"title" VARCHAR(256) NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		"updated_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "conversations_owner_idx" ON "conversations" ("owner", "updated_at" DESC);`,
+	`CREATE TABLE IF NOT EXISTS "messages" (
+		"id" VARCHAR(36) NOT NULL,
+		"conversation_id" VARCHAR(36) NOT NULL REFERENCES "conversations"("id") ON DELETE CASCADE,
+		"role" VARCHAR(32) NOT NULL,
+		"content" TEXT NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "messages_conversation_idx" ON "messages" ("conversation_id", "created_at");`,
+	`ALTER TABLE "messages" ADD COLUMN IF NOT EXISTS "model" VARCHAR(128) NOT NULL DEFAULT '';`,
+	`ALTER TABLE "messages" ADD COLUMN IF NOT EXISTS "tool_calls" JSONB;`,
+	`ALTER TABLE "messages" ADD COLUMN IF NOT EXISTS "tool_call_id" VARCHAR(64) NOT NULL DEFAULT '';`,
+	`ALTER TABLE "conversations" ADD COLUMN IF NOT EXISTS "assistant_id" VARCHAR(36) NOT NULL DEFAULT '';`,
+}
+
+func scanConversation(row pgx.CollectableRow) (models.Conversation, error) {
+	var c models.Conversation
+	err := row.Scan(&c.ID, &c.Owner, &c.Title, &c.AssistantID, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+func scanMessage(row pgx.CollectableRow) (models.ConversationMessage, error) {
+	var m models.ConversationMessage
+	var toolCalls []byte
+	if err := row.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.Model, &toolCalls, &m.ToolCallID, &m.CreatedAt); err != nil {
+		return m, err
+	}
+	if toolCalls != nil {
+		if err := json.Unmarshal(toolCalls, &m.ToolCalls); err != nil {
+			return m, fmt.Errorf("invalid tool calls of message %s: %v", m.ID, err)
+		}
+	}
+	return m, nil
+}
+
+func (pc *PostgresController) CreateConversation(ctx context.Context, conversation *models.Conversation) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `INSERT INTO "conversations" (
+		"id", "owner", "title", "assistant_id", "created_at", "updated_at"
+	) VALUES ($1, $2, $3, $4, $5, $6);`
+
+	if _, err := conn.Exec(ctx, query, conversation.ID, conversation.Owner, conversation.Title,
+		conversation.AssistantID, conversation.CreatedAt, conversation.UpdatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to create conversation, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetConversation(ctx context.Context, owner string, id string) (*models.Conversation, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "title", "assistant_id", "created_at", "updated_at"
+	FROM "conversations" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	rows, _ := conn.Query(ctx, query, id, owner)
+	conversation, err := pgx.CollectOneRow(rows, scanConversation)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to select conversation, error: %v", err)
+	}
+
+	return &conversation, nil
+}
+
+func (pc *PostgresController) ListConversations(ctx context.Context, owner string) ([]models.Conversation, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "title", "assistant_id", "created_at", "updated_at"
+	FROM "conversations" WHERE "owner" = ($1) ORDER BY "updated_at" DESC;`
+
+	rows, _ := conn.Query(ctx, query, owner)
+	conversations, err := pgx.CollectRows(rows, scanConversation)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select conversations, error: %v", err)
+	}
+
+	return conversations, nil
+}
+
+func (pc *PostgresController) DeleteConversation(ctx context.Context, owner string, id string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	// Messages are removed by the ON DELETE CASCADE constraint.
+	query := `DELETE FROM "conversations" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	if _, err := conn.Exec(ctx, query, id, owner); err != nil {
+		return fmt.Errorf("postgres: failed to delete conversation, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) AddMessage(ctx context.Context, message *models.ConversationMessage) error {
+	tx, err := pc.connPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to begin transaction, error: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// NULL rather than an empty json array when there are no tool calls.
+	var toolCalls []byte
+	if len(message.ToolCalls) > 0 {
+		toolCalls, err = json.Marshal(message.ToolCalls)
+		if err != nil {
+			return fmt.Errorf("postgres: failed to marshal tool calls, error: %v", err)
+		}
+	}
+
+	query := `INSERT INTO "messages" (
+		"id", "conversation_id", "role", "content", "model", "tool_calls", "tool_call_id", "created_at"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	if _, err := tx.Exec(ctx, query, message.ID, message.ConversationID, message.Role,
+		message.Content, message.Model, toolCalls, message.ToolCallID, message.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to add message, error: %v", err)
+	}
+
+	query = `UPDATE "conversations" SET "updated_at" = ($1) WHERE "id" = ($2);`
+
+	if _, err := tx.Exec(ctx, query, message.CreatedAt, message.ConversationID); err != nil {
+		return fmt.Errorf("postgres: failed to update conversation, error: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: failed to commit transaction, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetMessages(ctx context.Context, conversationID string) ([]models.ConversationMessage, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "conversation_id", "role", "content", "model", "tool_calls", "tool_call_id", "created_at"
+	FROM "messages" WHERE "conversation_id" = ($1) ORDER BY "created_at" ASC;`
+
+	rows, _ := conn.Query(ctx, query, conversationID)
+	messages, err := pgx.CollectRows(rows, scanMessage)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select messages, error: %v", err)
+	}
+
+	return messages, nil
+}
+
+func (pc *PostgresController) DeleteMessages(ctx context.Context, conversationID string, ids []string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `DELETE FROM "messages" WHERE "conversation_id" = ($1) AND "id" = ANY($2);`
+
+	if _, err := conn.Exec(ctx, query, conversationID, ids); err != nil {
+		return fmt.Errorf("postgres: failed to delete messages, error: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var documentTables = []string{
+	`CREATE TABLE IF NOT EXISTS "documents" (
+		"id" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"collection" VARCHAR(128) NOT NULL,
+		"name" VARCHAR(256) NOT NULL,
+		"content_type" VARCHAR(64) NOT NULL,
+		"size" INTEGER NOT NULL,
+		"chunks" INTEGER NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "documents_owner_idx" ON "documents" ("owner", "collection", "created_at" DESC);`,
+}
+
+func scanDocument(row pgx.CollectableRow) (models.Document, error) {
+	var d models.Document
+	err := row.Scan(&d.ID, &d.Owner, &d.Collection, &d.Name, &d.ContentType, &d.Size, &d.Chunks, &d.CreatedAt)
+	return d, err
+}
+
+func (pc *PostgresController) CreateDocument(ctx context.Context, document *models.Document) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `INSERT INTO "documents" (
+		"id", "owner", "collection", "name", "content_type", "size", "chunks", "created_at"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	if _, err := conn.Exec(ctx, query, document.ID, document.Owner, document.Collection, document.Name,
+		document.ContentType, document.Size, document.Chunks, document.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to create document, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetDocument(ctx context.Context, owner string, id string) (*models.Document, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "collection", "name", "content_type", "size", "chunks", "created_at"
+	FROM "documents" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	rows, _ := conn.Query(ctx, query, id, owner)
+	document, err := pgx.CollectOneRow(rows, scanDocument)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to select document, error: %v", err)
+	}
+
+	return &document, nil
+}
+
+func (pc *PostgresController) ListDocuments(ctx context.Context, owner string, collection string) ([]models.Document, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "collection", "name", "content_type", "size", "chunks", "created_at"
+	FROM "documents" WHERE "owner" = ($1) AND (($2) = '' OR "collection" = ($2)) ORDER BY "created_at" DESC;`
+
+	rows, _ := conn.Query(ctx, query, owner, collection)
+	documents, err := pgx.CollectRows(rows, scanDocument)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select documents, error: %v", err)
+	}
+
+	return documents, nil
+}
+
+func (pc *PostgresController) DeleteDocument(ctx context.Context, owner string, id string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `DELETE FROM "documents" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	if _, err := conn.Exec(ctx, query, id, owner); err != nil {
+		return fmt.Errorf("postgres: failed to delete document, error: %v", err)
+	}
+
+	return nil
+}
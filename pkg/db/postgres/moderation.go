@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var moderationTables = []string{
+	`CREATE TABLE IF NOT EXISTS "moderation_events" (
+		"id" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"conversation_id" VARCHAR(36) NOT NULL,
+		"stage" VARCHAR(16) NOT NULL,
+		"categories" TEXT[] NOT NULL,
+		"blocked" BOOLEAN NOT NULL,
+		"text" TEXT NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "moderation_events_created_at_idx" ON "moderation_events" ("created_at" DESC);`,
+}
+
+func (pc *PostgresController) AddModerationEvent(ctx context.Context, event *models.ModerationEvent) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `INSERT INTO "moderation_events" (
+		"id", "owner", "conversation_id", "stage", "categories", "blocked", "text", "created_at"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	if _, err := conn.Exec(ctx, query, event.ID, event.Owner, event.ConversationID, event.Stage,
+		event.Categories, event.Blocked, event.Text, event.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to add moderation event, error: %v", err)
+	}
+
+	return nil
+}
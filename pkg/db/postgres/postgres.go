@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -68,7 +69,7 @@ func (pc *PostgresController) createTable(ctx context.Context) error {
 	// "updated_at" TIMESTAMP NOT NULL,
 
 	query := `CREATE TABLE IF NOT EXISTS "users" (
-		"id" SERIAL, 
+		"id" VARCHAR(36) NOT NULL, 
 		"first_name" VARCHAR(64) NOT NULL, 
 		"last_name" VARCHAR(64) NOT NULL,
 		"email" VARCHAR(320) NOT NULL UNIQUE,
@@ -76,6 +77,9 @@ func (pc *PostgresController) createTable(ctx context.Context) error {
 		"country" VARCHAR(64) NOT NULL, 
 		"city" VARCHAR(64) NOT NULL, 
 		"country_code" VARCHAR(32) NOT NULL,
+		"email_verified" BOOLEAN NOT NULL,
+		"verification_sent_at" TIMESTAMPTZ,
+		"password_reset_sent_at" TIMESTAMPTZ,
 		PRIMARY KEY("id")
 	);`
 
@@ -83,11 +87,57 @@ func (pc *PostgresController) createTable(ctx context.Context) error {
 		return fmt.Errorf("postgres: failed to create a table, error: %v", err)
 	}
 
+	// Users used to be identified by a serial, they get a random UUID instead.
+	if _, err := conn.Exec(ctx, migrateUserIDs); err != nil {
+		return fmt.Errorf("postgres: failed to migrate user ids, error: %v", err)
+	}
+
+	// The users who signed up before emails were verified count as verified.
+	for _, query := range []string{
+		`ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified" BOOLEAN NOT NULL DEFAULT TRUE;`,
+		`ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "verification_sent_at" TIMESTAMPTZ;`,
+		`ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "password_reset_sent_at" TIMESTAMPTZ;`,
+	} {
+		if _, err := conn.Exec(ctx, query); err != nil {
+			return fmt.Errorf("postgres: failed to migrate users, error: %v", err)
+		}
+	}
+
+	for _, query := range slices.Concat(conversationTables, searchTables, documentTables, usageTables, moderationTables, templateTables, assistantTables, refreshTokenTables, passwordResetTokenTables) {
+		if _, err := conn.Exec(ctx, query); err != nil {
+			return fmt.Errorf("postgres: failed to create a table, error: %v", err)
+		}
+	}
+
 	log.Logger.Info("Successfully initialized postgres database controller")
 
 	return nil
 }
 
+const migrateUserIDs = `DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE "table_name" = 'users' AND "column_name" = 'id' AND "data_type" = 'integer'
+	) THEN
+		ALTER TABLE "users" ALTER COLUMN "id" DROP DEFAULT;
+		ALTER TABLE "users" ALTER COLUMN "id" TYPE VARCHAR(36) USING gen_random_uuid()::text;
+		DROP SEQUENCE IF EXISTS "users_id_seq";
+	END IF;
+END $$;`
+
+// Tables with the column holding the owner, see MigrateOwners.
+var ownedTables = [][2]string{
+	{"conversations", "owner"},
+	{"documents", "owner"},
+	{"usage", "owner"},
+	{"moderation_events", "owner"},
+	{"prompt_templates", "owner"},
+	{"prompt_template_versions", "created_by"},
+	{"assistants", "owner"},
+	{"refresh_tokens", "owner"},
+}
+
 func (pc *PostgresController) AddUser(ctx context.Context, userData *models.UserData, geolocation *models.Geolocation) error {
 	conn, err := pc.connPool.Acquire(ctx)
 	if err != nil {
@@ -97,12 +147,13 @@ func (pc *PostgresController) AddUser(ctx context.Context, userData *models.User
 	defer conn.Release()
 
 	query := `INSERT INTO "users" (
-		"first_name", "last_name", "email", "password", 
-		"country", "city", "country_code"
-	) values ($1, $2, $3, $4, $5, $6, $7);`
+		"id", "first_name", "last_name", "email", "password", 
+		"country", "city", "country_code", "email_verified", "verification_sent_at"
+	) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
 
-	if _, err := conn.Exec(ctx, query, userData.FirstName, userData.LastName,
-		userData.Email, userData.Password, geolocation.Country, geolocation.City, geolocation.CountryCode); err != nil {
+	if _, err := conn.Exec(ctx, query, userData.ID, userData.FirstName, userData.LastName,
+		userData.Email, userData.Password, geolocation.Country, geolocation.City, geolocation.CountryCode,
+		userData.EmailVerified, userData.VerificationSentAt); err != nil {
 		return fmt.Errorf("postgres: failed to add user, error: %v", err)
 	}
 
@@ -118,7 +169,7 @@ func (pc *PostgresController) GetUserByEmail(ctx context.Context, email string)
 	defer conn.Release()
 
 	query := `SELECT 
-	"first_name", "last_name", "email", "password", "country", "city"
+	"id", "first_name", "last_name", "email", "password", "email_verified", "verification_sent_at"
 	FROM "users" WHERE "email" = ($1);`
 
 	rows, _ := conn.Query(ctx, query, email)
@@ -137,9 +188,7 @@ func (pc *PostgresController) GetUserByEmail(ctx context.Context, email string)
 	return &users[0], nil
 }
 
-// NOTE: This method will be used when a refresh token contains an issuer.
-// TODO: This has to be tested properly.
-func (pc *PostgresController) GetUserByID(ctx context.Context, id int) (*models.UserData, error) {
+func (pc *PostgresController) GetUserByID(ctx context.Context, id string) (*models.UserData, error) {
 	conn, err := pc.connPool.Acquire(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
@@ -148,8 +197,8 @@ func (pc *PostgresController) GetUserByID(ctx context.Context, id int) (*models.
 	defer conn.Release()
 
 	query := `SELECT 
-	"first_name", "last_name", "email", "password", 
-	"country", "city" FROM "users" WHERE "id" = ($1);`
+	"id", "first_name", "last_name", "email", "password", "email_verified", "verification_sent_at"
+	FROM "users" WHERE "id" = ($1);`
 
 	rows, _ := conn.Query(ctx, query, id)
 	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.UserData])
@@ -159,9 +208,68 @@ func (pc *PostgresController) GetUserByID(ctx context.Context, id int) (*models.
 			// User doesn't exist
 			return nil, nil
 		default:
-			return nil, fmt.Errorf("postgres: failed to collect rows, error: %v", user)
+			return nil, fmt.Errorf("postgres: failed to collect rows, error: %v", err)
 		}
 	}
 
 	return &user, nil
 }
+
+// The owners are joined on the emails of the users, the updates are sent in a single batch,
+// which runs as one transaction. Rows owned by an ID don't match any email, so it only
+// has something to do the first time it's run.
+func (pc *PostgresController) MigrateOwners(ctx context.Context) error {
+	batch := &pgx.Batch{}
+	for _, table := range ownedTables {
+		batch.Queue(fmt.Sprintf(`UPDATE %q SET %q = "users"."id" FROM "users" WHERE %q.%q = "users"."email";`,
+			table[0], table[1], table[0], table[1]))
+	}
+
+	if err := pc.connPool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("postgres: failed to migrate owners, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) MarkEmailVerified(ctx context.Context, id string) error {
+	query := `UPDATE "users" SET "email_verified" = TRUE WHERE "id" = ($1);`
+
+	if _, err := pc.connPool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("postgres: failed to mark email verified, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) RecordVerificationEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	query := `UPDATE "users" SET "verification_sent_at" = ($2)
+	WHERE "id" = ($1) AND ("verification_sent_at" IS NULL OR "verification_sent_at" <= ($3));`
+
+	tag, err := pc.connPool.Exec(ctx, query, id, sentAt, sentAt.Add(-interval))
+	if err != nil {
+		return false, fmt.Errorf("postgres: failed to record verification email, error: %v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (pc *PostgresController) RecordPasswordResetEmail(ctx context.Context, id string, sentAt time.Time, interval time.Duration) (bool, error) {
+	query := `UPDATE "users" SET "password_reset_sent_at" = ($2)
+	WHERE "id" = ($1) AND ("password_reset_sent_at" IS NULL OR "password_reset_sent_at" <= ($3));`
+
+	tag, err := pc.connPool.Exec(ctx, query, id, sentAt, sentAt.Add(-interval))
+	if err != nil {
+		return false, fmt.Errorf("postgres: failed to record password reset email, error: %v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (pc *PostgresController) UpdatePassword(ctx context.Context, id string, password string) error {
+	if _, err := pc.connPool.Exec(ctx, `UPDATE "users" SET "password" = ($2) WHERE "id" = ($1);`, id, password); err != nil {
+		return fmt.Errorf("postgres: failed to update password, error: %v", err)
+	}
+
+	return nil
+}
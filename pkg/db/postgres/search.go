@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/search"
+)
+
+// The text search configuration used both to index messages and to parse queries.
+const textSearchConfig = "english"
+
+var searchTables = []string{
+	`ALTER TABLE "messages" ADD COLUMN IF NOT EXISTS "content_tsv" TSVECTOR
+		GENERATED ALWAYS AS (to_tsvector('` + textSearchConfig + `', "content")) STORED;`,
+	`CREATE INDEX IF NOT EXISTS "messages_content_tsv_idx" ON "messages" USING GIN ("content_tsv");`,
+}
+
+// nullTime maps the zero time, which leaves a range open, to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (pc *PostgresController) SearchMessages(ctx context.Context, owner string, query *models.MessageSearchQuery) ([]models.MessageSearchHit, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	var afterRank *float64
+	var afterCreatedAt *time.Time
+	var afterID string
+	if query.After != nil {
+		afterRank = &query.After.Rank
+		afterCreatedAt = &query.After.CreatedAt
+		afterID = query.After.MessageID
+	}
+
+	// Headlines are expensive, so they are made only for the hits of the page.
+	// The content is escaped before, so the only markup in a snippet are the marks.
+	// The rank is a REAL, the cursor's rank is cast back to it to compare the values exactly.
+	sql := `WITH "hits" AS (
+		SELECT "m"."id", "m"."conversation_id", "c"."title", "m"."role", "m"."model", "m"."content", "m"."created_at",
+			ts_rank_cd("m"."content_tsv", "q") AS "rank", "q"
+		FROM "messages" AS "m"
+		JOIN "conversations" AS "c" ON "c"."id" = "m"."conversation_id",
+			websearch_to_tsquery('` + textSearchConfig + `', $2) AS "q"
+		WHERE "c"."owner" = ($1) AND "m"."content_tsv" @@ "q"
+			AND ($3::TIMESTAMPTZ IS NULL OR "m"."created_at" >= $3)
+			AND ($4::TIMESTAMPTZ IS NULL OR "m"."created_at" < $4)
+			AND ($5 = '' OR "m"."model" = $5)
+			AND ($6 = '' OR "m"."role" = $6)
+	), "page" AS (
+		SELECT * FROM "hits"
+		WHERE $7::FLOAT8 IS NULL
+			OR "rank" < $7::REAL
+			OR ("rank" = $7::REAL AND "created_at" < $8)
+			OR ("rank" = $7::REAL AND "created_at" = $8 AND "id" > $9)
+		ORDER BY "rank" DESC, "created_at" DESC, "id" ASC
+		LIMIT $10
+	)
+	SELECT "conversation_id", "title", "id", "role", "model",
+		ts_headline('` + textSearchConfig + `',
+			replace(replace(replace("content", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), "q",
+			'StartSel=` + search.MarkStart + `, StopSel=` + search.MarkEnd + `, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=" … "'),
+		"rank", "created_at"
+	FROM "page"
+	ORDER BY "rank" DESC, "created_at" DESC, "id" ASC;`
+
+	rows, _ := conn.Query(ctx, sql, owner, query.Text, nullTime(query.From), nullTime(query.To),
+		query.Model, query.Role, afterRank, afterCreatedAt, afterID, query.Limit)
+	hits, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.MessageSearchHit, error) {
+		var hit models.MessageSearchHit
+		var rank float32
+		err := row.Scan(&hit.ConversationID, &hit.ConversationTitle, &hit.MessageID, &hit.Role, &hit.Model,
+			&hit.Snippet, &rank, &hit.CreatedAt)
+		hit.Rank = float64(rank)
+		return hit, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to search messages, error: %v", err)
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var templateTables = []string{
+	`CREATE TABLE IF NOT EXISTS "prompt_templates" (
+		"id" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"name" VARCHAR(256) NOT NULL,
+		"description" TEXT NOT NULL,
+		"visibility" VARCHAR(32) NOT NULL,
+		"organization" VARCHAR(256) NOT NULL,
+		"latest_version" INTEGER NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		"updated_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "prompt_templates_owner_idx" ON "prompt_templates" ("owner");`,
+	`CREATE INDEX IF NOT EXISTS "prompt_templates_organization_idx" ON "prompt_templates" ("organization", "visibility");`,
+	`CREATE TABLE IF NOT EXISTS "prompt_template_versions" (
+		"template_id" VARCHAR(36) NOT NULL REFERENCES "prompt_templates"("id") ON DELETE CASCADE,
+		"version" INTEGER NOT NULL,
+		"content" TEXT NOT NULL,
+		"variables" TEXT[] NOT NULL,
+		"created_by" VARCHAR(320) NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("template_id", "version")
+	);`,
+}
+
+// The condition under which a template is visible to the owner ($1) and the organisation ($2).
+const templateVisible = `("owner" = ($1) OR ($2 <> '' AND "organization" = ($2) AND "visibility" = '` + models.TemplateOrganization + `'))`
+
+func scanTemplate(row pgx.CollectableRow) (models.PromptTemplate, error) {
+	var t models.PromptTemplate
+	err := row.Scan(&t.ID, &t.Owner, &t.Name, &t.Description, &t.Visibility, &t.Organization,
+		&t.LatestVersion, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+func scanTemplateVersion(row pgx.CollectableRow) (models.PromptTemplateVersion, error) {
+	var v models.PromptTemplateVersion
+	err := row.Scan(&v.TemplateID, &v.Version, &v.Content, &v.Variables, &v.CreatedBy, &v.CreatedAt)
+	return v, err
+}
+
+func insertTemplateVersion(ctx context.Context, tx pgx.Tx, version *models.PromptTemplateVersion) error {
+	query := `INSERT INTO "prompt_template_versions" (
+		"template_id", "version", "content", "variables", "created_by", "created_at"
+	) VALUES ($1, $2, $3, $4, $5, $6);`
+
+	_, err := tx.Exec(ctx, query, version.TemplateID, version.Version, version.Content,
+		version.Variables, version.CreatedBy, version.CreatedAt)
+	return err
+}
+
+func (pc *PostgresController) CreatePromptTemplate(ctx context.Context, template *models.PromptTemplate, version *models.PromptTemplateVersion) error {
+	tx, err := pc.connPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to begin transaction, error: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO "prompt_templates" (
+		"id", "owner", "name", "description", "visibility", "organization", "latest_version", "created_at", "updated_at"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+
+	if _, err := tx.Exec(ctx, query, template.ID, template.Owner, template.Name, template.Description,
+		template.Visibility, template.Organization, template.LatestVersion, template.CreatedAt, template.UpdatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to create prompt template, error: %v", err)
+	}
+
+	if err := insertTemplateVersion(ctx, tx, version); err != nil {
+		return fmt.Errorf("postgres: failed to add prompt template version, error: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: failed to commit transaction, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetPromptTemplate(ctx context.Context, owner string, organization string, id string) (*models.PromptTemplate, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "name", "description", "visibility", "organization", "latest_version", "created_at", "updated_at"
+	FROM "prompt_templates" WHERE ` + templateVisible + ` AND "id" = ($3);`
+
+	rows, _ := conn.Query(ctx, query, owner, organization, id)
+	template, err := pgx.CollectOneRow(rows, scanTemplate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to select prompt template, error: %v", err)
+	}
+
+	return &template, nil
+}
+
+func (pc *PostgresController) ListPromptTemplates(ctx context.Context, owner string, organization string) ([]models.PromptTemplate, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "owner", "name", "description", "visibility", "organization", "latest_version", "created_at", "updated_at"
+	FROM "prompt_templates" WHERE ` + templateVisible + ` ORDER BY "updated_at" DESC;`
+
+	rows, _ := conn.Query(ctx, query, owner, organization)
+	templates, err := pgx.CollectRows(rows, scanTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select prompt templates, error: %v", err)
+	}
+
+	return templates, nil
+}
+
+func (pc *PostgresController) UpdatePromptTemplate(ctx context.Context, template *models.PromptTemplate) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `UPDATE "prompt_templates" SET "name" = ($1), "description" = ($2), "visibility" = ($3),
+		"organization" = ($4), "updated_at" = ($5)
+	WHERE "id" = ($6) AND "owner" = ($7);`
+
+	if _, err := conn.Exec(ctx, query, template.Name, template.Description, template.Visibility,
+		template.Organization, template.UpdatedAt, template.ID, template.Owner); err != nil {
+		return fmt.Errorf("postgres: failed to update prompt template, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) DeletePromptTemplate(ctx context.Context, owner string, id string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	// Versions are removed by the ON DELETE CASCADE constraint.
+	query := `DELETE FROM "prompt_templates" WHERE "id" = ($1) AND "owner" = ($2);`
+
+	if _, err := conn.Exec(ctx, query, id, owner); err != nil {
+		return fmt.Errorf("postgres: failed to delete prompt template, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) AddPromptTemplateVersion(ctx context.Context, version *models.PromptTemplateVersion) error {
+	tx, err := pc.connPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to begin transaction, error: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// The primary key rejects a version added concurrently with the same number.
+	if err := insertTemplateVersion(ctx, tx, version); err != nil {
+		return fmt.Errorf("postgres: failed to add prompt template version, error: %v", err)
+	}
+
+	query := `UPDATE "prompt_templates" SET "latest_version" = ($1), "updated_at" = ($2) WHERE "id" = ($3);`
+
+	if _, err := tx.Exec(ctx, query, version.Version, version.CreatedAt, version.TemplateID); err != nil {
+		return fmt.Errorf("postgres: failed to update prompt template, error: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: failed to commit transaction, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetPromptTemplateVersion(ctx context.Context, templateID string, version int) (*models.PromptTemplateVersion, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "template_id", "version", "content", "variables", "created_by", "created_at"
+	FROM "prompt_template_versions" WHERE "template_id" = ($1) AND "version" = ($2);`
+
+	rows, _ := conn.Query(ctx, query, templateID, version)
+	templateVersion, err := pgx.CollectOneRow(rows, scanTemplateVersion)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to select prompt template version, error: %v", err)
+	}
+
+	return &templateVersion, nil
+}
+
+func (pc *PostgresController) ListPromptTemplateVersions(ctx context.Context, templateID string) ([]models.PromptTemplateVersion, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "template_id", "version", "content", "variables", "created_by", "created_at"
+	FROM "prompt_template_versions" WHERE "template_id" = ($1) ORDER BY "version" ASC;`
+
+	rows, _ := conn.Query(ctx, query, templateID)
+	versions, err := pgx.CollectRows(rows, scanTemplateVersion)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select prompt template versions, error: %v", err)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var refreshTokenTables = []string{
+	`CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+		"id" VARCHAR(36) NOT NULL,
+		"family" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		"expires_at" TIMESTAMPTZ NOT NULL,
+		"rotated_at" TIMESTAMPTZ,
+		"revoked" BOOLEAN NOT NULL DEFAULT FALSE,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "refresh_tokens_family_idx" ON "refresh_tokens" ("family");`,
+	`CREATE INDEX IF NOT EXISTS "refresh_tokens_owner_idx" ON "refresh_tokens" ("owner", "expires_at");`,
+}
+
+var passwordResetTokenTables = []string{
+	`CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+		"id" CHARACTER(64) NOT NULL,
+		"owner" VARCHAR(36) NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		"expires_at" TIMESTAMPTZ NOT NULL,
+		"used_at" TIMESTAMPTZ,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "password_reset_tokens_owner_idx" ON "password_reset_tokens" ("owner", "expires_at");`,
+}
+
+// NOTE: The expired tokens of the owner are removed whenever a new one is issued,
+// there is no need to keep them, an expired token is rejected before it's looked up.
+func (pc *PostgresController) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `DELETE FROM "refresh_tokens" WHERE "owner" = ($1) AND "expires_at" < ($2);`,
+		token.Owner, token.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to delete expired refresh tokens, error: %v", err)
+	}
+
+	query := `INSERT INTO "refresh_tokens" (
+		"id", "family", "owner", "created_at", "expires_at", "rotated_at", "revoked"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
+	if _, err := conn.Exec(ctx, query, token.ID, token.Family, token.Owner, token.CreatedAt, token.ExpiresAt,
+		token.RotatedAt, token.Revoked); err != nil {
+		return fmt.Errorf("postgres: failed to create refresh token, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "id", "family", "owner", "created_at", "expires_at", "rotated_at", "revoked"
+	FROM "refresh_tokens" WHERE "id" = ($1);`
+
+	var token models.RefreshToken
+	err = conn.QueryRow(ctx, query, id).Scan(&token.ID, &token.Family, &token.Owner, &token.CreatedAt,
+		&token.ExpiresAt, &token.RotatedAt, &token.Revoked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to select refresh token, error: %v", err)
+	}
+
+	return &token, nil
+}
+
+func (pc *PostgresController) RotateRefreshToken(ctx context.Context, id string, rotatedAt time.Time) (bool, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `UPDATE "refresh_tokens" SET "rotated_at" = ($1)
+	WHERE "id" = ($2) AND "rotated_at" IS NULL AND NOT "revoked";`
+
+	tag, err := conn.Exec(ctx, query, rotatedAt, id)
+	if err != nil {
+		return false, fmt.Errorf("postgres: failed to rotate refresh token, error: %v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (pc *PostgresController) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `UPDATE "refresh_tokens" SET "revoked" = TRUE WHERE "family" = ($1);`, family); err != nil {
+		return fmt.Errorf("postgres: failed to revoke refresh tokens, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) RevokeRefreshTokens(ctx context.Context, owner string) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `UPDATE "refresh_tokens" SET "revoked" = TRUE WHERE "owner" = ($1);`, owner); err != nil {
+		return fmt.Errorf("postgres: failed to revoke refresh tokens, error: %v", err)
+	}
+
+	return nil
+}
+
+// NOTE: Like with the refresh tokens, the expired tokens of the owner are removed whenever a new one is issued.
+func (pc *PostgresController) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `DELETE FROM "password_reset_tokens" WHERE "owner" = ($1) AND "expires_at" < ($2);`,
+		token.Owner, token.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to delete expired password reset tokens, error: %v", err)
+	}
+
+	query := `INSERT INTO "password_reset_tokens" (
+		"id", "owner", "created_at", "expires_at", "used_at"
+	) VALUES ($1, $2, $3, $4, $5);`
+
+	if _, err := conn.Exec(ctx, query, token.ID, token.Owner, token.CreatedAt, token.ExpiresAt, token.UsedAt); err != nil {
+		return fmt.Errorf("postgres: failed to create password reset token, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) UsePasswordResetToken(ctx context.Context, id string, usedAt time.Time) (*models.PasswordResetToken, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `UPDATE "password_reset_tokens" SET "used_at" = ($1)
+	WHERE "id" = ($2) AND "used_at" IS NULL AND "expires_at" > ($1)
+	RETURNING "id", "owner", "created_at", "expires_at", "used_at";`
+
+	var token models.PasswordResetToken
+	err = conn.QueryRow(ctx, query, usedAt, id).Scan(&token.ID, &token.Owner, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres: failed to use password reset token, error: %v", err)
+	}
+
+	return &token, nil
+}
+
+func (pc *PostgresController) DeletePasswordResetTokens(ctx context.Context, owner string) error {
+	if _, err := pc.connPool.Exec(ctx, `DELETE FROM "password_reset_tokens" WHERE "owner" = ($1);`, owner); err != nil {
+		return fmt.Errorf("postgres: failed to delete password reset tokens, error: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var usageTables = []string{
+	`CREATE TABLE IF NOT EXISTS "usage" (
+		"id" VARCHAR(36) NOT NULL,
+		"owner" VARCHAR(320) NOT NULL,
+		"kind" VARCHAR(32) NOT NULL,
+		"model" VARCHAR(128) NOT NULL,
+		"prompt_tokens" INTEGER NOT NULL,
+		"completion_tokens" INTEGER NOT NULL,
+		"cost" DOUBLE PRECISION NOT NULL,
+		"created_at" TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY("id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "usage_owner_idx" ON "usage" ("owner", "created_at");`,
+}
+
+func (pc *PostgresController) AddUsage(ctx context.Context, record *models.UsageRecord) error {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: failed to acquire connection from the pool, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `INSERT INTO "usage" (
+		"id", "owner", "kind", "model", "prompt_tokens", "completion_tokens", "cost", "created_at"
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	if _, err := conn.Exec(ctx, query, record.ID, record.Owner, record.Kind, record.Model,
+		record.PromptTokens, record.CompletionTokens, record.Cost, record.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: failed to add usage, error: %v", err)
+	}
+
+	return nil
+}
+
+func (pc *PostgresController) GetUsage(ctx context.Context, owner string, from time.Time, to time.Time) ([]models.ModelUsage, error) {
+	conn, err := pc.connPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to acquire database connection, error: %v", err)
+	}
+
+	defer conn.Release()
+
+	query := `SELECT "model", COUNT(*), SUM("prompt_tokens"), SUM("completion_tokens"), SUM("cost")
+	FROM "usage" WHERE "owner" = ($1) AND "created_at" >= ($2) AND "created_at" < ($3)
+	GROUP BY "model" ORDER BY "model";`
+
+	rows, _ := conn.Query(ctx, query, owner, from, to)
+	usage, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ModelUsage, error) {
+		var u models.ModelUsage
+		err := row.Scan(&u.Model, &u.Requests, &u.PromptTokens, &u.CompletionTokens, &u.Cost)
+		return u, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to select usage, error: %v", err)
+	}
+
+	return usage, nil
+}
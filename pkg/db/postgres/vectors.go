@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// Requires the pgvector extension, see https://github.com/pgvector/pgvector
+//
+// The embeddings column has no fixed dimensions, so the embedding model can be changed
+// without a migration. There is no approximate index either: queries are always filtered by owner,
+// and an HNSW index filtered afterwards could return fewer than k records.
+// An exact scan over the records of a single user is fast enough.
+var vectorTables = []string{
+	`CREATE EXTENSION IF NOT EXISTS vector;`,
+	`CREATE TABLE IF NOT EXISTS "vectors" (
+		"owner" VARCHAR(320) NOT NULL,
+		"id" VARCHAR(128) NOT NULL,
+		"collection" VARCHAR(128) NOT NULL,
+		"content" TEXT NOT NULL,
+		"metadata" JSONB NOT NULL DEFAULT '{}',
+		"embedding" vector NOT NULL,
+		PRIMARY KEY("owner", "id")
+	);`,
+	`CREATE INDEX IF NOT EXISTS "vectors_collection_idx" ON "vectors" ("owner", "collection");`,
+}
+
+type PostgresVectorStore struct {
+	connPool *pgxpool.Pool
+}
+
+// NewPostgresVectorStore connects to POSTGRES_URL, the vector store doesn't have to live
+// in the same database as the rest of the data.
+func NewPostgresVectorStore(ctx context.Context) (*PostgresVectorStore, error) {
+	postgresUrl, set := os.LookupEnv("POSTGRES_URL")
+	if !set || postgresUrl == "" {
+		return nil, fmt.Errorf("POSTGRES_URL is not set")
+	}
+
+	connPool, err := pgxpool.New(ctx, postgresUrl)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to create connection pool, error: %v", err)
+	}
+
+	for _, query := range vectorTables {
+		if _, err := connPool.Exec(ctx, query); err != nil {
+			connPool.Close()
+			return nil, fmt.Errorf("postgres: failed to create vector tables, error: %v", err)
+		}
+	}
+
+	log.Logger.Info("Successfully initialized postgres vector store")
+
+	return &PostgresVectorStore{connPool: connPool}, nil
+}
+
+func (vs *PostgresVectorStore) Close(_ context.Context) error {
+	vs.connPool.Close()
+	return nil
+}
+
+// formatVector encodes the vector in pgvector's text format, `[1,2,3]`.
+func formatVector(vector []float32) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func (vs *PostgresVectorStore) Upsert(ctx context.Context, records []models.VectorRecord) error {
+	query := `INSERT INTO "vectors" (
+		"owner", "id", "collection", "content", "metadata", "embedding"
+	) VALUES ($1, $2, $3, $4, $5, $6::vector)
+	ON CONFLICT ("owner", "id") DO UPDATE SET
+		"collection" = EXCLUDED."collection",
+		"content" = EXCLUDED."content",
+		"metadata" = EXCLUDED."metadata",
+		"embedding" = EXCLUDED."embedding";`
+
+	batch := &pgx.Batch{}
+	for _, record := range records {
+		if len(record.Vector) == 0 {
+			return fmt.Errorf("postgres: record %s has an empty vector", record.ID)
+		}
+		metadata := record.Metadata
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		batch.Queue(query, record.Owner, record.ID, record.Collection, record.Content, metadata, formatVector(record.Vector))
+	}
+
+	// A batch runs in an implicit transaction, either all the records are stored or none.
+	if err := vs.connPool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("postgres: failed to upsert vectors, error: %v", err)
+	}
+
+	return nil
+}
+
+func (vs *PostgresVectorStore) Delete(ctx context.Context, owner string, ids []string) error {
+	query := `DELETE FROM "vectors" WHERE "owner" = ($1) AND "id" = ANY($2);`
+
+	if _, err := vs.connPool.Exec(ctx, query, owner, ids); err != nil {
+		return fmt.Errorf("postgres: failed to delete vectors, error: %v", err)
+	}
+
+	return nil
+}
+
+// The owners are joined on the emails of the users, so the records are only migrated if the users
+// live in the same database. Records of the ID with the same ids as the ones of the email are replaced, like with Upsert.
+const migrateVectorOwners = `DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.tables WHERE "table_name" = 'users') THEN
+		DELETE FROM "vectors" USING "users", "vectors" AS "owned"
+		WHERE "vectors"."owner" = "users"."id" AND "owned"."owner" = "users"."email" AND "owned"."id" = "vectors"."id";
+		UPDATE "vectors" SET "owner" = "users"."id" FROM "users" WHERE "vectors"."owner" = "users"."email";
+	END IF;
+END $$;`
+
+func (vs *PostgresVectorStore) MigrateOwners(ctx context.Context) error {
+	if _, err := vs.connPool.Exec(ctx, migrateVectorOwners); err != nil {
+		return fmt.Errorf("postgres: failed to migrate vector owners, error: %v", err)
+	}
+
+	return nil
+}
+
+func (vs *PostgresVectorStore) Query(ctx context.Context, owner string, collection string, vector []float32, k int) ([]models.VectorMatch, error) {
+	if len(vector) == 0 {
+		return nil, fmt.Errorf("postgres: query vector is empty")
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	// <=> is the cosine distance, 1 - distance is the similarity.
+	query := `SELECT "id", "owner", "collection", "content", "metadata", 1 - ("embedding" <=> $1::vector)
+	FROM "vectors" WHERE "owner" = ($2) AND (($3) = '' OR "collection" = ($3))
+	ORDER BY "embedding" <=> $1::vector, "id" LIMIT ($4);`
+
+	rows, _ := vs.connPool.Query(ctx, query, formatVector(vector), owner, collection, k)
+	matches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.VectorMatch, error) {
+		var m models.VectorMatch
+		err := row.Scan(&m.ID, &m.Owner, &m.Collection, &m.Content, &m.Metadata, &m.Score)
+		return m, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to query vectors, error: %v", err)
+	}
+
+	return matches, nil
+}
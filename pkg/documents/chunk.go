@@ -0,0 +1,87 @@
+package documents
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	DefaultChunkSize    = 1000
+	DefaultChunkOverlap = 200
+
+	MinChunkSize = 100
+	MaxChunkSize = 8000
+)
+
+// Chunker splits text into chunks of at most Size characters,
+// every chunk repeats the last Overlap characters of the previous one,
+// so that a sentence cut in half can still be found in one piece.
+type Chunker struct {
+	Size    int
+	Overlap int
+}
+
+func NewChunker(size int, overlap int) (*Chunker, error) {
+	if size < MinChunkSize || size > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between %d and %d characters", MinChunkSize, MaxChunkSize)
+	}
+	if overlap < 0 || overlap >= size/2 {
+		return nil, fmt.Errorf("chunk overlap must be between 0 and half of the chunk size")
+	}
+	return &Chunker{Size: size, Overlap: overlap}, nil
+}
+
+// Separators the chunks are preferably split at, from the strongest to the weakest.
+var separators = []string{"\n\n", "\n", ". ", "? ", "! ", "; ", ", ", " "}
+
+// Split returns the chunks of the text, chunks end at a paragraph, a sentence or a word boundary
+// whenever there is one in the second half of the chunk.
+func (c *Chunker) Split(text string) []string {
+	runes := []rune(text)
+
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := min(start+c.Size, len(runes))
+		if end < len(runes) {
+			end = start + splitPoint(runes[start:end])
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		// The overlap is at most half of the chunk, and the split point is in the second half,
+		// so the next chunk always starts after this one.
+		next := end - c.Overlap
+		// Don't start the next chunk in the middle of a word.
+		for i := next; i < end; i++ {
+			if unicode.IsSpace(runes[i]) {
+				next = i + 1
+				break
+			}
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// splitPoint returns the length of the chunk which ends at the strongest separator
+// in the second half of the window, or the whole window if there is none.
+func splitPoint(window []rune) int {
+	half := len(window) / 2
+	text := string(window[half:])
+
+	for _, separator := range separators {
+		if i := strings.LastIndex(text, separator); i >= 0 {
+			// The separator stays with the chunk it ends.
+			return half + len([]rune(text[:i+len(separator)]))
+		}
+	}
+
+	return len(window)
+}
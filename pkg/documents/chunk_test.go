@@ -0,0 +1,99 @@
+package documents
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewChunker(t *testing.T) {
+	tests := []struct {
+		size    int
+		overlap int
+		valid   bool
+	}{
+		{size: DefaultChunkSize, overlap: DefaultChunkOverlap, valid: true},
+		{size: MinChunkSize, overlap: 0, valid: true},
+		{size: MinChunkSize - 1, overlap: 0},
+		{size: MaxChunkSize + 1, overlap: 0},
+		{size: 200, overlap: 100},
+		{size: 200, overlap: -1},
+	}
+	for _, test := range tests {
+		_, err := NewChunker(test.size, test.overlap)
+		if (err == nil) != test.valid {
+			t.Errorf("size %d, overlap %d: expected valid %v, got error: %v", test.size, test.overlap, test.valid, err)
+		}
+	}
+}
+
+func TestSplitShortText(t *testing.T) {
+	chunker, _ := NewChunker(100, 20)
+
+	chunks := chunker.Split("  A short text.  ")
+	if len(chunks) != 1 || chunks[0] != "A short text." {
+		t.Errorf("expected a single chunk, got: %q", chunks)
+	}
+
+	if chunks := chunker.Split(""); len(chunks) != 0 {
+		t.Errorf("expected no chunks, got: %q", chunks)
+	}
+}
+
+func TestSplitPrefersParagraphs(t *testing.T) {
+	chunker, _ := NewChunker(100, 0)
+
+	first := strings.Repeat("a", 60) + "."
+	second := strings.Repeat("b", 60) + "."
+	chunks := chunker.Split(first + "\n\n" + second)
+
+	if len(chunks) != 2 || chunks[0] != first || chunks[1] != second {
+		t.Errorf("expected the paragraphs in separate chunks, got: %q", chunks)
+	}
+}
+
+func TestSplitOverlap(t *testing.T) {
+	chunker, _ := NewChunker(100, 30)
+
+	words := make([]string, 200)
+	for i := range words {
+		words[i] = "word"
+	}
+	text := strings.Join(words, " ")
+	chunks := chunker.Split(text)
+
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got: %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len([]rune(chunk)) > 100 {
+			t.Errorf("chunk %d is longer than the chunk size: %d", i, len(chunk))
+		}
+		// Chunks start and end at word boundaries.
+		if !strings.HasPrefix(chunk, "word") || !strings.HasSuffix(chunk, "word") {
+			t.Errorf("chunk %d splits a word: %q", i, chunk)
+		}
+	}
+	for i := 1; i < len(chunks); i++ {
+		if !strings.Contains(chunks[i-1], chunks[i][:20]) {
+			t.Errorf("chunk %d doesn't overlap with the previous one", i)
+		}
+	}
+}
+
+func TestSplitWithoutSeparators(t *testing.T) {
+	chunker, _ := NewChunker(100, 10)
+
+	text := strings.Repeat("ж", 250)
+	chunks := chunker.Split(text)
+
+	var total int
+	for _, chunk := range chunks {
+		if len([]rune(chunk)) > 100 {
+			t.Errorf("chunk is longer than the chunk size: %d", len([]rune(chunk)))
+		}
+		total += len([]rune(chunk))
+	}
+	if total != 250+10*(len(chunks)-1) {
+		t.Errorf("expected the chunks to cover the text with the overlap, got %d chunks of %d characters", len(chunks), total)
+	}
+}
@@ -0,0 +1,225 @@
+// Package documents turns the files uploaded by the users into plain text
+// and splits it into chunks small enough to be embedded and put into a prompt.
+package documents
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html"
+)
+
+// Supported types of documents.
+const (
+	TypeText     = "text/plain"
+	TypeMarkdown = "text/markdown"
+	TypeHTML     = "text/html"
+	TypePDF      = "application/pdf"
+)
+
+var ErrUnsupportedType = errors.New("unsupported document type")
+
+var extensionTypes = map[string]string{
+	".txt":      TypeText,
+	".text":     TypeText,
+	".md":       TypeMarkdown,
+	".markdown": TypeMarkdown,
+	".html":     TypeHTML,
+	".htm":      TypeHTML,
+	".pdf":      TypePDF,
+}
+
+// DetectType resolves the type of a document from the content type it was uploaded with,
+// or from the extension of its name if the content type is missing or too generic.
+func DetectType(name string, contentType string) (string, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case TypeText, TypeMarkdown, TypeHTML, TypePDF:
+			return mediaType, nil
+		case "text/x-markdown":
+			return TypeMarkdown, nil
+		case "application/xhtml+xml":
+			return TypeHTML, nil
+		}
+	}
+
+	if documentType, ok := extensionTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return documentType, nil
+	}
+
+	return "", fmt.Errorf("%w, expected plain text, markdown, html or pdf", ErrUnsupportedType)
+}
+
+// Extract returns the text of a document of the given type.
+// Markdown is kept as is, the markup doesn't get in the way of the model.
+func Extract(documentType string, data []byte) (string, error) {
+	var text string
+	var err error
+
+	switch documentType {
+	case TypeText, TypeMarkdown:
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("document is not valid utf-8 text")
+		}
+		text = string(data)
+	case TypeHTML:
+		text, err = extractHTML(data)
+	case TypePDF:
+		text, err = extractPDF(data)
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnsupportedType, documentType)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text = normalize(text)
+	if text == "" {
+		return "", fmt.Errorf("document doesn't contain any text")
+	}
+
+	return text, nil
+}
+
+var (
+	trailingSpaces = regexp.MustCompile(`[ \t]+\n`)
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+)
+
+// normalize unifies line endings and squeezes the blank lines, paragraphs are kept apart
+// since the chunker prefers to split between them.
+func normalize(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "\x00", "")
+	text = trailingSpaces.ReplaceAllString(text, "\n")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// Elements which never contain any readable text.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true, "svg": true,
+}
+
+// Elements which start a new paragraph, everything else is inline.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "tr": true, "ul": true,
+}
+
+func extractHTML(data []byte) (string, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html document: %v", err)
+	}
+
+	var sb strings.Builder
+
+	var walk func(node *html.Node, pre bool)
+	walk = func(node *html.Node, pre bool) {
+		switch node.Type {
+		case html.TextNode:
+			if pre {
+				sb.WriteString(node.Data)
+				return
+			}
+			// Whitespace is insignificant in html, the text is reflowed the same way a browser would.
+			if strings.TrimLeft(node.Data, spaces) != node.Data {
+				writeSpace(&sb)
+			}
+			sb.WriteString(strings.Join(strings.Fields(node.Data), " "))
+			if strings.TrimRight(node.Data, spaces) != node.Data {
+				writeSpace(&sb)
+			}
+			return
+		case html.ElementNode:
+			if skippedElements[node.Data] {
+				return
+			}
+			if node.Data == "br" {
+				sb.WriteByte('\n')
+				return
+			}
+		}
+
+		block := node.Type == html.ElementNode && blockElements[node.Data]
+		if block {
+			sb.WriteString("\n\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, pre || (node.Type == html.ElementNode && node.Data == "pre"))
+		}
+		if block {
+			sb.WriteString("\n\n")
+		}
+	}
+	walk(root, false)
+
+	// Inline elements leave spaces at the beginning and the end of the lines.
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, " ")
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+const spaces = " \t\n\r\f"
+
+// writeSpace separates words, unless they are already separated.
+func writeSpace(sb *strings.Builder) {
+	if sb.Len() > 0 && !strings.ContainsRune(spaces, rune(sb.String()[sb.Len()-1])) {
+		sb.WriteByte(' ')
+	}
+}
+
+func extractPDF(data []byte) (text string, err error) {
+	// The pdf reader panics on malformed documents, and the documents come from the users.
+	defer func() {
+		if r := recover(); r != nil {
+			text = ""
+			err = fmt.Errorf("failed to read pdf document: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read pdf document: %v", err)
+	}
+
+	var sb strings.Builder
+	fonts := make(map[string]*pdf.Font)
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		// Fonts are shared between the pages, decoding their character maps once is enough.
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		pageText, err := page.GetPlainText(fonts)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract text of page %d: %v", i, err)
+		}
+		sb.WriteString(pageText)
+		sb.WriteString("\n\n")
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,120 @@
+package documents
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		expected    string
+	}{
+		{name: "notes.txt", contentType: "text/plain; charset=utf-8", expected: TypeText},
+		{name: "README.md", contentType: "application/octet-stream", expected: TypeMarkdown},
+		{name: "page", contentType: "text/html", expected: TypeHTML},
+		{name: "Report.PDF", contentType: "", expected: TypePDF},
+		{name: "image.png", contentType: "image/png"},
+	}
+	for _, test := range tests {
+		documentType, err := DetectType(test.name, test.contentType)
+		if test.expected == "" {
+			if !errors.Is(err, ErrUnsupportedType) {
+				t.Errorf("%s: expected unsupported type, got: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil || documentType != test.expected {
+			t.Errorf("%s: expected %s, got: %s, %v", test.name, test.expected, documentType, err)
+		}
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	text, err := Extract(TypeMarkdown, []byte("# Title\r\n\r\n\r\n\r\nSome   text.  \r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "# Title\n\nSome   text." {
+		t.Errorf("unexpected text: %q", text)
+	}
+
+	if _, err := Extract(TypeText, []byte{0xff, 0xfe}); err == nil {
+		t.Error("expected invalid utf-8 to be rejected")
+	}
+	if _, err := Extract(TypeText, []byte(" \n\t ")); err == nil {
+		t.Error("expected a document without text to be rejected")
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	document := `<!DOCTYPE html>
+<html>
+<head><title>Ignored</title><style>p { color: red; }</style></head>
+<body>
+	<h1>Heading</h1>
+	<p>First   <b>bold</b> <i>italic</i>
+	paragraph.</p>
+	<script>alert("ignored")</script>
+	<ul><li>one</li><li>two</li></ul>
+	<pre>keep
+  spaces</pre>
+</body>
+</html>`
+
+	text, err := Extract(TypeHTML, []byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Heading\n\nFirst bold italic paragraph.\n\none\n\ntwo\n\nkeep\nspaces"
+	if text != expected {
+		t.Errorf("expected: %q, got: %q", expected, text)
+	}
+}
+
+// minimalPDF builds a single page pdf document which shows the text.
+func minimalPDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	text, err := Extract(TypePDF, minimalPDF("Hello from a pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Hello from a pdf") {
+		t.Errorf("expected the text of the page, got: %q", text)
+	}
+
+	if _, err := Extract(TypePDF, []byte("%PDF-1.4\nnot really a pdf")); err == nil {
+		t.Error("expected a malformed pdf to be rejected")
+	}
+}
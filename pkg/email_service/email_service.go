@@ -3,12 +3,20 @@ package emailservice
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/config"
-	_ "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+
+	"github.com/isnastish/openai/pkg/log"
 )
 
+// Sender delivers plain text emails.
+type Sender interface {
+	SendEmail(ctx context.Context, messageBody string, subject string, fromEmail string, recipient Recipient) error
+}
+
 type AWSEmailService struct {
 	client *ses.Client
 }
@@ -18,6 +26,13 @@ type Recipient struct {
 	ccEmails []string
 }
 
+func NewRecipient(toEmails []string, ccEmails []string) Recipient {
+	return Recipient{
+		toEmails: toEmails,
+		ccEmails: ccEmails,
+	}
+}
+
 func NewAWSEmailService() (*AWSEmailService, error) {
 	// Load the shared AWS configuration (~/.aws/config)
 	awsConfig, err := config.LoadDefaultConfig(context.TODO())
@@ -30,7 +45,32 @@ func NewAWSEmailService() (*AWSEmailService, error) {
 	}, nil
 }
 
-func (s *AWSEmailService) SendEmail(messageBody string, subject string, fromEmail string, recipient Recipient) error {
-	// s.client.SendEmail()
+func (s *AWSEmailService) SendEmail(ctx context.Context, messageBody string, subject string, fromEmail string, recipient Recipient) error {
+	_, err := s.client.SendEmail(ctx, &ses.SendEmailInput{
+		Source: &fromEmail,
+		Destination: &types.Destination{
+			ToAddresses: recipient.toEmails,
+			CcAddresses: recipient.ccEmails,
+		},
+		Message: &types.Message{
+			Subject: &types.Content{Data: &subject},
+			Body: &types.Body{
+				Text: &types.Content{Data: &messageBody},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email, %v", err)
+	}
+
+	return nil
+}
+
+// LogEmailService writes the emails to the log instead of sending them,
+// for development without an AWS account.
+type LogEmailService struct{}
+
+func (s *LogEmailService) SendEmail(_ context.Context, messageBody string, subject string, fromEmail string, recipient Recipient) error {
+	log.Logger.Info("Email from %s to %s: %s\n%s", fromEmail, strings.Join(recipient.toEmails, ", "), subject, messageBody)
 	return nil
 }
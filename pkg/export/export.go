@@ -0,0 +1,177 @@
+// Package export renders conversations in the formats users take them out in,
+// and parses the json format back for the conversations to be imported.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	// OpenAI chat fine-tuning format, a json document with the messages of a conversation per line.
+	FormatJSONL = "jsonl"
+)
+
+// The version of the json format, bumped on incompatible changes.
+const Version = 1
+
+// ContentType returns the content type of the format, and false if the format is unknown.
+func ContentType(format string) (string, bool) {
+	switch format {
+	case FormatJSON:
+		return "application/json", true
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8", true
+	case FormatJSONL:
+		return "application/jsonl", true
+	}
+	return "", false
+}
+
+// Extension returns the file extension of the format.
+func Extension(format string) string {
+	if format == FormatMarkdown {
+		return "md"
+	}
+	return format
+}
+
+// Render renders the conversations, together with their messages, in the format.
+func Render(format string, conversations []models.Conversation, exportedAt time.Time) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return JSON(conversations, exportedAt)
+	case FormatMarkdown:
+		return Markdown(conversations), nil
+	case FormatJSONL:
+		return JSONL(conversations)
+	}
+	return nil, fmt.Errorf("export: unknown format %q", format)
+}
+
+// JSON renders the conversations in the format accepted by Parse.
+func JSON(conversations []models.Conversation, exportedAt time.Time) ([]byte, error) {
+	exported := models.ConversationExport{
+		Version:       Version,
+		ExportedAt:    exportedAt,
+		Conversations: make([]models.ExportedConversation, 0, len(conversations)),
+	}
+
+	for _, conversation := range conversations {
+		messages := make([]models.ExportedMessage, 0, len(conversation.Messages))
+		for _, message := range conversation.Messages {
+			messages = append(messages, models.ExportedMessage{
+				Role:       message.Role,
+				Content:    message.Content,
+				Model:      message.Model,
+				ToolCalls:  message.ToolCalls,
+				ToolCallID: message.ToolCallID,
+				CreatedAt:  message.CreatedAt,
+			})
+		}
+		exported.Conversations = append(exported.Conversations, models.ExportedConversation{
+			Title:     conversation.Title,
+			CreatedAt: conversation.CreatedAt,
+			UpdatedAt: conversation.UpdatedAt,
+			Messages:  messages,
+		})
+	}
+
+	return json.MarshalIndent(&exported, "", "  ")
+}
+
+// Markdown renders the conversations as a transcript meant to be read by people.
+func Markdown(conversations []models.Conversation) []byte {
+	var b strings.Builder
+
+	for i, conversation := range conversations {
+		if i > 0 {
+			b.WriteString("\n---\n\n")
+		}
+		fmt.Fprintf(&b, "# %s\n\n", conversation.Title)
+		fmt.Fprintf(&b, "_Created %s_\n", conversation.CreatedAt.UTC().Format(time.RFC3339))
+
+		for _, message := range conversation.Messages {
+			b.WriteString("\n")
+			switch {
+			case len(message.ToolCalls) > 0:
+				for _, call := range message.ToolCalls {
+					fmt.Fprintf(&b, "**Tool call** `%s`:\n\n```json\n%s\n```\n", call.Function.Name, call.Function.Arguments)
+				}
+			case message.Role == "tool":
+				fmt.Fprintf(&b, "**Tool result**:\n\n```json\n%s\n```\n", message.Content)
+			case message.Role == "assistant" && message.Model != "":
+				fmt.Fprintf(&b, "**Assistant** (%s):\n\n%s\n", message.Model, message.Content)
+			default:
+				fmt.Fprintf(&b, "**%s**:\n\n%s\n", roleTitle(message.Role), message.Content)
+			}
+		}
+	}
+
+	return []byte(b.String())
+}
+
+func roleTitle(role string) string {
+	if role == "" {
+		return role
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+type fineTuningExample struct {
+	Messages []models.OpenAIMessage `json:"messages"`
+}
+
+// JSONL renders every conversation as a chat fine-tuning example.
+// Conversations without any answer of the model are of no use for training and are left out.
+func JSONL(conversations []models.Conversation) ([]byte, error) {
+	var b bytes.Buffer
+
+	for _, conversation := range conversations {
+		example := fineTuningExample{
+			Messages: make([]models.OpenAIMessage, 0, len(conversation.Messages)),
+		}
+
+		answered := false
+		for _, message := range conversation.Messages {
+			toolCalls := make([]models.OpenAIToolCall, len(message.ToolCalls))
+			for i, call := range message.ToolCalls {
+				toolCalls[i] = models.OpenAIToolCall{
+					ID:       call.ID,
+					Type:     "function",
+					Function: call.Function,
+				}
+			}
+			if len(toolCalls) == 0 {
+				toolCalls = nil
+			}
+
+			example.Messages = append(example.Messages, models.OpenAIMessage{
+				Role:       message.Role,
+				Content:    message.Content,
+				ToolCalls:  toolCalls,
+				ToolCallID: message.ToolCallID,
+			})
+			answered = answered || (message.Role == "assistant" && len(toolCalls) == 0)
+		}
+		if !answered {
+			continue
+		}
+
+		line, err := json.Marshal(&example)
+		if err != nil {
+			return nil, fmt.Errorf("export: failed to marshal conversation, error: %v", err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func newConversation() models.Conversation {
+	createdAt := time.Date(2024, 11, 3, 10, 0, 0, 0, time.UTC)
+	return models.Conversation{
+		ID:        "c1",
+		Owner:     "user@example.com",
+		Title:     "Arithmetic",
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt.Add(3 * time.Second),
+		Messages: []models.ConversationMessage{
+			{ID: "m1", ConversationID: "c1", Role: "user", Content: "What is 2+2?", CreatedAt: createdAt},
+			{ID: "m2", ConversationID: "c1", Role: "assistant", Model: "gpt-4o-mini", CreatedAt: createdAt.Add(time.Second),
+				ToolCalls: []models.OpenAIToolCall{{ID: "call_1", Function: models.OpenAIFunctionCall{Name: "calculator", Arguments: `{"expression":"2+2"}`}}}},
+			{ID: "m3", ConversationID: "c1", Role: "tool", Content: `{"result":4}`, ToolCallID: "call_1", CreatedAt: createdAt.Add(2 * time.Second)},
+			{ID: "m4", ConversationID: "c1", Role: "assistant", Model: "gpt-4o-mini", Content: "4", CreatedAt: createdAt.Add(3 * time.Second)},
+		},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	conversation := newConversation()
+
+	data, err := JSON([]models.Conversation{conversation}, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("m1")) || bytes.Contains(data, []byte("user@example.com")) {
+		t.Errorf("ids and owners must not be exported, got %s", data)
+	}
+
+	exported, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported.Conversations) != 1 {
+		t.Fatalf("expected a single conversation, got %d", len(exported.Conversations))
+	}
+
+	parsed := exported.Conversations[0]
+	if parsed.Title != conversation.Title || len(parsed.Messages) != len(conversation.Messages) {
+		t.Fatalf("conversation changed on the round trip: %+v", parsed)
+	}
+	for i, message := range parsed.Messages {
+		original := conversation.Messages[i]
+		if message.Role != original.Role || message.Content != original.Content || message.ToolCallID != original.ToolCallID ||
+			!message.CreatedAt.Equal(original.CreatedAt) || len(message.ToolCalls) != len(original.ToolCalls) {
+			t.Errorf("message %d changed on the round trip: %+v", i, message)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", `conversations`},
+		{"unknown version", `{"version":2,"conversations":[{"title":"a","messages":[]}]}`},
+		{"no conversations", `{"version":1,"conversations":[]}`},
+		{"unknown field", `{"version":1,"conversations":[{"title":"a","messages":[]}],"owner":"x"}`},
+		{"unknown role", `{"version":1,"conversations":[{"title":"a","messages":[{"role":"system","content":"be nice"}]}]}`},
+		{"orphan tool result", `{"version":1,"conversations":[{"title":"a","messages":[{"role":"tool","content":"4","tool_call_id":"call_1"}]}]}`},
+		{"tool call without id", `{"version":1,"conversations":[{"title":"a","messages":[{"role":"assistant","content":"","tool_calls":[{"function":{"name":"calculator","arguments":"{}"}}]}]}]}`},
+		{"answered twice", `{"version":1,"conversations":[{"title":"a","messages":[
+			{"role":"assistant","content":"","tool_calls":[{"id":"call_1","function":{"name":"calculator","arguments":"{}"}}]},
+			{"role":"tool","content":"4","tool_call_id":"call_1"},
+			{"role":"tool","content":"4","tool_call_id":"call_1"}]}]}`},
+		{"unanswered tool call", `{"version":1,"conversations":[{"title":"a","messages":[
+			{"role":"assistant","content":"","tool_calls":[{"id":"call_1","function":{"name":"calculator","arguments":"{}"}}]},
+			{"role":"user","content":"What is 2+2?"}]}]}`},
+		{"partly answered tool calls", `{"version":1,"conversations":[{"title":"a","messages":[
+			{"role":"assistant","content":"","tool_calls":[{"id":"call_1","function":{"name":"calculator","arguments":"{}"}},
+				{"id":"call_2","function":{"name":"calculator","arguments":"{}"}}]},
+			{"role":"tool","content":"4","tool_call_id":"call_1"},
+			{"role":"assistant","content":"4"}]}]}`},
+		{"unanswered tool call at the end", `{"version":1,"conversations":[{"title":"a","messages":[
+			{"role":"user","content":"What is 2+2?"},
+			{"role":"assistant","content":"","tool_calls":[{"id":"call_1","function":{"name":"calculator","arguments":"{}"}}]}]}]}`},
+		{"long title", `{"version":1,"conversations":[{"title":"` + strings.Repeat("a", MaxTitleLength+1) + `","messages":[]}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse([]byte(test.data)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	unanswered := models.Conversation{
+		Title:    "Unanswered",
+		Messages: []models.ConversationMessage{{Role: "user", Content: "hello"}},
+	}
+
+	data, err := JSONL([]models.Conversation{newConversation(), unanswered})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected the unanswered conversation to be left out, got %d lines", len(lines))
+	}
+
+	var example fineTuningExample
+	if err := json.Unmarshal([]byte(lines[0]), &example); err != nil {
+		t.Fatal(err)
+	}
+	if len(example.Messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(example.Messages))
+	}
+	if call := example.Messages[1].ToolCalls[0]; call.Type != "function" || call.ID != "call_1" {
+		t.Errorf("unexpected tool call %+v", call)
+	}
+	if example.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("expected the tool result to keep its call id, got %+v", example.Messages[2])
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	data := string(Markdown([]models.Conversation{newConversation()}))
+
+	for _, expected := range []string{"# Arithmetic", "**User**:\n\nWhat is 2+2?", "**Tool call** `calculator`", "**Assistant** (gpt-4o-mini):\n\n4"} {
+		if !strings.Contains(data, expected) {
+			t.Errorf("expected %q in\n%s", expected, data)
+		}
+	}
+}
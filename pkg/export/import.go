@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+const (
+	MaxImportedConversations = 1000
+	MaxImportedMessages      = 10000
+	MaxTitleLength           = 256
+)
+
+// Parse reads conversations exported in the json format and checks that they can be recreated:
+// the roles are known, and every tool result answers a call made by the model before it.
+func Parse(data []byte) (*models.ConversationExport, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var exported models.ConversationExport
+	if err := decoder.Decode(&exported); err != nil {
+		return nil, fmt.Errorf("export: invalid json, %v", err)
+	}
+
+	if exported.Version != Version {
+		return nil, fmt.Errorf("export: unsupported version %d, expected %d", exported.Version, Version)
+	}
+	if len(exported.Conversations) == 0 {
+		return nil, fmt.Errorf("export: no conversations")
+	}
+	if len(exported.Conversations) > MaxImportedConversations {
+		return nil, fmt.Errorf("export: %d conversations, at most %d can be imported at once", len(exported.Conversations), MaxImportedConversations)
+	}
+
+	for i := range exported.Conversations {
+		if err := validateConversation(&exported.Conversations[i]); err != nil {
+			return nil, fmt.Errorf("export: conversation %d: %v", i, err)
+		}
+	}
+
+	return &exported, nil
+}
+
+func validateConversation(conversation *models.ExportedConversation) error {
+	conversation.Title = strings.TrimSpace(conversation.Title)
+	if utf8.RuneCountInString(conversation.Title) > MaxTitleLength {
+		return fmt.Errorf("title is longer than %d characters", MaxTitleLength)
+	}
+
+	if len(conversation.Messages) > MaxImportedMessages {
+		return fmt.Errorf("%d messages, at most %d are allowed", len(conversation.Messages), MaxImportedMessages)
+	}
+
+	// Ids of the tool calls which haven't got their result yet.
+	// The results must follow the calls right away, the upstream rejects the history otherwise.
+	pending := make(map[string]bool)
+
+	for i, message := range conversation.Messages {
+		if message.Role != "tool" && len(pending) > 0 {
+			return fmt.Errorf("message %d: %d tool calls of the preceding message haven't got their results", i, len(pending))
+		}
+
+		switch message.Role {
+		case "user":
+			if len(message.ToolCalls) > 0 || message.ToolCallID != "" {
+				return fmt.Errorf("message %d: user messages can't have tool calls", i)
+			}
+
+		case "assistant":
+			if message.ToolCallID != "" {
+				return fmt.Errorf("message %d: assistant messages can't have a tool_call_id", i)
+			}
+			for _, call := range message.ToolCalls {
+				if call.ID == "" || call.Function.Name == "" {
+					return fmt.Errorf("message %d: tool calls must have an id and a function name", i)
+				}
+				pending[call.ID] = true
+			}
+
+		case "tool":
+			if !pending[message.ToolCallID] {
+				return fmt.Errorf("message %d: tool result doesn't answer any preceding tool call", i)
+			}
+			delete(pending, message.ToolCallID)
+
+		default:
+			return fmt.Errorf("message %d: unknown role %q", i, message.Role)
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%d tool calls of the last message haven't got their results", len(pending))
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+const DefaultSystemPrompt = "You are a helpful assistant."
+
+// OpenAI accepts at most 4 stop sequences, and so does everyone who copies its api.
+const maxStopSequences = 4
+
+// Upper bound for a custom system prompt, in characters.
+const maxSystemPromptLength = 8192
+
+// Upper bound for a response schema, in bytes.
+const maxResponseSchemaLength = 32 * 1024
+
+// Per-model limits, in tokens.
+type ModelLimits struct {
+	ContextWindow   int
+	MaxOutputTokens int
+}
+
+// Prices of a model in US dollars per million tokens.
+type ModelPricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// Catalog describes the models a provider accepts.
+// It's the allow-list the parameters supplied by the clients are validated against.
+type Catalog struct {
+	DefaultModel string
+	// Empty if the provider doesn't support embeddings.
+	DefaultEmbeddingModel string
+	Models                map[string]ModelLimits
+	// Models without a price, the local ones for example, are free.
+	Pricing map[string]ModelPricing
+	// OpenAI accepts temperatures up to 2, Anthropic only up to 1.
+	MaxTemperature float64
+	SupportsSeed   bool
+	SupportsTools  bool
+}
+
+func (c *Catalog) modelNames() string {
+	names := make([]string, 0, len(c.Models))
+	for name := range c.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Resolve returns the model of the catalog a response was produced by. Providers report the dated snapshot
+// which answered, gpt-4o-mini-2024-07-18 for gpt-4o-mini for example. Unknown models are returned as is.
+func (c *Catalog) Resolve(model string) string {
+	if _, ok := c.Models[model]; ok {
+		return model
+	}
+
+	resolved := model
+	for name := range c.Models {
+		if strings.HasPrefix(model, name+"-") && (resolved == model || len(name) > len(resolved)) {
+			resolved = name
+		}
+	}
+	return resolved
+}
+
+// Cost returns the cost of the tokens in US dollars.
+func (c *Catalog) Cost(model string, promptTokens int, completionTokens int) float64 {
+	pricing := c.Pricing[c.Resolve(model)]
+	return (float64(promptTokens)*pricing.InputPerMillion + float64(completionTokens)*pricing.OutputPerMillion) / 1e6
+}
+
+// Limits returns the limits of the model, an empty model stands for the default one.
+func (c *Catalog) Limits(model string) (ModelLimits, bool) {
+	if model == "" {
+		model = c.DefaultModel
+	}
+	limits, supported := c.Models[model]
+	return limits, supported
+}
+
+// ValidateParams checks the parameters against the allow-list of models and their limits.
+// The returned error is meant to be shown to the client as is.
+func (c *Catalog) ValidateParams(params *models.OpenAIParams) error {
+	model := params.Model
+	if model == "" {
+		model = c.DefaultModel
+	}
+
+	limits, supported := c.Models[model]
+	if !supported {
+		return fmt.Errorf("model %q is not supported, supported models: %s", model, c.modelNames())
+	}
+
+	if params.Temperature != nil && (*params.Temperature < 0 || *params.Temperature > c.MaxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %v, got %v", c.MaxTemperature, *params.Temperature)
+	}
+
+	if params.TopP != nil && (*params.TopP < 0 || *params.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1, got %v", *params.TopP)
+	}
+
+	if params.MaxTokens != nil && (*params.MaxTokens < 1 || *params.MaxTokens > limits.MaxOutputTokens) {
+		return fmt.Errorf("max_tokens must be between 1 and %d for model %s, got %d",
+			limits.MaxOutputTokens, model, *params.MaxTokens)
+	}
+
+	if len(params.Stop) > maxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", maxStopSequences, len(params.Stop))
+	}
+
+	for _, stop := range params.Stop {
+		if stop == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+
+	if params.Seed != nil && !c.SupportsSeed {
+		return fmt.Errorf("seed is not supported by model %s", model)
+	}
+
+	if len(params.Tools) > 0 && !c.SupportsTools {
+		return fmt.Errorf("tools are not supported by model %s", model)
+	}
+
+	if len(params.SystemPrompt) > maxSystemPromptLength {
+		return fmt.Errorf("system_prompt must not be longer than %d characters", maxSystemPromptLength)
+	}
+
+	if len(params.ResponseSchema) > maxResponseSchemaLength {
+		return fmt.Errorf("response_schema must not be longer than %d bytes", maxResponseSchemaLength)
+	}
+
+	return nil
+}
+
+// NewChatRequest builds a chat completion request out of the conversation turns,
+// oldest first, and the parameters supplied by the client.
+// The parameters are expected to be validated with ValidateParams.
+func (c *Catalog) NewChatRequest(messages []models.OpenAIMessage, params *models.OpenAIParams) *models.OpenAIChatRequest {
+	model := params.Model
+	if model == "" {
+		model = c.DefaultModel
+	}
+
+	systemPrompt := params.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = DefaultSystemPrompt
+	}
+
+	var responseFormat *models.OpenAIResponseFormat
+	if len(params.ResponseSchema) > 0 {
+		responseFormat = &models.OpenAIResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &models.OpenAIJSONSchemaFormat{
+				Name:   "response",
+				Schema: params.ResponseSchema,
+			},
+		}
+	}
+
+	return &models.OpenAIChatRequest{
+		Model: model,
+		Messages: append([]models.OpenAIMessage{
+			{
+				Role:    "system",
+				Content: systemPrompt,
+			},
+		}, messages...),
+		Temperature:    params.Temperature,
+		TopP:           params.TopP,
+		MaxTokens:      params.MaxTokens,
+		Stop:           params.Stop,
+		Seed:           params.Seed,
+		ResponseFormat: responseFormat,
+	}
+}
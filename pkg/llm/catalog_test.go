@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+var testCatalog = &Catalog{
+	DefaultModel: "small",
+	Models: map[string]ModelLimits{
+		"small": {ContextWindow: 128000, MaxOutputTokens: 16384},
+		"large": {ContextWindow: 200000, MaxOutputTokens: 32768},
+	},
+	Pricing: map[string]ModelPricing{
+		"large": {InputPerMillion: 2, OutputPerMillion: 8},
+	},
+	MaxTemperature: 2,
+	SupportsSeed:   true,
+}
+
+func TestValidateParams(t *testing.T) {
+	temperature := 2.5
+	topP := -0.1
+	maxTokens := 20000
+	validMaxTokens := 1000
+	seed := int64(42)
+
+	testData := []struct {
+		catalog  *Catalog
+		params   models.OpenAIParams
+		expected string
+	}{
+		{testCatalog, models.OpenAIParams{}, ""},
+		{testCatalog, models.OpenAIParams{Model: "large", MaxTokens: &maxTokens, Stop: []string{"\n"}, Seed: &seed}, ""},
+		{testCatalog, models.OpenAIParams{Model: "davinci"}, "model \"davinci\" is not supported, supported models: large, small"},
+		{testCatalog, models.OpenAIParams{Temperature: &temperature}, "temperature must be between 0 and 2"},
+		{testCatalog, models.OpenAIParams{TopP: &topP}, "top_p must be between 0 and 1"},
+		{testCatalog, models.OpenAIParams{MaxTokens: &maxTokens}, "max_tokens must be between 1 and 16384"},
+		{testCatalog, models.OpenAIParams{MaxTokens: &validMaxTokens, Stop: []string{"a", "b", "c", "d", "e"}}, "at most 4 stop sequences are allowed"},
+		{testCatalog, models.OpenAIParams{SystemPrompt: strings.Repeat("x", maxSystemPromptLength+1)}, "system_prompt must not be longer"},
+		{&Catalog{DefaultModel: "small", Models: testCatalog.Models, MaxTemperature: 1}, models.OpenAIParams{Seed: &seed}, "seed is not supported"},
+		{testCatalog, models.OpenAIParams{Tools: []string{"calculator"}}, "tools are not supported"},
+	}
+
+	for _, data := range testData {
+		err := data.catalog.ValidateParams(&data.params)
+		if data.expected == "" {
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), data.expected) {
+			t.Fatalf("expected: %s, got: %v", data.expected, err)
+		}
+	}
+}
+
+func TestNewChatRequestDefaults(t *testing.T) {
+	request := testCatalog.NewChatRequest([]models.OpenAIMessage{{Role: "user", Content: "hi"}}, &models.OpenAIParams{})
+	if request.Model != testCatalog.DefaultModel {
+		t.Errorf("expected model: %s, got: %s", testCatalog.DefaultModel, request.Model)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Role != "system" || request.Messages[0].Content != DefaultSystemPrompt {
+		t.Errorf("expected the default system prompt to be prepended, got: %v", request.Messages)
+	}
+}
+
+func TestNewChatRequestResponseSchema(t *testing.T) {
+	request := testCatalog.NewChatRequest(nil, &models.OpenAIParams{ResponseSchema: []byte(`{"type":"object"}`)})
+	if request.ResponseFormat == nil || request.ResponseFormat.Type != "json_schema" || string(request.ResponseFormat.JSONSchema.Schema) != `{"type":"object"}` {
+		t.Errorf("expected the schema to be sent as response_format, got: %+v", request.ResponseFormat)
+	}
+}
+
+func TestCost(t *testing.T) {
+	if cost := testCatalog.Cost("large", 500000, 250000); cost != 3 {
+		t.Errorf("expected cost: 3, got: %v", cost)
+	}
+	if cost := testCatalog.Cost("small", 500000, 250000); cost != 0 {
+		t.Errorf("expected a model without a price to be free, got: %v", cost)
+	}
+	if cost := testCatalog.Cost("large-2024-07-18", 500000, 250000); cost != 3 {
+		t.Errorf("expected a snapshot to cost as much as its model, got: %v", cost)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	catalog := &Catalog{Models: map[string]ModelLimits{"gpt-4o": {}, "gpt-4o-mini": {}}}
+
+	tests := []struct {
+		model    string
+		expected string
+	}{
+		{model: "gpt-4o-mini", expected: "gpt-4o-mini"},
+		{model: "gpt-4o-2024-08-06", expected: "gpt-4o"},
+		// The longest model the snapshot belongs to.
+		{model: "gpt-4o-mini-2024-07-18", expected: "gpt-4o-mini"},
+		{model: "gpt-4o1", expected: "gpt-4o1"},
+		{model: "llama3", expected: "llama3"},
+	}
+	for _, test := range tests {
+		if resolved := catalog.Resolve(test.model); resolved != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.model, test.expected, resolved)
+		}
+	}
+}
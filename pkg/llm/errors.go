@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrNotSupported is returned when the provider doesn't implement the requested capability.
+var ErrNotSupported = errors.New("not supported by the provider")
+
+// APIError is the common part of all the errors returned by the providers' apis.
+// It's returned as is only if the error doesn't fall into any of the known categories,
+// use errors.As to match the typed errors below.
+type APIError struct {
+	// The name of the provider which returned the error.
+	Provider   string
+	StatusCode int
+	Type       string
+	Code       string
+	Param      string
+	Message    string
+	// The delay requested by the server with Retry-After headers, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %s (status: %d, code: %s)", e.Provider, e.Message, e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("%s: %s (status: %d)", e.Provider, e.Message, e.StatusCode)
+}
+
+// 429, either too many requests or the quota has been used up.
+type RateLimitError struct{ *APIError }
+
+// 400, 404, 409 and 422, the request was rejected by the provider.
+type InvalidRequestError struct{ *APIError }
+
+// 401 and 403, the api key is invalid or doesn't have access to the resource.
+type AuthenticationError struct{ *APIError }
+
+// 5xx, the provider failed to process a valid request.
+type ServerError struct{ *APIError }
+
+// The prompt together with max_tokens doesn't fit into the model's context window.
+type ContextLengthExceededError struct{ *APIError }
+
+func (e *RateLimitError) Unwrap() error             { return e.APIError }
+func (e *InvalidRequestError) Unwrap() error        { return e.APIError }
+func (e *AuthenticationError) Unwrap() error        { return e.APIError }
+func (e *ServerError) Unwrap() error                { return e.APIError }
+func (e *ContextLengthExceededError) Unwrap() error { return e.APIError }
+
+// QuotaExceeded reports whether the account ran out of credits,
+// in which case retrying the request is pointless.
+func (e *RateLimitError) QuotaExceeded() bool {
+	return e.Code == "insufficient_quota"
+}
+
+// ClassifyError wraps the error into one of the typed errors based on its status code.
+// Providers are expected to recognize ContextLengthExceededError themselves,
+// since every one of them reports it differently.
+func ClassifyError(apiError *APIError) error {
+	switch status := apiError.StatusCode; {
+	case status == http.StatusTooManyRequests:
+		return &RateLimitError{apiError}
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return &AuthenticationError{apiError}
+	case status == http.StatusBadRequest, status == http.StatusNotFound,
+		status == http.StatusConflict, status == http.StatusUnprocessableEntity:
+		return &InvalidRequestError{apiError}
+	// Anthropic responds with 529 when it's overloaded.
+	case status >= 500:
+		return &ServerError{apiError}
+	default:
+		return apiError
+	}
+}
+
+// ParseRetryAfter reads the delay requested by the server.
+// OpenAI sends the non-standard `retry-after-ms` header with a millisecond precision,
+// in addition to `Retry-After` which is either a number of seconds or an http date.
+// Returns 0 if neither of the headers is present or valid.
+func ParseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After-Ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		return 0
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
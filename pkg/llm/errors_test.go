@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	testData := []struct {
+		header   http.Header
+		expected time.Duration
+	}{
+		{http.Header{}, 0},
+		{http.Header{"Retry-After": {"2"}}, 2 * time.Second},
+		{http.Header{"Retry-After": {"0.5"}}, 500 * time.Millisecond},
+		{http.Header{"Retry-After": {"-1"}}, 0},
+		{http.Header{"Retry-After": {"Mon, 01 Jan 2024 12:00:10 GMT"}}, 10 * time.Second},
+		{http.Header{"Retry-After": {"Mon, 01 Jan 2024 11:00:00 GMT"}}, 0},
+		{http.Header{"Retry-After": {"2"}, "Retry-After-Ms": {"150"}}, 150 * time.Millisecond},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+	}
+
+	for _, data := range testData {
+		if got := ParseRetryAfter(data.header, now); got != data.expected {
+			t.Fatalf("header %v, expected: %v, got: %v", data.header, data.expected, got)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	testData := []struct {
+		status int
+		check  func(err error) bool
+	}{
+		{429, func(err error) bool { _, ok := err.(*RateLimitError); return ok }},
+		{401, func(err error) bool { _, ok := err.(*AuthenticationError); return ok }},
+		{404, func(err error) bool { _, ok := err.(*InvalidRequestError); return ok }},
+		{529, func(err error) bool { _, ok := err.(*ServerError); return ok }},
+		{418, func(err error) bool { _, ok := err.(*APIError); return ok }},
+	}
+
+	for _, data := range testData {
+		if err := ClassifyError(&APIError{StatusCode: data.status}); !data.check(err) {
+			t.Fatalf("unexpected error for status %d: %T", data.status, err)
+		}
+	}
+}
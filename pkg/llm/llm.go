@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Provider is implemented by every LLM backend the server can talk to.
+// Requests and responses use OpenAI's chat completion format,
+// backends with a different wire format translate it on their side.
+type Provider interface {
+	// The name of the backend, used in logs.
+	Name() string
+	// The models the backend accepts together with their limits.
+	Catalog() *Catalog
+	Chat(ctx context.Context, request *models.OpenAIChatRequest) (*models.OpenAIResp, error)
+	// ChatStream invokes onChunk for every chunk of the completion, in order.
+	// Returning an error from onChunk, or cancelling ctx, aborts the upstream request.
+	ChatStream(ctx context.Context, request *models.OpenAIChatRequest, onChunk func(chunk *models.OpenAIStreamChunk) error) error
+	// Embed returns ErrNotSupported if the backend doesn't provide embeddings.
+	Embed(ctx context.Context, request *models.EmbeddingRequest) (*models.EmbeddingResponse, error)
+}
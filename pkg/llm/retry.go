@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/isnastish/openai/pkg/log"
+)
+
+type RetryPolicy struct {
+	// The number of retries after the first attempt, 0 disables retries.
+	MaxRetries int
+	// The upper bound of the first delay, doubled on every attempt.
+	BaseDelay time.Duration
+	// The upper bound of any delay. If the server asks to wait longer than that,
+	// the request is not retried.
+	MaxDelay time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+}
+
+// backoff returns a random delay between 0 and BaseDelay*2^attempt, capped by MaxDelay.
+// Full jitter spreads the retries of concurrent requests which failed at the same time.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	// Shifting further would overflow.
+	if attempt < 32 {
+		if delay := p.BaseDelay << attempt; delay > 0 && delay < ceiling {
+			ceiling = delay
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// delay decides whether the failed attempt should be retried and how long to wait before that.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxRetries {
+		return 0, false
+	}
+
+	var rateLimitError *RateLimitError
+	var serverError *ServerError
+	var netError net.Error
+
+	var retryAfter time.Duration
+	switch {
+	case errors.As(err, &rateLimitError):
+		if rateLimitError.QuotaExceeded() {
+			return 0, false
+		}
+		retryAfter = rateLimitError.RetryAfter
+	case errors.As(err, &serverError):
+		retryAfter = serverError.RetryAfter
+	case errors.As(err, &netError), errors.Is(err, io.ErrUnexpectedEOF):
+	default:
+		return 0, false
+	}
+
+	if retryAfter > 0 {
+		if retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	return p.backoff(attempt), true
+}
+
+// Do sends the request built by newRequest, retrying transient failures according to the retry policy.
+// newRequest is called for every attempt, since the body of a sent request cannot be reused.
+// On success the caller is responsible for closing the response body,
+// non-2xx responses are converted into typed errors with parseError.
+func Do(ctx context.Context, httpClient *http.Client, policy RetryPolicy,
+	newRequest func() (*http.Request, error), parseError func(resp *http.Response, body []byte) error) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return resp, nil
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = parseError(resp, body)
+		}
+
+		// Never retry a request the caller is no longer interested in.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		delay, retry := policy.delay(attempt, err)
+		if !retry {
+			return nil, err
+		}
+
+		log.Logger.Warn("llm: request to %s failed, retrying in %v (attempt %d/%d): %v",
+			req.URL.Host, delay, attempt+1, policy.MaxRetries, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RetryPolicyFromEnv returns the default policy with the number of retries
+// overwritten by the given environment variable, if it's set.
+func RetryPolicyFromEnv(variable string) (RetryPolicy, error) {
+	policy := DefaultRetryPolicy
+	if maxRetries, set := os.LookupEnv(variable); set && maxRetries != "" {
+		value, err := strconv.Atoi(maxRetries)
+		if err != nil || value < 0 {
+			return policy, fmt.Errorf("%s must be a non-negative integer", variable)
+		}
+		policy.MaxRetries = value
+	}
+	return policy, nil
+}
@@ -0,0 +1,15 @@
+package llm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffIsCapped(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 64; attempt++ {
+		if delay := policy.backoff(attempt); delay < 0 || delay > policy.MaxDelay {
+			t.Fatalf("attempt %d, delay %v is out of range", attempt, delay)
+		}
+	}
+}
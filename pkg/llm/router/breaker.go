@@ -0,0 +1,122 @@
+package router
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	// Requests flow through, consecutive failures are counted.
+	breakerClosed breakerState = iota
+	// Requests are rejected until the cooldown elapses.
+	breakerOpen
+	// A single trial request is let through to probe the upstream.
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// circuitBreaker stops sending requests to an upstream which keeps failing,
+// so the requests go straight to the fallback instead of waiting for a timeout every time.
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// Set while the trial request of a half-open breaker is in flight.
+	probing bool
+}
+
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+	}
+}
+
+// allow reports whether a request can be sent to the upstream.
+// Every allowed request must be followed by either success or failure.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// failure records a failed request, returns true if the breaker has just opened.
+func (b *circuitBreaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	b.failures++
+
+	if b.state == breakerHalfOpen || b.failures >= b.failureThreshold {
+		opened := b.state != breakerOpen
+		b.state = breakerOpen
+		b.openedAt = b.now()
+		return opened
+	}
+
+	return false
+}
+
+// release gives up the permission obtained with allow without judging the upstream,
+// for requests which failed because of the client.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *circuitBreaker) currentState() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	breaker.allow()
+	if breaker.failure() {
+		t.Fatalf("expected the breaker to stay closed after a single failure")
+	}
+	breaker.allow()
+	if !breaker.failure() {
+		t.Fatalf("expected the breaker to open after reaching the threshold")
+	}
+	if breaker.allow() {
+		t.Fatalf("expected an open breaker to reject requests")
+	}
+
+	now = now.Add(time.Minute)
+	if !breaker.allow() {
+		t.Fatalf("expected a trial request after the cooldown")
+	}
+	if breaker.allow() {
+		t.Fatalf("expected a single trial request at a time")
+	}
+	if !breaker.failure() {
+		t.Fatalf("expected a failed trial to open the breaker again")
+	}
+
+	now = now.Add(time.Minute)
+	breaker.allow()
+	breaker.success()
+	if breaker.currentState() != breakerClosed || !breaker.allow() {
+		t.Fatalf("expected a successful trial to close the breaker")
+	}
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// Upstreams are tried in the configured order.
+	PolicyFallback = "fallback"
+	// The first upstream is picked at random proportionally to the weights,
+	// the rest are tried in the configured order.
+	PolicyWeighted = "weighted"
+	// Short prompts go to the cheapest upstream first, long ones follow the configured order.
+	PolicyCost = "cost"
+)
+
+const (
+	defaultFailureThreshold  = 5
+	defaultCooldown          = 30 * time.Second
+	defaultShortPromptTokens = 500
+)
+
+// Duration accepts Go duration strings such as "30s" in json.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+type UpstreamConfig struct {
+	// Unique name used in logs, defaults to the backend name.
+	Name string `json:"name"`
+	// The backend to instantiate: openai, anthropic or ollama.
+	Backend string `json:"backend"`
+	// Pins the model sent to this upstream. If empty, the model requested by the client
+	// is kept when the backend supports it, otherwise the backend's default model is used.
+	Model string `json:"model"`
+	// Relative share of the traffic under the weighted policy.
+	Weight float64 `json:"weight"`
+	// Price of a million input tokens, used by the cost policy to find the cheapest upstream.
+	CostPerMillionTokens float64 `json:"cost_per_million_tokens"`
+	// How long to wait for a response (or the first chunk of a stream) before falling back,
+	// zero means no limit.
+	Timeout Duration `json:"timeout"`
+}
+
+type BreakerConfig struct {
+	// Consecutive failures after which the upstream is taken out of rotation.
+	FailureThreshold int `json:"failure_threshold"`
+	// How long the upstream stays out of rotation before a trial request is let through.
+	Cooldown Duration `json:"cooldown"`
+}
+
+type Config struct {
+	Policy string `json:"policy"`
+	// Prompts estimated to be shorter than that are considered short by the cost policy.
+	ShortPromptTokens int              `json:"short_prompt_tokens"`
+	Breaker           BreakerConfig    `json:"breaker"`
+	Upstreams         []UpstreamConfig `json:"upstreams"`
+}
+
+// LoadConfig reads a json routing config from the file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("router: failed to read config, error: %v", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("router: failed to parse config, error: %v", err)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// validate checks the config and fills in the defaults.
+func (c *Config) validate() error {
+	switch c.Policy {
+	case "":
+		c.Policy = PolicyFallback
+	case PolicyFallback, PolicyWeighted, PolicyCost:
+	default:
+		return fmt.Errorf("router: unknown policy %q", c.Policy)
+	}
+
+	if len(c.Upstreams) == 0 {
+		return fmt.Errorf("router: at least one upstream is required")
+	}
+
+	if c.ShortPromptTokens <= 0 {
+		c.ShortPromptTokens = defaultShortPromptTokens
+	}
+	if c.Breaker.FailureThreshold <= 0 {
+		c.Breaker.FailureThreshold = defaultFailureThreshold
+	}
+	if c.Breaker.Cooldown <= 0 {
+		c.Breaker.Cooldown = Duration(defaultCooldown)
+	}
+
+	names := make(map[string]bool, len(c.Upstreams))
+	for i := range c.Upstreams {
+		upstream := &c.Upstreams[i]
+		if upstream.Backend == "" {
+			return fmt.Errorf("router: upstream %d has no backend", i)
+		}
+		if upstream.Name == "" {
+			upstream.Name = upstream.Backend
+		}
+		if names[upstream.Name] {
+			return fmt.Errorf("router: duplicate upstream name %q", upstream.Name)
+		}
+		names[upstream.Name] = true
+
+		if upstream.Weight < 0 || upstream.CostPerMillionTokens < 0 || upstream.Timeout < 0 {
+			return fmt.Errorf("router: upstream %s has a negative weight, cost or timeout", upstream.Name)
+		}
+		if c.Policy == PolicyWeighted && upstream.Weight == 0 {
+			upstream.Weight = 1
+		}
+	}
+
+	return nil
+}
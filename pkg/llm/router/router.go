@@ -0,0 +1,391 @@
+// Package router spreads LLM requests across several upstreams.
+// The Router implements llm.Provider, so the rest of the server
+// doesn't know whether it's talking to a single backend or to a fleet of them.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// The cause of the context cancellation when an upstream didn't respond in time.
+var errUpstreamTimeout = errors.New("upstream timed out")
+
+// consumerError wraps errors returned by the consumer of a stream,
+// which must not count against the health of the upstream.
+type consumerError struct {
+	err error
+}
+
+func (e *consumerError) Error() string {
+	return e.err.Error()
+}
+
+type upstream struct {
+	UpstreamConfig
+	provider llm.Provider
+	breaker  *circuitBreaker
+}
+
+// Router routes every request to an upstream chosen by the policy,
+// and falls back to the next upstream when the chosen one fails with a 5xx, a rate limit or a timeout.
+// Upstreams which keep failing are taken out of rotation by a circuit breaker.
+type Router struct {
+	policy            string
+	shortPromptTokens int
+	upstreams         []*upstream
+	catalog           *llm.Catalog
+	// Returns a number in [0, 1), replaced in tests.
+	random func() float64
+}
+
+// New creates the upstreams described by the config, newProvider instantiates a backend by its name.
+// Upstreams sharing a backend share the provider too.
+func New(config *Config, newProvider func(backend string) (llm.Provider, error)) (*Router, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	router := &Router{
+		policy:            config.Policy,
+		shortPromptTokens: config.ShortPromptTokens,
+		random:            rand.Float64,
+	}
+
+	providers := make(map[string]llm.Provider)
+	for _, upstreamConfig := range config.Upstreams {
+		provider, ok := providers[upstreamConfig.Backend]
+		if !ok {
+			var err error
+			provider, err = newProvider(upstreamConfig.Backend)
+			if err != nil {
+				return nil, fmt.Errorf("router: failed to create upstream %s, error: %v", upstreamConfig.Name, err)
+			}
+			providers[upstreamConfig.Backend] = provider
+		}
+
+		if upstreamConfig.Model != "" {
+			if _, supported := provider.Catalog().Models[upstreamConfig.Model]; !supported {
+				return nil, fmt.Errorf("router: model %s is not supported by upstream %s", upstreamConfig.Model, upstreamConfig.Name)
+			}
+		}
+
+		router.upstreams = append(router.upstreams, &upstream{
+			UpstreamConfig: upstreamConfig,
+			provider:       provider,
+			breaker:        newCircuitBreaker(config.Breaker.FailureThreshold, time.Duration(config.Breaker.Cooldown)),
+		})
+	}
+
+	router.catalog = mergeCatalogs(router.upstreams)
+
+	return router, nil
+}
+
+// mergeCatalogs accepts every model any of the upstreams accepts, so the clients can ask for a specific one.
+// The parameters are adjusted to the limits of the upstream which ends up serving the request.
+func mergeCatalogs(upstreams []*upstream) *llm.Catalog {
+	catalog := &llm.Catalog{
+		DefaultModel: upstreams[0].model(""),
+		Models:       make(map[string]llm.ModelLimits),
+		Pricing:      make(map[string]llm.ModelPricing),
+	}
+
+	for _, upstream := range upstreams {
+		upstreamCatalog := upstream.provider.Catalog()
+		for name, limits := range upstreamCatalog.Models {
+			if _, exists := catalog.Models[name]; !exists {
+				catalog.Models[name] = limits
+			}
+		}
+		for name, pricing := range upstreamCatalog.Pricing {
+			if _, exists := catalog.Pricing[name]; !exists {
+				catalog.Pricing[name] = pricing
+			}
+		}
+		if upstreamCatalog.MaxTemperature > catalog.MaxTemperature {
+			catalog.MaxTemperature = upstreamCatalog.MaxTemperature
+		}
+		if upstreamCatalog.SupportsSeed {
+			catalog.SupportsSeed = true
+		}
+		if upstreamCatalog.SupportsTools {
+			catalog.SupportsTools = true
+		}
+		if catalog.DefaultEmbeddingModel == "" {
+			catalog.DefaultEmbeddingModel = upstreamCatalog.DefaultEmbeddingModel
+		}
+	}
+
+	return catalog
+}
+
+func (r *Router) Name() string {
+	names := make([]string, 0, len(r.upstreams))
+	for _, upstream := range r.upstreams {
+		names = append(names, upstream.Name)
+	}
+	return fmt.Sprintf("router (%s: %s)", r.policy, strings.Join(names, ", "))
+}
+
+func (r *Router) Catalog() *llm.Catalog {
+	return r.catalog
+}
+
+// model returns the model to send to the upstream when the client asked for the given one.
+func (u *upstream) model(requested string) string {
+	if u.Model != "" {
+		return u.Model
+	}
+	catalog := u.provider.Catalog()
+	if _, supported := catalog.Models[requested]; supported {
+		return requested
+	}
+	return catalog.DefaultModel
+}
+
+// adapt rewrites the request to fit the upstream, since the parameters were validated
+// against the merged catalog and might exceed the limits of this particular backend.
+func (u *upstream) adapt(request *models.OpenAIChatRequest) *models.OpenAIChatRequest {
+	catalog := u.provider.Catalog()
+
+	adapted := *request
+	adapted.Model = u.model(request.Model)
+
+	if adapted.Temperature != nil && *adapted.Temperature > catalog.MaxTemperature {
+		temperature := catalog.MaxTemperature
+		adapted.Temperature = &temperature
+	}
+
+	if limits, ok := catalog.Limits(adapted.Model); ok && adapted.MaxTokens != nil && *adapted.MaxTokens > limits.MaxOutputTokens {
+		maxTokens := limits.MaxOutputTokens
+		adapted.MaxTokens = &maxTokens
+	}
+
+	if !catalog.SupportsSeed {
+		adapted.Seed = nil
+	}
+
+	if !catalog.SupportsTools {
+		adapted.Tools = nil
+	}
+
+	return &adapted
+}
+
+// estimateTokens is a rough estimate of the prompt size, about four characters per token for English text.
+func estimateTokens(messages []models.OpenAIMessage) int {
+	characters := 0
+	for _, message := range messages {
+		characters += len(message.Content)
+	}
+	return characters / 4
+}
+
+// order returns the upstreams in the order they should be tried for the request.
+func (r *Router) order(request *models.OpenAIChatRequest) ([]*upstream, string) {
+	order := append([]*upstream(nil), r.upstreams...)
+
+	switch r.policy {
+	case PolicyWeighted:
+		total := 0.0
+		for _, upstream := range order {
+			total += upstream.Weight
+		}
+		point := r.random() * total
+		for i, upstream := range order {
+			point -= upstream.Weight
+			if point < 0 || i == len(order)-1 {
+				// Move the picked upstream to the front, keeping the rest in the configured order.
+				copy(order[1:i+1], order[:i])
+				order[0] = upstream
+				break
+			}
+		}
+		return order, fmt.Sprintf("weighted pick %s", order[0].Name)
+
+	case PolicyCost:
+		tokens := estimateTokens(request.Messages)
+		if tokens >= r.shortPromptTokens {
+			return order, fmt.Sprintf("long prompt (~%d tokens)", tokens)
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return order[i].CostPerMillionTokens < order[j].CostPerMillionTokens
+		})
+		return order, fmt.Sprintf("short prompt (~%d tokens), cheapest first", tokens)
+
+	default:
+		return order, "configured order"
+	}
+}
+
+// shouldFallback reports whether the failure is specific to the upstream,
+// so that another upstream has a chance to succeed.
+// Errors caused by the request itself, such as invalid parameters, would fail everywhere.
+func shouldFallback(err error) bool {
+	var serverError *llm.ServerError
+	var rateLimitError *llm.RateLimitError
+	var netError net.Error
+
+	return errors.As(err, &serverError) ||
+		errors.As(err, &rateLimitError) ||
+		errors.As(err, &netError) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, errUpstreamTimeout)
+}
+
+// attempt is a single call to an upstream.
+// It returns whether the upstream has already delivered a part of the response,
+// in which case it's too late to fall back.
+type attempt func(ctx context.Context, upstream *upstream, request *models.OpenAIChatRequest) (committed bool, err error)
+
+func (r *Router) route(ctx context.Context, request *models.OpenAIChatRequest, call attempt) error {
+	order, reason := r.order(request)
+
+	names := make([]string, len(order))
+	for i, upstream := range order {
+		names[i] = upstream.Name
+	}
+	log.Logger.Info("router: %s policy, %s, order: %s", r.policy, reason, strings.Join(names, " -> "))
+
+	var lastErr error
+	for i, upstream := range order {
+		if !upstream.breaker.allow() {
+			log.Logger.Warn("router: skipping upstream %s, circuit breaker is %s", upstream.Name, upstream.breaker.currentState())
+			continue
+		}
+
+		adapted := upstream.adapt(request)
+		if i > 0 {
+			log.Logger.Info("router: falling back to upstream %s, model %s", upstream.Name, adapted.Model)
+		} else {
+			log.Logger.Info("router: sending request to upstream %s, model %s", upstream.Name, adapted.Model)
+		}
+
+		committed, err := call(ctx, upstream, adapted)
+		if err == nil {
+			upstream.breaker.success()
+			return nil
+		}
+
+		var consumerErr *consumerError
+		if errors.As(err, &consumerErr) {
+			upstream.breaker.release()
+			return consumerErr.err
+		}
+
+		// Neither a cancelled request nor a bad one says anything about the health of the upstream.
+		if ctx.Err() != nil || !shouldFallback(err) {
+			upstream.breaker.release()
+			return err
+		}
+
+		if upstream.breaker.failure() {
+			log.Logger.Warn("router: circuit breaker for upstream %s opened", upstream.Name)
+		}
+
+		if committed {
+			return err
+		}
+
+		log.Logger.Warn("router: upstream %s failed: %v", upstream.Name, err)
+		lastErr = err
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+
+	return &llm.ServerError{APIError: &llm.APIError{
+		Provider:   "router",
+		StatusCode: http.StatusServiceUnavailable,
+		Message:    "all upstreams are unavailable",
+	}}
+}
+
+// withTimeout limits the attempt by the upstream's timeout, if it has one.
+func (u *upstream) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if u.Timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, time.Duration(u.Timeout), errUpstreamTimeout)
+}
+
+// timeoutError replaces the error of an attempt cut short by the upstream's timeout,
+// since the provider only sees a cancelled context.
+func (u *upstream) timeoutError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), errUpstreamTimeout) {
+		return fmt.Errorf("%w: %s didn't respond within %v", errUpstreamTimeout, u.Name, time.Duration(u.Timeout))
+	}
+	return err
+}
+
+func (r *Router) Chat(ctx context.Context, request *models.OpenAIChatRequest) (*models.OpenAIResp, error) {
+	var response *models.OpenAIResp
+
+	err := r.route(ctx, request, func(ctx context.Context, upstream *upstream, request *models.OpenAIChatRequest) (bool, error) {
+		attemptCtx, cancel := upstream.withTimeout(ctx)
+		defer cancel()
+
+		var err error
+		response, err = upstream.provider.Chat(attemptCtx, request)
+		return false, upstream.timeoutError(attemptCtx, err)
+	})
+
+	return response, err
+}
+
+// ChatStream falls back only until the first chunk is delivered,
+// a partially streamed answer cannot be taken back.
+// The upstream's timeout applies to the first chunk only.
+func (r *Router) ChatStream(ctx context.Context, request *models.OpenAIChatRequest, onChunk func(chunk *models.OpenAIStreamChunk) error) error {
+	return r.route(ctx, request, func(ctx context.Context, upstream *upstream, request *models.OpenAIChatRequest) (bool, error) {
+		attemptCtx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		var timer *time.Timer
+		if upstream.Timeout > 0 {
+			timer = time.AfterFunc(time.Duration(upstream.Timeout), func() { cancel(errUpstreamTimeout) })
+			defer timer.Stop()
+		}
+
+		committed := false
+		err := upstream.provider.ChatStream(attemptCtx, request, func(chunk *models.OpenAIStreamChunk) error {
+			if !committed {
+				committed = true
+				if timer != nil {
+					timer.Stop()
+				}
+			}
+			if err := onChunk(chunk); err != nil {
+				return &consumerError{err: err}
+			}
+			return nil
+		})
+
+		return committed, upstream.timeoutError(attemptCtx, err)
+	})
+}
+
+// Embed doesn't fall back: vectors produced by different embedding models are not comparable,
+// so all of them have to come from the same upstream.
+func (r *Router) Embed(ctx context.Context, request *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	for _, upstream := range r.upstreams {
+		if upstream.provider.Catalog().DefaultEmbeddingModel != "" {
+			return upstream.provider.Embed(ctx, request)
+		}
+	}
+	return nil, llm.ErrNotSupported
+}
@@ -0,0 +1,278 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+type fakeProvider struct {
+	name    string
+	catalog *llm.Catalog
+	// Returned by every call, nil means success.
+	err error
+	// Blocks the call until the context is cancelled.
+	hang     bool
+	requests []*models.OpenAIChatRequest
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Catalog() *llm.Catalog {
+	return p.catalog
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, request *models.OpenAIChatRequest) (*models.OpenAIResp, error) {
+	p.requests = append(p.requests, request)
+	if p.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &models.OpenAIResp{Model: request.Model}, nil
+}
+
+func (p *fakeProvider) ChatStream(ctx context.Context, request *models.OpenAIChatRequest, onChunk func(chunk *models.OpenAIStreamChunk) error) error {
+	p.requests = append(p.requests, request)
+	if err := onChunk(&models.OpenAIStreamChunk{Model: request.Model}); err != nil {
+		return err
+	}
+	return p.err
+}
+
+func (p *fakeProvider) Embed(ctx context.Context, request *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	return nil, llm.ErrNotSupported
+}
+
+func newFakeProvider(name string, model string) *fakeProvider {
+	return &fakeProvider{
+		name: name,
+		catalog: &llm.Catalog{
+			DefaultModel: model,
+			Models:       map[string]llm.ModelLimits{model: {ContextWindow: 8192, MaxOutputTokens: 1024}},
+			// Same as Anthropic.
+			MaxTemperature: 1,
+		},
+	}
+}
+
+func serverError() error {
+	return &llm.ServerError{APIError: &llm.APIError{StatusCode: http.StatusBadGateway, Message: "bad gateway"}}
+}
+
+func newTestRouter(t *testing.T, config *Config, providers ...*fakeProvider) *Router {
+	t.Helper()
+
+	byName := make(map[string]*fakeProvider)
+	for _, provider := range providers {
+		byName[provider.name] = provider
+	}
+
+	router, err := New(config, func(backend string) (llm.Provider, error) {
+		provider, ok := byName[backend]
+		if !ok {
+			return nil, errors.New("unknown backend")
+		}
+		return provider, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create router: %v", err)
+	}
+	return router
+}
+
+func testRequest(prompt string) *models.OpenAIChatRequest {
+	return &models.OpenAIChatRequest{Messages: []models.OpenAIMessage{{Role: "user", Content: prompt}}}
+}
+
+func TestFallbackOnServerError(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	primary.err = serverError()
+	secondary := newFakeProvider("secondary", "small")
+
+	router := newTestRouter(t, &Config{Upstreams: []UpstreamConfig{{Backend: "primary"}, {Backend: "secondary"}}}, primary, secondary)
+
+	resp, err := router.Chat(context.Background(), testRequest("hi"))
+	if err != nil {
+		t.Fatalf("expected the secondary upstream to succeed, got: %v", err)
+	}
+	if resp.Model != "small" {
+		t.Errorf("expected the request to be sent with the secondary's model, got: %s", resp.Model)
+	}
+}
+
+func TestNoFallbackOnInvalidRequest(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	primary.err = &llm.InvalidRequestError{APIError: &llm.APIError{StatusCode: http.StatusBadRequest, Message: "invalid"}}
+	secondary := newFakeProvider("secondary", "small")
+
+	router := newTestRouter(t, &Config{Upstreams: []UpstreamConfig{{Backend: "primary"}, {Backend: "secondary"}}}, primary, secondary)
+
+	_, err := router.Chat(context.Background(), testRequest("hi"))
+	var invalidRequestError *llm.InvalidRequestError
+	if !errors.As(err, &invalidRequestError) {
+		t.Fatalf("expected invalid request error, got: %v", err)
+	}
+	if len(secondary.requests) != 0 {
+		t.Errorf("expected no requests to the secondary upstream, got %d", len(secondary.requests))
+	}
+	if router.upstreams[0].breaker.currentState() != breakerClosed {
+		t.Errorf("expected client errors not to affect the circuit breaker")
+	}
+}
+
+func TestFallbackOnTimeout(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	primary.hang = true
+	secondary := newFakeProvider("secondary", "small")
+
+	router := newTestRouter(t, &Config{Upstreams: []UpstreamConfig{
+		{Backend: "primary", Timeout: Duration(10 * time.Millisecond)},
+		{Backend: "secondary"},
+	}}, primary, secondary)
+
+	if _, err := router.Chat(context.Background(), testRequest("hi")); err != nil {
+		t.Fatalf("expected the secondary upstream to succeed, got: %v", err)
+	}
+}
+
+func TestCircuitBreakerSkipsFailingUpstream(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	primary.err = serverError()
+	secondary := newFakeProvider("secondary", "small")
+
+	router := newTestRouter(t, &Config{
+		Breaker:   BreakerConfig{FailureThreshold: 2, Cooldown: Duration(time.Hour)},
+		Upstreams: []UpstreamConfig{{Backend: "primary"}, {Backend: "secondary"}},
+	}, primary, secondary)
+
+	for i := 0; i < 5; i++ {
+		if _, err := router.Chat(context.Background(), testRequest("hi")); err != nil {
+			t.Fatalf("expected the secondary upstream to succeed, got: %v", err)
+		}
+	}
+	if len(primary.requests) != 2 {
+		t.Errorf("expected the primary to receive 2 requests before the breaker opened, got %d", len(primary.requests))
+	}
+}
+
+func TestAllUpstreamsFail(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	primary.err = serverError()
+
+	router := newTestRouter(t, &Config{
+		Breaker:   BreakerConfig{FailureThreshold: 1, Cooldown: Duration(time.Hour)},
+		Upstreams: []UpstreamConfig{{Backend: "primary"}},
+	}, primary)
+
+	if _, err := router.Chat(context.Background(), testRequest("hi")); err == nil || !strings.Contains(err.Error(), "bad gateway") {
+		t.Fatalf("expected the upstream's error, got: %v", err)
+	}
+	_, err := router.Chat(context.Background(), testRequest("hi"))
+	var serverError *llm.ServerError
+	if !errors.As(err, &serverError) || serverError.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected service unavailable once the breaker is open, got: %v", err)
+	}
+}
+
+func TestStreamDoesNotFallBackAfterFirstChunk(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	primary.err = serverError()
+	secondary := newFakeProvider("secondary", "small")
+
+	router := newTestRouter(t, &Config{Upstreams: []UpstreamConfig{{Backend: "primary"}, {Backend: "secondary"}}}, primary, secondary)
+
+	chunks := 0
+	err := router.ChatStream(context.Background(), testRequest("hi"), func(chunk *models.OpenAIStreamChunk) error {
+		chunks++
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected the error of the primary upstream")
+	}
+	if chunks != 1 || len(secondary.requests) != 0 {
+		t.Errorf("expected a single chunk and no fallback, got %d chunks and %d fallback requests", chunks, len(secondary.requests))
+	}
+}
+
+func TestStreamConsumerError(t *testing.T) {
+	primary := newFakeProvider("primary", "large")
+	router := newTestRouter(t, &Config{
+		Breaker:   BreakerConfig{FailureThreshold: 1},
+		Upstreams: []UpstreamConfig{{Backend: "primary"}},
+	}, primary)
+
+	consumerErr := errors.New("client disconnected")
+	err := router.ChatStream(context.Background(), testRequest("hi"), func(chunk *models.OpenAIStreamChunk) error {
+		return consumerErr
+	})
+	if err != consumerErr {
+		t.Fatalf("expected the consumer's error, got: %v", err)
+	}
+	if router.upstreams[0].breaker.currentState() != breakerClosed {
+		t.Errorf("expected consumer errors not to affect the circuit breaker")
+	}
+}
+
+func TestOrder(t *testing.T) {
+	expensive := newFakeProvider("expensive", "large")
+	cheap := newFakeProvider("cheap", "small")
+	upstreams := []UpstreamConfig{
+		{Backend: "expensive", Weight: 3, CostPerMillionTokens: 2.5},
+		{Backend: "cheap", Weight: 1, CostPerMillionTokens: 0.15},
+	}
+
+	testData := []struct {
+		policy   string
+		random   float64
+		prompt   string
+		expected string
+	}{
+		{PolicyFallback, 0, "hi", "expensive"},
+		{PolicyWeighted, 0.5, "hi", "expensive"},
+		{PolicyWeighted, 0.8, "hi", "cheap"},
+		{PolicyCost, 0, "hi", "cheap"},
+		{PolicyCost, 0, strings.Repeat("long prompt ", 200), "expensive"},
+	}
+
+	for _, data := range testData {
+		router := newTestRouter(t, &Config{Policy: data.policy, ShortPromptTokens: 100, Upstreams: upstreams}, expensive, cheap)
+		router.random = func() float64 { return data.random }
+
+		order, _ := router.order(testRequest(data.prompt))
+		if len(order) != 2 || order[0].Name != data.expected {
+			t.Errorf("policy %s: expected %s first, got %s", data.policy, data.expected, order[0].Name)
+		}
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	provider := newFakeProvider("anthropic", "claude")
+	upstream := &upstream{provider: provider}
+
+	temperature := 1.5
+	maxTokens := 4096
+	seed := int64(1)
+	adapted := upstream.adapt(&models.OpenAIChatRequest{Model: "gpt-4o", Temperature: &temperature, MaxTokens: &maxTokens, Seed: &seed})
+
+	if adapted.Model != "claude" {
+		t.Errorf("expected unsupported model to be replaced with the default one, got: %s", adapted.Model)
+	}
+	if *adapted.Temperature != 1 || *adapted.MaxTokens != 1024 || adapted.Seed != nil {
+		t.Errorf("expected parameters to be clamped, got temperature %v, max_tokens %v, seed %v",
+			*adapted.Temperature, *adapted.MaxTokens, adapted.Seed)
+	}
+	if temperature != 1.5 || maxTokens != 4096 {
+		t.Errorf("expected the original request to stay intact")
+	}
+}
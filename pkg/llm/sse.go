@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadEvents parses a stream of server-sent events from r and invokes onEvent for every event, in order.
+// Returns io.EOF if the stream ends without onEvent returning an error first,
+// providers use io.EOF from onEvent to report the regular end of the stream.
+func ReadEvents(r io.Reader, onEvent func(event string, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	// A single event might exceed the default 64KB token size.
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := onEvent(event, strings.Join(data, "\n"))
+		event, data = "", data[:0]
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Events are separated by blank lines, comments start with a colon.
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %v", err)
+	}
+
+	// The last event might not be followed by a blank line.
+	if err := dispatch(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
@@ -0,0 +1,10 @@
+// Package moderation classifies prompts and answers into policy categories,
+// either with the OpenAI moderation endpoint or with a local set of rules.
+package moderation
+
+import "context"
+
+type Moderator interface {
+	// Moderate returns the sorted names of the categories the text violates, none if it's fine.
+	Moderate(ctx context.Context, text string) ([]string, error)
+}
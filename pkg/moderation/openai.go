@@ -0,0 +1,53 @@
+package moderation
+
+import (
+	"context"
+	"sort"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+const DefaultOpenAIModel = "omni-moderation-latest"
+
+type moderationClient interface {
+	Moderate(ctx context.Context, request *models.ModerationRequest) (*models.ModerationResponse, error)
+}
+
+// OpenAI moderates the text with the OpenAI moderation endpoint,
+// its categories are named the way OpenAI names them, for example "violence" or "self-harm/intent".
+type OpenAI struct {
+	client moderationClient
+	model  string
+}
+
+func NewOpenAI(client moderationClient, model string) *OpenAI {
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAI{
+		client: client,
+		model:  model,
+	}
+}
+
+func (m *OpenAI) Moderate(ctx context.Context, text string) ([]string, error) {
+	response, err := m.client.Moderate(ctx, &models.ModerationRequest{
+		Model: m.model,
+		Input: []string{text},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []string
+	for _, result := range response.Results {
+		for category, flagged := range result.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+	}
+	sort.Strings(categories)
+
+	return categories, nil
+}
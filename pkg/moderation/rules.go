@@ -0,0 +1,94 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+type RuleConfig struct {
+	// Matched as whole words, ignoring the case.
+	Keywords []string `json:"keywords"`
+	// Go regular expressions, matched anywhere in the text.
+	Patterns []string `json:"patterns"`
+}
+
+// The rules file maps the names of the categories to their rules, for example
+//
+//	{"categories": {"violence": {"keywords": ["kill"], "patterns": ["(?i)how to (build|make) a bomb"]}}}
+type RulesConfig struct {
+	Categories map[string]RuleConfig `json:"categories"`
+}
+
+// Rules moderates the text locally, a category is violated if any of its rules matches.
+type Rules struct {
+	categories map[string][]*regexp.Regexp
+}
+
+func NewRules(config *RulesConfig) (*Rules, error) {
+	if len(config.Categories) == 0 {
+		return nil, fmt.Errorf("moderation: at least one category is required")
+	}
+
+	rules := &Rules{
+		categories: make(map[string][]*regexp.Regexp, len(config.Categories)),
+	}
+
+	for category, rule := range config.Categories {
+		if len(rule.Keywords) == 0 && len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("moderation: category %q has no rules", category)
+		}
+
+		var compiled []*regexp.Regexp
+		for _, keyword := range rule.Keywords {
+			if keyword == "" {
+				return nil, fmt.Errorf("moderation: category %q has an empty keyword", category)
+			}
+			compiled = append(compiled, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(keyword)+`\b`))
+		}
+		for _, pattern := range rule.Patterns {
+			expression, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("moderation: category %q has an invalid pattern, error: %v", category, err)
+			}
+			compiled = append(compiled, expression)
+		}
+
+		rules.categories[category] = compiled
+	}
+
+	return rules, nil
+}
+
+// LoadRules reads a json rules file.
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("moderation: failed to read rules, error: %v", err)
+	}
+
+	var config RulesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("moderation: failed to parse rules, error: %v", err)
+	}
+
+	return NewRules(&config)
+}
+
+func (r *Rules) Moderate(_ context.Context, text string) ([]string, error) {
+	var categories []string
+	for category, expressions := range r.categories {
+		for _, expression := range expressions {
+			if expression.MatchString(text) {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+	sort.Strings(categories)
+
+	return categories, nil
+}
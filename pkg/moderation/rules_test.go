@@ -0,0 +1,52 @@
+package moderation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := NewRules(&RulesConfig{
+		Categories: map[string]RuleConfig{
+			"violence": {Keywords: []string{"kill"}},
+			"weapons":  {Patterns: []string{`(?i)how to (build|make) a bomb`}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"What's the weather like?", nil},
+		// Keywords only match whole words.
+		{"Skills matter", nil},
+		{"I will KILL the process", []string{"violence"}},
+		{"How to make a bomb and kill", []string{"violence", "weapons"}},
+	}
+
+	for _, test := range tests {
+		categories, err := rules.Moderate(context.Background(), test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(categories, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.text, test.expected, categories)
+		}
+	}
+}
+
+func TestNewRulesInvalid(t *testing.T) {
+	for _, config := range []*RulesConfig{
+		{},
+		{Categories: map[string]RuleConfig{"empty": {}}},
+		{Categories: map[string]RuleConfig{"invalid": {Patterns: []string{"("}}}},
+	} {
+		if _, err := NewRules(config); err == nil {
+			t.Errorf("expected %+v to be rejected", config)
+		}
+	}
+}
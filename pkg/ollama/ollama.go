@@ -0,0 +1,90 @@
+package ollama
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/isnastish/openai/pkg/llm"
+	"github.com/isnastish/openai/pkg/openai"
+)
+
+const (
+	defaultBaseURL        = "http://localhost:11434/v1"
+	defaultModel          = "llama3.1"
+	defaultEmbeddingModel = "nomic-embed-text"
+	defaultContextWindow  = 8192
+)
+
+// NewClient creates a client for a local Ollama server.
+// Ollama, llama.cpp server and most of the other local runtimes expose
+// an OpenAI compatible api, so any of them can be used by pointing OLLAMA_BASE_URL at it.
+//
+// Since the models depend on what is pulled locally, the allow-list is configured with
+// OLLAMA_MODELS, a comma separated list where the first model is the default one.
+func NewClient() (*openai.Client, error) {
+	baseURL, set := os.LookupEnv("OLLAMA_BASE_URL")
+	if !set || baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	contextWindow := defaultContextWindow
+	if value, set := os.LookupEnv("OLLAMA_CONTEXT_WINDOW"); set && value != "" {
+		var err error
+		contextWindow, err = strconv.Atoi(value)
+		if err != nil || contextWindow <= 0 {
+			return nil, fmt.Errorf("ollama: OLLAMA_CONTEXT_WINDOW must be a positive integer")
+		}
+	}
+
+	names := []string{defaultModel}
+	if value, set := os.LookupEnv("OLLAMA_MODELS"); set && value != "" {
+		names = names[:0]
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("ollama: OLLAMA_MODELS doesn't contain any models")
+		}
+	}
+
+	// Tool calling depends on the model, llama3.1 supports it, many others don't.
+	supportsTools := false
+	if value, set := os.LookupEnv("OLLAMA_SUPPORTS_TOOLS"); set && value != "" {
+		var err error
+		supportsTools, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("ollama: OLLAMA_SUPPORTS_TOOLS must be a boolean")
+		}
+	}
+
+	embeddingModel, set := os.LookupEnv("OLLAMA_EMBEDDING_MODEL")
+	if !set {
+		embeddingModel = defaultEmbeddingModel
+	}
+
+	catalog := &llm.Catalog{
+		DefaultModel:          names[0],
+		DefaultEmbeddingModel: embeddingModel,
+		Models:                make(map[string]llm.ModelLimits, len(names)),
+		MaxTemperature:        2,
+		SupportsSeed:          true,
+		SupportsTools:         supportsTools,
+	}
+	for _, name := range names {
+		catalog.Models[name] = llm.ModelLimits{ContextWindow: contextWindow, MaxOutputTokens: contextWindow}
+	}
+
+	retryPolicy, err := llm.RetryPolicyFromEnv("OLLAMA_MAX_RETRIES")
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %v", err)
+	}
+
+	// Ollama ignores the api key, but servers behind a proxy might require one.
+	apiKey := os.Getenv("OLLAMA_API_KEY")
+
+	return openai.NewCompatibleClient("ollama", baseURL, apiKey, catalog, retryPolicy), nil
+}
@@ -0,0 +1,56 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+// The error envelope returned by OpenAI api together with a non-2xx status code.
+// See https://platform.openai.com/docs/guides/error-codes
+type errorEnvelope struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Param   string      `json:"param"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// parseAPIError converts a non-2xx response into one of the typed errors.
+// The body doesn't have to be a valid error envelope, proxies in front of OpenAI
+// might respond with plain text or html.
+func (c *Client) parseAPIError(resp *http.Response, body []byte) error {
+	apiError := &llm.APIError{
+		Provider:   c.name,
+		StatusCode: resp.StatusCode,
+		RetryAfter: llm.ParseRetryAfter(resp.Header, time.Now()),
+	}
+
+	var envelope errorEnvelope
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Message != "" {
+		apiError.Type = envelope.Error.Type
+		apiError.Param = envelope.Error.Param
+		apiError.Message = envelope.Error.Message
+		// The code is usually a string, but it's documented as nullable
+		// and some compatible servers send numbers.
+		if envelope.Error.Code != nil {
+			apiError.Code = fmt.Sprint(envelope.Error.Code)
+		}
+	} else {
+		apiError.Message = strings.TrimSpace(string(body))
+		if apiError.Message == "" {
+			apiError.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+
+	if apiError.Code == "context_length_exceeded" {
+		return &llm.ContextLengthExceededError{APIError: apiError}
+	}
+
+	return llm.ClassifyError(apiError)
+}
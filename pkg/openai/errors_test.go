@@ -0,0 +1,46 @@
+package openai
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+func TestParseAPIError(t *testing.T) {
+	testData := []struct {
+		status int
+		body   string
+		check  func(err error) bool
+	}{
+		{429, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`,
+			func(err error) bool { var e *llm.RateLimitError; return errors.As(err, &e) && !e.QuotaExceeded() }},
+		{429, `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","code":"insufficient_quota"}}`,
+			func(err error) bool { var e *llm.RateLimitError; return errors.As(err, &e) && e.QuotaExceeded() }},
+		{400, `{"error":{"message":"maximum context length is 128000 tokens","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`,
+			func(err error) bool {
+				var e *llm.ContextLengthExceededError
+				return errors.As(err, &e) && e.Param == "messages"
+			}},
+		{400, `{"error":{"message":"Invalid value for 'temperature'","type":"invalid_request_error","param":"temperature","code":null}}`,
+			func(err error) bool { var e *llm.InvalidRequestError; return errors.As(err, &e) && e.Code == "" }},
+		{401, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			func(err error) bool { var e *llm.AuthenticationError; return errors.As(err, &e) }},
+		{503, `<html>Service Unavailable</html>`,
+			func(err error) bool {
+				var e *llm.ServerError
+				return errors.As(err, &e) && e.Message == "<html>Service Unavailable</html>"
+			}},
+		{418, ``,
+			func(err error) bool { var e *llm.APIError; return errors.As(err, &e) && e.Message == "I'm a teapot" }},
+	}
+
+	for _, data := range testData {
+		resp := &http.Response{StatusCode: data.status, Header: http.Header{}}
+		err := newTestClient("").parseAPIError(resp, []byte(data.body))
+		if !data.check(err) {
+			t.Fatalf("unexpected error for status %d: %T %v", data.status, err, err)
+		}
+	}
+}
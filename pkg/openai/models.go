@@ -0,0 +1,33 @@
+package openai
+
+import "github.com/isnastish/openai/pkg/llm"
+
+// The allow-list of OpenAI models that can be requested by the clients.
+var Catalog = llm.Catalog{
+	DefaultModel:          "gpt-4o-mini-2024-07-18",
+	DefaultEmbeddingModel: "text-embedding-3-small",
+	Models: map[string]llm.ModelLimits{
+		"gpt-4o-mini-2024-07-18": {ContextWindow: 128000, MaxOutputTokens: 16384},
+		"gpt-4o-mini":            {ContextWindow: 128000, MaxOutputTokens: 16384},
+		"gpt-4o-2024-08-06":      {ContextWindow: 128000, MaxOutputTokens: 16384},
+		"gpt-4o":                 {ContextWindow: 128000, MaxOutputTokens: 16384},
+		"gpt-4.1":                {ContextWindow: 1047576, MaxOutputTokens: 32768},
+		"gpt-4.1-mini":           {ContextWindow: 1047576, MaxOutputTokens: 32768},
+		"gpt-4.1-nano":           {ContextWindow: 1047576, MaxOutputTokens: 32768},
+		"gpt-3.5-turbo":          {ContextWindow: 16385, MaxOutputTokens: 4096},
+	},
+	Pricing: map[string]llm.ModelPricing{
+		"gpt-4o-mini-2024-07-18": {InputPerMillion: 0.15, OutputPerMillion: 0.6},
+		"gpt-4o-mini":            {InputPerMillion: 0.15, OutputPerMillion: 0.6},
+		"gpt-4o-2024-08-06":      {InputPerMillion: 2.5, OutputPerMillion: 10},
+		"gpt-4o":                 {InputPerMillion: 2.5, OutputPerMillion: 10},
+		"gpt-4.1":                {InputPerMillion: 2, OutputPerMillion: 8},
+		"gpt-4.1-mini":           {InputPerMillion: 0.4, OutputPerMillion: 1.6},
+		"gpt-4.1-nano":           {InputPerMillion: 0.1, OutputPerMillion: 0.4},
+		"gpt-3.5-turbo":          {InputPerMillion: 0.5, OutputPerMillion: 1.5},
+		"text-embedding-3-small": {InputPerMillion: 0.02},
+	},
+	MaxTemperature: 2,
+	SupportsSeed:   true,
+	SupportsTools:  true,
+}
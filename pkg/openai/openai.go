@@ -4,17 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
 )
 
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// Returned from the event handler once the terminating `[DONE]` event is received.
+var errStreamDone = errors.New("stream done")
+
+// Client talks to OpenAI api, or to any server which implements
+// OpenAI compatible chat completions and embeddings endpoints.
 type Client struct {
+	// the name reported in logs and errors
+	name         string
 	openAIApiKey string
-	httpClient   *http.Client
+	// base url of the OpenAI API, can be overwritten with OPENAI_BASE_URL
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy llm.RetryPolicy
+	catalog     *llm.Catalog
 }
 
 func NewClient() (*Client, error) {
@@ -23,68 +39,206 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("openai: OPENAI_API_KEY is not set")
 	}
 
+	baseURL, set := os.LookupEnv("OPENAI_BASE_URL")
+	if !set || baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	retryPolicy, err := llm.RetryPolicyFromEnv("OPENAI_MAX_RETRIES")
+	if err != nil {
+		return nil, fmt.Errorf("openai: %v", err)
+	}
+
+	return NewCompatibleClient("openai", baseURL, openAIApiKey, &Catalog, retryPolicy), nil
+}
+
+// NewCompatibleClient creates a client for a server which implements OpenAI's api,
+// like Ollama or llama.cpp. The api key is optional for local servers.
+func NewCompatibleClient(name string, baseURL string, apiKey string, catalog *llm.Catalog, retryPolicy llm.RetryPolicy) *Client {
 	return &Client{
-		openAIApiKey: openAIApiKey,
+		name:         name,
+		openAIApiKey: apiKey,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
 		httpClient:   &http.Client{},
-	}, nil
+		retryPolicy:  retryPolicy,
+		catalog:      catalog,
+	}
 }
 
-// TODO: This should be rewritten in a more understandable way
-// And the function should be renamed.
-// NOTE: This context will need more time, because requests to openai model
-// might require some processing, data accumulation, etc.
-func (c *Client) AskOpenAI(ctx context.Context, message string) (*models.OpenAIResp, error) {
-	messages := []map[string]string{
-		{
-			"role":    "system",
-			"content": "You are a helpful assistant.",
-		},
-		{
-			"role":    "user",
-			"content": message,
-		},
-	}
+func (c *Client) Name() string {
+	return c.name
+}
 
-	reqData := map[string]interface{}{
-		"model":    "gpt-4o-mini-2024-07-18",
-		"messages": messages,
-	}
+func (c *Client) Catalog() *llm.Catalog {
+	return c.catalog
+}
 
-	body, err := json.Marshal(reqData)
+// newRequest returns a function which builds a new http request
+// with the same body every time it's called, see llm.Do.
+func (c *Client) newRequest(ctx context.Context, path string, payload interface{}, stream bool) (func() (*http.Request, error), error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to marshal request body: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(body))
+	return func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create a request: %v", err)
+		}
+
+		req.Header.Add("Content-Type", "application/json")
+		if c.openAIApiKey != "" {
+			req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.openAIApiKey))
+		}
+		if stream {
+			req.Header.Add("Accept", "text/event-stream")
+		}
+
+		return req, nil
+	}, nil
+}
+
+// post sends the payload to the given endpoint and unmarshals the response into result.
+// Transient failures are retried, the rest is reported as one of the typed errors.
+func (c *Client) post(ctx context.Context, path string, payload interface{}, result interface{}) error {
+	newRequest, err := c.newRequest(ctx, path, payload, false)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create a request: %v", err)
+		return err
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.openAIApiKey))
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := llm.Do(ctx, c.httpClient, c.retryPolicy, newRequest, c.parseAPIError)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to make request: %v", err)
+		return err
 	}
 
 	defer resp.Body.Close()
 
-	// TODO: Read API documentation for possible error codes
-	// if resp.StatusCode != http.StatusOK {
-	// 	// log.Fatalf("Response status code: %d, message: %s", resp.StatusCode, resp.Status)
-	// }
-
 	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read the response body: %v", err)
+		return fmt.Errorf("Failed to read the response body: %v", err)
 	}
 
+	if err := json.Unmarshal(respBytes, result); err != nil {
+		return fmt.Errorf("Failed to unmarshal the response body: %v", err)
+	}
+
+	return nil
+}
+
+// Chat requests a chat completion, the request is normally built with llm.Catalog.NewChatRequest.
+// NOTE: This context will need more time, because requests to openai model
+// might require some processing, data accumulation, etc.
+func (c *Client) Chat(ctx context.Context, request *models.OpenAIChatRequest) (*models.OpenAIResp, error) {
 	var openAIResp models.OpenAIResp
-	err = json.Unmarshal(respBytes, &openAIResp)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to unmarshal the response body: %v", err)
+	if err := c.post(ctx, "/chat/completions", request, &openAIResp); err != nil {
+		return nil, err
 	}
 
 	return &openAIResp, nil
 }
+
+// ChatStream requests a chat completion with `stream: true` and invokes onChunk
+// for every chunk received from the server, in order.
+// The stream is consumed until OpenAI sends the terminating `[DONE]` event,
+// the context is cancelled, or onChunk returns an error.
+// Cancelling the context closes the upstream connection.
+func (c *Client) ChatStream(ctx context.Context, request *models.OpenAIChatRequest, onChunk func(chunk *models.OpenAIStreamChunk) error) error {
+	streamRequest := *request
+	streamRequest.Stream = true
+	streamRequest.StreamOptions = &models.OpenAIStreamOptions{IncludeUsage: true}
+
+	newRequest, err := c.newRequest(ctx, "/chat/completions", &streamRequest, true)
+	if err != nil {
+		return err
+	}
+
+	// Only opening the stream is retried, once the first chunk has been delivered
+	// to onChunk, the request cannot be repeated.
+	resp, err := llm.Do(ctx, c.httpClient, c.retryPolicy, newRequest, c.parseAPIError)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return c.readStream(resp.Body, onChunk)
+}
+
+// readStream parses server-sent events from r.
+// Every event carries a json encoded chunk in its data field,
+// the last event's data is `[DONE]`.
+func (c *Client) readStream(r io.Reader, onChunk func(chunk *models.OpenAIStreamChunk) error) error {
+	err := llm.ReadEvents(r, func(_ string, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+
+		// Errors which occur after the stream has started are delivered as an event.
+		if strings.HasPrefix(data, `{"error"`) {
+			var envelope errorEnvelope
+			if err := json.Unmarshal([]byte(data), &envelope); err == nil && envelope.Error.Message != "" {
+				return &llm.ServerError{APIError: &llm.APIError{
+					Provider:   c.name,
+					StatusCode: http.StatusInternalServerError,
+					Type:       envelope.Error.Type,
+					Message:    envelope.Error.Message,
+				}}
+			}
+		}
+
+		var chunk models.OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("%s: failed to unmarshal stream chunk: %v", c.name, err)
+		}
+
+		return onChunk(&chunk)
+	})
+
+	switch {
+	case errors.Is(err, errStreamDone):
+		return nil
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("%s: stream ended unexpectedly", c.name)
+	default:
+		return err
+	}
+}
+
+// Embed returns the embeddings of the input strings, in the same order.
+func (c *Client) Embed(ctx context.Context, request *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	if c.catalog.DefaultEmbeddingModel == "" {
+		return nil, fmt.Errorf("%s: embeddings are %w", c.name, llm.ErrNotSupported)
+	}
+
+	embeddingRequest := *request
+	if embeddingRequest.Model == "" {
+		embeddingRequest.Model = c.catalog.DefaultEmbeddingModel
+	}
+
+	var embeddingResp models.EmbeddingResponse
+	if err := c.post(ctx, "/embeddings", &embeddingRequest, &embeddingResp); err != nil {
+		return nil, err
+	}
+
+	if len(embeddingResp.Data) != len(request.Input) {
+		return nil, fmt.Errorf("%s: expected %d embeddings, got %d", c.name, len(request.Input), len(embeddingResp.Data))
+	}
+
+	return &embeddingResp, nil
+}
+
+// Moderate classifies the input against OpenAI usage policies, one result per input.
+// Moderation is free of charge and only available on OpenAI itself.
+func (c *Client) Moderate(ctx context.Context, request *models.ModerationRequest) (*models.ModerationResponse, error) {
+	var moderationResp models.ModerationResponse
+	if err := c.post(ctx, "/moderations", request, &moderationResp); err != nil {
+		return nil, err
+	}
+
+	if len(moderationResp.Results) != len(request.Input) {
+		return nil, fmt.Errorf("%s: expected %d moderation results, got %d", c.name, len(request.Input), len(moderationResp.Results))
+	}
+
+	return &moderationResp, nil
+}
@@ -1 +1,114 @@
 package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+func newTestClient(baseURL string) *Client {
+	return NewCompatibleClient("openai", baseURL, "test-key", &Catalog, llm.RetryPolicy{})
+}
+
+var testRequest = Catalog.NewChatRequest([]models.OpenAIMessage{{Role: "user", Content: "hi"}}, &models.OpenAIParams{})
+
+func TestChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, "data: {\"model\":\"gpt\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var sb strings.Builder
+	err := newTestClient(server.URL).ChatStream(context.Background(), testRequest, func(chunk *models.OpenAIStreamChunk) error {
+		sb.WriteString(chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "Hello!" {
+		t.Errorf("expected: Hello!, got: %s", sb.String())
+	}
+}
+
+func TestChatStreamHandlerErrorAbortsStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"}}]}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	calls := 0
+	err := newTestClient(server.URL).ChatStream(context.Background(), testRequest, func(chunk *models.OpenAIStreamChunk) error {
+		calls++
+		return fmt.Errorf("client disconnected")
+	})
+	if err == nil || err.Error() != "client disconnected" {
+		t.Errorf("expected handler error, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got: %d", calls)
+	}
+}
+
+func TestChatStreamUnexpectedEOF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"}}]}\n\n")
+	}))
+	defer server.Close()
+
+	err := newTestClient(server.URL).ChatStream(context.Background(), testRequest, func(chunk *models.OpenAIStreamChunk) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "stream ended unexpectedly") {
+		t.Errorf("expected unexpected end of stream error, got: %v", err)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"model":"text-embedding-3-small","data":[{"index":0,"embedding":[0.1,0.2]},{"index":1,"embedding":[0.3,0.4]}]}`)
+	}))
+	defer server.Close()
+
+	resp, err := newTestClient(server.URL).Embed(context.Background(), &models.EmbeddingRequest{Input: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || resp.Data[1].Embedding[1] != 0.4 {
+		t.Errorf("unexpected embeddings: %v", resp.Data)
+	}
+}
+
+func TestModerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moderations" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"id":"modr-1","model":"omni-moderation-latest","results":[{"flagged":true,"categories":{"violence":true,"hate":false},"category_scores":{"violence":0.9,"hate":0.01}}]}`)
+	}))
+	defer server.Close()
+
+	resp, err := newTestClient(server.URL).Moderate(context.Background(), &models.ModerationRequest{Input: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Results[0].Flagged || !resp.Results[0].Categories["violence"] {
+		t.Errorf("unexpected moderation results: %v", resp.Results)
+	}
+}
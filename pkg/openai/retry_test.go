@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/isnastish/openai/pkg/llm"
+)
+
+func TestChatRetriesTransientFailures(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			w.Header().Set("Retry-After-Ms", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"Rate limit reached","code":"rate_limit_exceeded"}}`)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, `{"model":"gpt","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	client.retryPolicy = llm.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	resp, err := client.Chat(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || resp.Choices[0].Message.Content != "ok" {
+		t.Errorf("expected a successful third attempt, got %d attempts", attempts)
+	}
+}
+
+func TestChatDoesNotRetryPermanentFailures(t *testing.T) {
+	testData := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusTooManyRequests, `{"error":{"message":"quota","code":"insufficient_quota"}}`},
+		{http.StatusBadRequest, `{"error":{"message":"bad","code":"context_length_exceeded"}}`},
+		{http.StatusUnauthorized, `{"error":{"message":"key","code":"invalid_api_key"}}`},
+	}
+
+	for _, data := range testData {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(data.status)
+			fmt.Fprint(w, data.body)
+		}))
+
+		client := newTestClient(server.URL)
+		client.retryPolicy = llm.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+		_, err := client.Chat(context.Background(), testRequest)
+		server.Close()
+
+		var apiError *llm.APIError
+		if !errors.As(err, &apiError) || apiError.StatusCode != data.status {
+			t.Fatalf("expected an api error with status %d, got: %v", data.status, err)
+		}
+		if attempts != 1 {
+			t.Fatalf("status %d shouldn't be retried, got %d attempts", data.status, attempts)
+		}
+	}
+}
+
+func TestRetryAfterLongerThanMaxDelayIsNotRetried(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	client.retryPolicy = llm.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	_, err := client.Chat(context.Background(), testRequest)
+
+	var serverError *llm.ServerError
+	if !errors.As(err, &serverError) || serverError.RetryAfter != 2*time.Minute {
+		t.Fatalf("expected a server error, got: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected a single attempt, got: %d", attempts)
+	}
+}
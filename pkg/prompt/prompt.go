@@ -0,0 +1,209 @@
+// Package prompt parses and renders prompt templates written in the syntax of Go's text/template.
+// Templates are written by the users, so the features which would let a template run away
+// with the server's time or memory are rejected when it's parsed.
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const (
+	MaxTemplateLength = 32 * 1024
+	MaxRenderedLength = 128 * 1024
+)
+
+var errTooLong = errors.New("rendered prompt is too long")
+
+type Template struct {
+	template  *template.Template
+	variables []string
+}
+
+// Parse parses the template and collects the variables it refers to, {{.name}} or {{$.name}}.
+func Parse(content string) (*Template, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("template is empty")
+	}
+	if len(content) > MaxTemplateLength {
+		return nil, fmt.Errorf("template is longer than %d bytes", MaxTemplateLength)
+	}
+
+	parsed, err := template.New("prompt").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	// Defined templates would have to be walked with whatever they are invoked with.
+	if len(parsed.Templates()) > 1 {
+		return nil, fmt.Errorf("nested templates are not supported")
+	}
+
+	w := &walker{seen: make(map[string]bool)}
+	if err := w.walk(parsed.Tree.Root, true, false); err != nil {
+		return nil, err
+	}
+
+	return &Template{template: parsed, variables: w.variables}, nil
+}
+
+// Variables returns the names of the variables the template refers to, in the order of their first use.
+func (t *Template) Variables() []string {
+	return t.variables
+}
+
+// Render executes the template with the variables, every variable the template refers to has to be supplied,
+// and no other.
+func (t *Template) Render(variables map[string]any) (string, error) {
+	var missing, unknown []string
+	for _, name := range t.variables {
+		if _, ok := variables[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	for name := range variables {
+		if !slices.Contains(t.variables, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing variables: %s", strings.Join(missing, ", "))
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return "", fmt.Errorf("unknown variables: %s", strings.Join(unknown, ", "))
+	}
+
+	if variables == nil {
+		variables = map[string]any{}
+	}
+
+	var b limitedBuilder
+	if err := t.template.Execute(&b, variables); err != nil {
+		if errors.Is(err, errTooLong) {
+			return "", fmt.Errorf("%v, at most %d bytes are allowed", errTooLong, MaxRenderedLength)
+		}
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+type limitedBuilder struct {
+	strings.Builder
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > MaxRenderedLength {
+		return 0, errTooLong
+	}
+	return b.Builder.Write(p)
+}
+
+type walker struct {
+	variables []string
+	seen      map[string]bool
+}
+
+func (w *walker) add(name string) {
+	if !w.seen[name] {
+		w.seen[name] = true
+		w.variables = append(w.variables, name)
+	}
+}
+
+// walk collects the variables referred to in the node. Dot refers to the variables only at the root,
+// range and with rebind it. Ranges are allowed only over variables and can't be nested,
+// so the number of iterations is bounded by the size of the variables.
+func (w *walker) walk(node parse.Node, dotIsRoot bool, inRange bool) error {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, n := range node.Nodes {
+			if err := w.walk(n, dotIsRoot, inRange); err != nil {
+				return err
+			}
+		}
+
+	case *parse.ActionNode:
+		return w.walk(node.Pipe, dotIsRoot, inRange)
+
+	case *parse.PipeNode:
+		if node == nil {
+			return nil
+		}
+		for _, command := range node.Cmds {
+			for _, arg := range command.Args {
+				if err := w.walk(arg, dotIsRoot, inRange); err != nil {
+					return err
+				}
+			}
+		}
+
+	case *parse.FieldNode:
+		if dotIsRoot {
+			w.add(node.Ident[0])
+		}
+
+	case *parse.VariableNode:
+		if node.Ident[0] == "$" && len(node.Ident) > 1 {
+			w.add(node.Ident[1])
+		}
+
+	case *parse.ChainNode:
+		return w.walk(node.Node, dotIsRoot, inRange)
+
+	case *parse.IfNode:
+		return w.walkBranch(&node.BranchNode, dotIsRoot, dotIsRoot, inRange)
+
+	case *parse.WithNode:
+		return w.walkBranch(&node.BranchNode, false, dotIsRoot, inRange)
+
+	case *parse.RangeNode:
+		if inRange {
+			return fmt.Errorf("nested ranges are not supported")
+		}
+		if !rangesOverVariable(node.Pipe) {
+			return fmt.Errorf("range is supported only over a variable")
+		}
+		if err := w.walk(node.Pipe, dotIsRoot, inRange); err != nil {
+			return err
+		}
+		if err := w.walk(node.List, false, true); err != nil {
+			return err
+		}
+		return w.walk(node.ElseList, dotIsRoot, inRange)
+
+	case *parse.TemplateNode:
+		return fmt.Errorf("nested templates are not supported")
+	}
+
+	return nil
+}
+
+func (w *walker) walkBranch(node *parse.BranchNode, listDotIsRoot bool, dotIsRoot bool, inRange bool) error {
+	if err := w.walk(node.Pipe, dotIsRoot, inRange); err != nil {
+		return err
+	}
+	if err := w.walk(node.List, listDotIsRoot, inRange); err != nil {
+		return err
+	}
+	return w.walk(node.ElseList, dotIsRoot, inRange)
+}
+
+// rangesOverVariable reports whether the pipeline of a range is a single field, variable or a chain of them.
+func rangesOverVariable(pipe *parse.PipeNode) bool {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode, *parse.VariableNode, *parse.ChainNode:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package prompt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	parsed, err := Parse(`Summarize {{.document}} for {{.audience}}.
+{{if .bullets}}Use bullet points.{{end}}
+{{range .topics}}Cover {{.name}} with {{$.detail}}.{{end}}
+{{with .tone}}Keep a {{.}} tone, unlike {{$.audience}}.{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"document", "audience", "bullets", "topics", "detail", "tone"}
+	if variables := parsed.Variables(); !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected %v, got %v", expected, variables)
+	}
+}
+
+func TestRender(t *testing.T) {
+	parsed, err := Parse(`Translate "{{.text}}" into {{.language}}.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := parsed.Render(map[string]any{"text": "hello", "language": "French"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered != `Translate "hello" into French.` {
+		t.Errorf("unexpected prompt %q", rendered)
+	}
+
+	if _, err := parsed.Render(map[string]any{"text": "hello"}); err == nil || !strings.Contains(err.Error(), "language") {
+		t.Errorf("expected the missing variable to be reported, got %v", err)
+	}
+	if _, err := parsed.Render(map[string]any{"text": "hello", "language": "French", "tone": "formal"}); err == nil {
+		t.Errorf("expected an unknown variable to be rejected")
+	}
+
+	repeated, err := Parse(`{{range .items}}{{.}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]any, MaxRenderedLength/1000+1)
+	for i := range items {
+		items[i] = strings.Repeat("x", 1000)
+	}
+	if _, err := repeated.Render(map[string]any{"items": items}); err == nil {
+		t.Errorf("expected a prompt longer than the limit to be rejected")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, content := range []string{
+		``,
+		`{{.unclosed`,
+		`{{range 1000000000}}x{{end}}`,
+		`{{range .a}}{{range .b}}x{{end}}{{end}}`,
+		`{{define "inner"}}x{{end}}{{template "inner" .}}`,
+	} {
+		if _, err := Parse(content); err == nil {
+			t.Errorf("expected %q to be rejected", content)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package redact
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// regexpDetector finds the values matching the pattern which pass the validation, if any.
+type regexpDetector struct {
+	label    string
+	pattern  *regexp.Regexp
+	validate func(value string) bool
+}
+
+// NewRegexpDetector creates a detector of the values matching the pattern,
+// validate rules out the false positives and may be nil.
+func NewRegexpDetector(label string, pattern *regexp.Regexp, validate func(value string) bool) Detector {
+	return &regexpDetector{
+		label:    label,
+		pattern:  pattern,
+		validate: validate,
+	}
+}
+
+func (d *regexpDetector) Label() string {
+	return d.label
+}
+
+func (d *regexpDetector) Find(text string) [][2]int {
+	var spans [][2]int
+	for _, span := range d.pattern.FindAllStringIndex(text, -1) {
+		if d.validate == nil || d.validate(text[span[0]:span[1]]) {
+			spans = append(spans, [2]int{span[0], span[1]})
+		}
+	}
+	return spans
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// Groups of four, the way IBANs are usually written, or no spaces at all.
+	ibanPattern = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// An optional country code and area code, followed by digits separated with spaces, dots or dashes.
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]?\d{2,4}){1,5}`)
+	datePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+)
+
+func digits(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// Email detects email addresses.
+func Email() Detector {
+	return NewRegexpDetector("EMAIL", emailPattern, nil)
+}
+
+// Phone detects phone numbers of 9 to 15 digits, the shorter ones are too easily confused with other numbers.
+func Phone() Detector {
+	return NewRegexpDetector("PHONE", phonePattern, func(value string) bool {
+		// Dates followed by the time look like phone numbers too.
+		if datePattern.MatchString(value) {
+			return false
+		}
+		count := len(digits(value))
+		return count >= 9 && count <= 15
+	})
+}
+
+// Card detects payment card numbers, they are validated with the Luhn checksum.
+func Card() Detector {
+	return NewRegexpDetector("CARD", cardPattern, func(value string) bool {
+		return luhn(digits(value))
+	})
+}
+
+// IBAN detects international bank account numbers, they are validated with their mod 97 checksum.
+func IBAN() Detector {
+	return NewRegexpDetector("IBAN", ibanPattern, func(value string) bool {
+		return validIBAN(strings.ReplaceAll(value, " ", ""))
+	})
+}
+
+// Detectors maps the names the detectors are configured with to the detectors.
+var Detectors = map[string]func() Detector{
+	"email": Email,
+	"phone": Phone,
+	"card":  Card,
+	"iban":  IBAN,
+}
+
+// DefaultDetectors are the names of the built-in detectors in the order they are run,
+// IBANs and card numbers are looked for before the phone numbers they resemble.
+var DefaultDetectors = []string{"email", "iban", "card", "phone"}
+
+func luhn(number string) bool {
+	if len(number) < 13 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func validIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	// The country code and the check digits are moved to the end, and the letters are replaced with numbers, A = 10.
+	var numeric strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			numeric.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			numeric.WriteString(strconv.Itoa(int(c-'A') + 10))
+		default:
+			return false
+		}
+	}
+
+	value, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(value, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,194 @@
+// Package redact replaces personal data in the prompts with placeholders such as <EMAIL_1>
+// before they are sent to the llm provider, and puts the original values back into the answers.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Detector finds one kind of personal data in a text.
+type Detector interface {
+	// The kind of data, used in the placeholders, for example EMAIL. Only uppercase letters are allowed.
+	Label() string
+	// Returns the [start, end) byte offsets of the values found in the text.
+	Find(text string) [][2]int
+}
+
+var (
+	labelPattern       = regexp.MustCompile(`^[A-Z]+$`)
+	placeholderPattern = regexp.MustCompile(`<([A-Z]+)_(\d+)>`)
+)
+
+// The longest placeholder a stream may leave unfinished, <IBAN_99999> and alike.
+const maxPlaceholderLength = 32
+
+// Redactor holds the detectors, which are run in order.
+// When the values found by two detectors overlap, the one found by the earlier detector wins.
+type Redactor struct {
+	detectors []Detector
+}
+
+func New(detectors ...Detector) (*Redactor, error) {
+	if len(detectors) == 0 {
+		return nil, fmt.Errorf("redact: at least one detector is required")
+	}
+	for _, detector := range detectors {
+		if !labelPattern.MatchString(detector.Label()) {
+			return nil, fmt.Errorf("redact: label %q must consist of uppercase letters", detector.Label())
+		}
+	}
+	return &Redactor{detectors: detectors}, nil
+}
+
+// NewSession starts a new mapping between the values and the placeholders.
+// The values are numbered in the order they are first seen within the session.
+func (r *Redactor) NewSession() *Session {
+	return &Session{
+		redactor:     r,
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// Session redacts the texts of a single conversation turn, so the same value is always replaced
+// with the same placeholder, and the placeholders in the answer can be restored.
+// A nil session leaves the texts as they are. It's not safe for concurrent use.
+type Session struct {
+	redactor *Redactor
+	// label + value -> placeholder
+	placeholders map[string]string
+	// placeholder -> value
+	values map[string]string
+	counts map[string]int
+}
+
+type match struct {
+	start, end int
+	label      string
+}
+
+// Redact replaces the values found by the detectors with their placeholders.
+func (s *Session) Redact(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+
+	var matches []match
+	for _, detector := range s.redactor.detectors {
+		for _, span := range detector.Find(text) {
+			candidate := match{start: span[0], end: span[1], label: detector.Label()}
+			overlaps := false
+			for _, found := range matches {
+				if candidate.start < found.end && found.start < candidate.end {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				matches = append(matches, candidate)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return text
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	redacted := make([]byte, 0, len(text))
+	last := 0
+	for _, found := range matches {
+		redacted = append(redacted, text[last:found.start]...)
+		redacted = append(redacted, s.placeholder(found.label, text[found.start:found.end])...)
+		last = found.end
+	}
+	redacted = append(redacted, text[last:]...)
+
+	return string(redacted)
+}
+
+func (s *Session) placeholder(label string, value string) string {
+	key := label + "\x00" + value
+	if placeholder, ok := s.placeholders[key]; ok {
+		return placeholder
+	}
+
+	s.counts[label]++
+	placeholder := "<" + label + "_" + strconv.Itoa(s.counts[label]) + ">"
+	s.placeholders[key] = placeholder
+	s.values[placeholder] = value
+
+	return placeholder
+}
+
+// Restore puts the original values in place of the placeholders of this session,
+// the placeholders the session doesn't know are left as they are.
+func (s *Session) Restore(text string) string {
+	if s == nil || len(s.values) == 0 {
+		return text
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, ok := s.values[placeholder]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// StreamRestorer restores the placeholders in an answer which arrives in pieces,
+// a placeholder split between two pieces is held back until it's complete.
+type StreamRestorer struct {
+	session *Session
+	pending string
+}
+
+func (s *Session) NewStreamRestorer() *StreamRestorer {
+	return &StreamRestorer{session: s}
+}
+
+// Next returns the restored text which is safe to pass on, it may be empty.
+func (r *StreamRestorer) Next(delta string) string {
+	if r.session == nil {
+		return delta
+	}
+
+	text := r.pending + delta
+	r.pending = ""
+
+	if start := lastOpenPlaceholder(text); start >= 0 {
+		r.pending = text[start:]
+		text = text[:start]
+	}
+
+	return r.session.Restore(text)
+}
+
+// Flush returns what's been held back, once the answer is complete.
+func (r *StreamRestorer) Flush() string {
+	text := r.pending
+	r.pending = ""
+	return r.session.Restore(text)
+}
+
+// lastOpenPlaceholder returns the offset of the trailing text which may turn into a placeholder
+// once the rest of it arrives, or -1.
+func lastOpenPlaceholder(text string) int {
+	start := len(text) - 1
+	for ; start >= 0 && len(text)-start <= maxPlaceholderLength; start-- {
+		switch c := text[start]; {
+		case c == '<':
+			return start
+		case c == '_' || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+		default:
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,83 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T) *Redactor {
+	var detectors []Detector
+	for _, name := range DefaultDetectors {
+		detectors = append(detectors, Detectors[name]())
+	}
+	redactor, err := New(detectors...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redactor
+}
+
+func TestRedact(t *testing.T) {
+	redactor := newTestRedactor(t)
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"Write to ada@example.com or bob.smith+work@mail.example.org",
+			"Write to <EMAIL_1> or <EMAIL_2>"},
+		{"Call me at +44 20 7946 0958, or (555) 123-4567 after 5pm",
+			"Call me at <PHONE_1>, or <PHONE_2> after 5pm"},
+		{"My card is 4111 1111 1111 1111 and the iban is DE89 3704 0044 0532 0130 00.",
+			"My card is <CARD_1> and the iban is <IBAN_1>."},
+		// Numbers failing the checksums are left alone.
+		{"Order 4111 1111 1111 1112 for account DE00 3704 0044 0532 0130 00",
+			"Order 4111 1111 1111 1112 for account DE00 3704 0044 0532 0130 00"},
+		{"The meeting on 2024-01-15 10:30 takes 45 minutes, 3 rooms of 120 seats",
+			"The meeting on 2024-01-15 10:30 takes 45 minutes, 3 rooms of 120 seats"},
+	}
+
+	for _, test := range tests {
+		if actual := redactor.NewSession().Redact(test.text); actual != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.text, test.expected, actual)
+		}
+	}
+}
+
+func TestSessionIsStable(t *testing.T) {
+	session := newTestRedactor(t).NewSession()
+
+	first := session.Redact("ada@example.com wrote to bob@example.com")
+	second := session.Redact("reply to bob@example.com")
+	if first != "<EMAIL_1> wrote to <EMAIL_2>" || second != "reply to <EMAIL_2>" {
+		t.Errorf("expected the same values to get the same placeholders, got %q and %q", first, second)
+	}
+
+	restored := session.Restore("I've written to <EMAIL_2>, <EMAIL_3> is unknown")
+	if restored != "I've written to bob@example.com, <EMAIL_3> is unknown" {
+		t.Errorf("unexpected restored text %q", restored)
+	}
+}
+
+func TestStreamRestorer(t *testing.T) {
+	session := newTestRedactor(t).NewSession()
+	session.Redact("ada@example.com")
+
+	restorer := session.NewStreamRestorer()
+	var restored strings.Builder
+	for _, delta := range []string{"Hi <", "EMA", "IL_1>, 2 < 3", " <PH"} {
+		restored.WriteString(restorer.Next(delta))
+	}
+	restored.WriteString(restorer.Flush())
+
+	if expected := "Hi ada@example.com, 2 < 3 <PH"; restored.String() != expected {
+		t.Errorf("expected %q, got %q", expected, restored.String())
+	}
+}
+
+func TestNilSession(t *testing.T) {
+	var session *Session
+	if session.Redact("ada@example.com") != "ada@example.com" || session.Restore("<EMAIL_1>") != "<EMAIL_1>" {
+		t.Errorf("expected a nil session to leave the text as is")
+	}
+}
@@ -0,0 +1,74 @@
+// Package schema validates json documents against json schemas supplied at runtime,
+// by the tools registered on the server or by the clients.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// The schema is registered under an url which doesn't point anywhere,
+// a relative one would be resolved against the working directory and show up in the errors.
+const resourceURL = "urn:schema"
+
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Compile parses and compiles a json schema, the latest draft is assumed unless the schema says otherwise.
+// References to external documents are not resolved.
+func Compile(raw json.RawMessage) (*Schema, error) {
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	// Never fetch anything from the network or the file system on behalf of a client.
+	compiler.UseLoader(jsonschema.SchemeURLLoader{})
+	if err := compiler.AddResource(resourceURL, document); err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiler.Compile(resourceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schema{schema: compiled}, nil
+}
+
+// Validate checks that data is a json document which conforms to the schema.
+func (s *Schema) Validate(data []byte) error {
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("not valid json: %v", err)
+	}
+
+	if err := s.schema.Validate(instance); err != nil {
+		var validationError *jsonschema.ValidationError
+		if errors.As(err, &validationError) {
+			return fmt.Errorf("doesn't match the schema: %s", strings.Join(violations(validationError, nil), "; "))
+		}
+		return fmt.Errorf("doesn't match the schema: %v", err)
+	}
+
+	return nil
+}
+
+// violations flattens the tree of validation errors into the list of its leaves,
+// each of them reads like `at '/age': minimum: got -1, want 0`.
+func violations(err *jsonschema.ValidationError, list []string) []string {
+	if len(err.Causes) == 0 {
+		return append(list, err.Error())
+	}
+	for _, cause := range err.Causes {
+		list = violations(cause, list)
+	}
+	return list
+}
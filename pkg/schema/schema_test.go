@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	compiled, err := Compile(json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer", "minimum": 0}
+		},
+		"required": ["name"]
+	}`))
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+
+	testData := []struct {
+		document string
+		expected string
+	}{
+		{`{"name": "Ada", "age": 36}`, ""},
+		{`{"age": 36}`, "doesn't match the schema"},
+		{`{"name": "Ada", "age": -1}`, "doesn't match the schema"},
+		{`{"name": `, "not valid json"},
+	}
+
+	for _, data := range testData {
+		err := compiled.Validate([]byte(data.document))
+		if data.expected == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got: %v", data.document, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), data.expected) {
+			t.Errorf("%s: expected error containing %q, got: %v", data.document, data.expected, err)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, raw := range []string{
+		`{"type": 1}`,
+		`{"type": "object"`,
+		// External references must never be resolved.
+		`{"$ref": "file:///etc/passwd"}`,
+		`{"$ref": "https://example.com/schema.json"}`,
+	} {
+		if _, err := Compile(json.RawMessage(raw)); err == nil {
+			t.Errorf("%s: expected compilation to fail", raw)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package search ranks and highlights messages matched by a full-text query
+// for the databases which have no full-text search, or no highlighting, of their own.
+package search
+
+import (
+	"html"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+// Matched words are wrapped into these in snippets, the same markers are used by postgres.
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+)
+
+// Number of words in a snippet.
+const snippetWords = 30
+
+type word struct {
+	start, end int
+}
+
+// words splits the text into words made of letters and digits.
+func words(text string) []word {
+	var result []word
+	start := -1
+	for i, r := range text {
+		letter := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case letter && start < 0:
+			start = i
+		case !letter && start >= 0:
+			result = append(result, word{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		result = append(result, word{start, len(text)})
+	}
+	return result
+}
+
+// Terms returns the lowercased words of a query, which all have to occur in a message for it to match.
+// Quotes and the operators of web search syntax are ignored, words prefixed with '-' are left out.
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, field := range strings.Fields(query) {
+		if strings.HasPrefix(field, "-") || strings.EqualFold(field, "or") {
+			continue
+		}
+		for _, w := range words(field) {
+			term := strings.ToLower(field[w.start:w.end])
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	return terms
+}
+
+// matches reports whether a word matches a term. Words which start with the term match too,
+// as a poor man's stemming, so that "deploy" finds "deployment" and "deployed".
+func matches(text string, w word, term string) bool {
+	return strings.HasPrefix(strings.ToLower(text[w.start:w.end]), term)
+}
+
+// Score ranks the content against the terms, it's zero unless every term occurs in the content.
+// Repeated occurrences raise the rank with diminishing returns, long messages are ranked lower.
+func Score(content string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	contentWords := words(content)
+
+	score := 0.0
+	for _, term := range terms {
+		frequency := 0
+		for _, w := range contentWords {
+			if matches(content, w, term) {
+				frequency++
+			}
+		}
+		if frequency == 0 {
+			return 0
+		}
+		score += 1 + math.Log(float64(frequency))
+	}
+
+	return score / math.Log(float64(len(contentWords))+math.E)
+}
+
+// Snippet returns a fragment of the content around the first matched word,
+// html escaped, with the matched words wrapped into the marks.
+func Snippet(content string, terms []string) string {
+	contentWords := words(content)
+
+	first := 0
+	for i, w := range contentWords {
+		if isMatch(content, w, terms) {
+			first = i
+			break
+		}
+	}
+
+	// A few words of context before the match.
+	from := max(0, min(first-5, len(contentWords)-snippetWords))
+	to := min(len(contentWords), from+snippetWords)
+
+	var b strings.Builder
+	start := 0
+	if from > 0 {
+		b.WriteString("… ")
+		start = contentWords[from].start
+	}
+	end := len(content)
+	if to < len(contentWords) {
+		end = contentWords[to-1].end
+	}
+
+	position := start
+	for _, w := range contentWords[from:to] {
+		if !isMatch(content, w, terms) {
+			continue
+		}
+		b.WriteString(html.EscapeString(content[position:w.start]))
+		b.WriteString(MarkStart)
+		b.WriteString(html.EscapeString(content[w.start:w.end]))
+		b.WriteString(MarkEnd)
+		position = w.end
+	}
+	b.WriteString(html.EscapeString(content[position:end]))
+	if end < len(content) {
+		b.WriteString(" …")
+	}
+
+	return b.String()
+}
+
+func isMatch(content string, w word, terms []string) bool {
+	for _, term := range terms {
+		if matches(content, w, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// After reports whether the hit comes after the cursor in the order of the results.
+func After(hit *models.MessageSearchHit, cursor *models.MessageSearchCursor) bool {
+	if cursor == nil {
+		return true
+	}
+	if hit.Rank != cursor.Rank {
+		return hit.Rank < cursor.Rank
+	}
+	if !hit.CreatedAt.Equal(cursor.CreatedAt) {
+		return hit.CreatedAt.Before(cursor.CreatedAt)
+	}
+	return hit.MessageID > cursor.MessageID
+}
+
+// Less orders the hits, the best ranked first.
+func Less(a *models.MessageSearchHit, b *models.MessageSearchHit) bool {
+	return After(b, &models.MessageSearchCursor{Rank: a.Rank, CreatedAt: a.CreatedAt, MessageID: a.MessageID})
+}
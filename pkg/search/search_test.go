@@ -0,0 +1,80 @@
+package search
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestTerms(t *testing.T) {
+	terms := Terms(`"Deploy Kubernetes" or helm -docker deploy`)
+	if expected := []string{"deploy", "kubernetes", "helm"}; !reflect.DeepEqual(terms, expected) {
+		t.Errorf("expected %v, got %v", expected, terms)
+	}
+}
+
+func TestScore(t *testing.T) {
+	terms := Terms("deploy cluster")
+
+	if score := Score("How do I deploy an app?", terms); score != 0 {
+		t.Errorf("expected no match without every term, got %f", score)
+	}
+
+	once := Score("The cluster was deployed.", terms)
+	if once == 0 {
+		t.Fatalf("expected words starting with a term to match")
+	}
+	twice := Score("The cluster was deployed, then the cluster was deployed again.", terms)
+	if twice <= once {
+		t.Errorf("expected repeated terms to rank higher, got %f and %f", twice, once)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	snippet := Snippet("Use <b>kubectl</b> to deploy & scale", Terms("deploy kubectl"))
+	expected := "Use &lt;b&gt;<mark>kubectl</mark>&lt;/b&gt; to <mark>deploy</mark> &amp; scale"
+	if snippet != expected {
+		t.Errorf("expected %q, got %q", expected, snippet)
+	}
+
+	var long []string
+	for i := range 60 {
+		long = append(long, "w"+strconv.Itoa(i))
+	}
+	long[30] = "match"
+	snippet = Snippet(strings.Join(long, " "), Terms("match"))
+	if !strings.HasPrefix(snippet, "… w25 ") || !strings.HasSuffix(snippet, " w54 …") {
+		t.Errorf("expected the snippet to be cut around the match, got %q", snippet)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	now := time.Now().UTC()
+	hits := []models.MessageSearchHit{
+		{MessageID: "b", Rank: 1, CreatedAt: now},
+		{MessageID: "c", Rank: 2, CreatedAt: now.Add(-time.Hour)},
+		{MessageID: "a", Rank: 1, CreatedAt: now},
+		{MessageID: "d", Rank: 1, CreatedAt: now.Add(time.Hour)},
+	}
+	sort.Slice(hits, func(i, j int) bool { return Less(&hits[i], &hits[j]) })
+
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.MessageID)
+	}
+	if expected := []string{"c", "d", "a", "b"}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+
+	cursor := &models.MessageSearchCursor{Rank: hits[2].Rank, CreatedAt: hits[2].CreatedAt, MessageID: hits[2].MessageID}
+	for i, hit := range hits {
+		if After(&hit, cursor) != (i > 2) {
+			t.Errorf("unexpected position of %s relative to the cursor", hit.MessageID)
+		}
+	}
+}
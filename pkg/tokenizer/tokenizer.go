@@ -0,0 +1,109 @@
+// Package tokenizer counts the tokens of prompts the way OpenAI models do,
+// with the cl100k_base and o200k_base encodings.
+// Other providers use their own tokenizers, for their models the counts are an estimate.
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+const (
+	Cl100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// Every message is wrapped into <|start|>{role}\n{content}<|end|>\n,
+// and the reply is primed with <|start|>assistant<|message|>.
+const (
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+// Models released since gpt-4o use o200k_base, the older ones cl100k_base.
+var o200kPrefixes = []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "chatgpt-4o", "o1", "o3", "o4"}
+
+func init() {
+	// The encodings are embedded into the binary rather than downloaded on first use.
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+type Tokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+var (
+	mu         sync.Mutex
+	tokenizers = make(map[string]*Tokenizer)
+)
+
+// EncodingForModel returns the name of the encoding used by the model.
+// Models this package doesn't know are assumed to use cl100k_base.
+func EncodingForModel(model string) string {
+	for _, prefix := range o200kPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return O200kBase
+		}
+	}
+	return Cl100kBase
+}
+
+// ForModel returns the tokenizer of the model's encoding.
+// Encodings are loaded on first use and shared afterwards, which takes a while the first time.
+func ForModel(model string) (*Tokenizer, error) {
+	return Get(EncodingForModel(model))
+}
+
+// Get returns the tokenizer of the encoding.
+func Get(encodingName string) (*Tokenizer, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if tokenizer, ok := tokenizers[encodingName]; ok {
+		return tokenizer, nil
+	}
+
+	encoding, err := tiktoken.GetEncoding(encodingName)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: failed to load %s encoding, error: %v", encodingName, err)
+	}
+
+	tokenizer := &Tokenizer{encoding: encoding}
+	tokenizers[encodingName] = tokenizer
+
+	return tokenizer, nil
+}
+
+// Count returns the number of tokens in the text.
+// Special tokens are counted as ordinary text, the way the api treats them in the messages.
+func (t *Tokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	return len(t.encoding.EncodeOrdinary(text))
+}
+
+// CountMessage returns the number of tokens the message takes in a prompt.
+func (t *Tokenizer) CountMessage(message *models.OpenAIMessage) int {
+	tokens := tokensPerMessage + t.Count(message.Role) + t.Count(message.Content)
+	for _, call := range message.ToolCalls {
+		tokens += t.Count(call.ID) + t.Count(call.Function.Name) + t.Count(call.Function.Arguments)
+	}
+	return tokens + t.Count(message.ToolCallID)
+}
+
+// CountMessages returns the number of tokens the messages take in a prompt,
+// including the tokens which prime the reply.
+func (t *Tokenizer) CountMessages(messages []models.OpenAIMessage) int {
+	tokens := tokensPerReply
+	for i := range messages {
+		tokens += t.CountMessage(&messages[i])
+	}
+	return tokens
+}
@@ -0,0 +1,68 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func TestEncodingForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o-mini-2024-07-18": O200kBase,
+		"gpt-4.1-nano":           O200kBase,
+		"gpt-3.5-turbo":          Cl100kBase,
+		"claude-3-5-haiku":       Cl100kBase,
+		"":                       Cl100kBase,
+	}
+	for model, expected := range tests {
+		if encoding := EncodingForModel(model); encoding != expected {
+			t.Errorf("%q: expected %s, got %s", model, expected, encoding)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	// Reference counts produced by OpenAI's tiktoken.
+	tests := []struct {
+		encoding string
+		text     string
+		expected int
+	}{
+		{Cl100kBase, "hello world", 2},
+		{Cl100kBase, "tiktoken is great!", 6},
+		{O200kBase, "hello world", 2},
+		{Cl100kBase, "", 0},
+		// Special tokens in the user's text are not special.
+		{Cl100kBase, "<|endoftext|>", 7},
+	}
+	for _, test := range tests {
+		tokenizer, err := Get(test.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := tokenizer.Count(test.text); count != test.expected {
+			t.Errorf("%s %q: expected %d tokens, got %d", test.encoding, test.text, test.expected, count)
+		}
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	tokenizer, err := ForModel("gpt-3.5-turbo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The example from OpenAI's cookbook on counting tokens, minus the name fields.
+	messages := []models.OpenAIMessage{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: "hello world"},
+	}
+	// 3 + (3 + 1 + 6) + (3 + 1 + 2)
+	if count := tokenizer.CountMessages(messages); count != 19 {
+		t.Errorf("expected 19 tokens, got %d", count)
+	}
+
+	if _, err := Get("unknown_base"); err == nil {
+		t.Error("expected an unknown encoding to fail")
+	}
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	// The server might run in a container without the zoneinfo database.
+	_ "time/tzdata"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+)
+
+// CurrentTime tells the model the current time, which it has no way of knowing otherwise.
+func CurrentTime() Tool {
+	return Tool{
+		Name:        "current_time",
+		Description: "Returns the current date and time. Use it whenever the answer depends on today's date or the time of day.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {
+					"type": "string",
+					"description": "IANA time zone name, for example Europe/Berlin. Defaults to UTC."
+				}
+			},
+			"additionalProperties": false
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, err
+			}
+
+			location := time.UTC
+			if args.Timezone != "" {
+				var err error
+				location, err = time.LoadLocation(args.Timezone)
+				if err != nil {
+					return nil, fmt.Errorf("unknown time zone %q", args.Timezone)
+				}
+			}
+
+			now := time.Now().In(location)
+			return map[string]string{
+				"time":     now.Format(time.RFC3339),
+				"weekday":  now.Weekday().String(),
+				"timezone": location.String(),
+			}, nil
+		},
+	}
+}
+
+// Calculator evaluates arithmetic expressions, models are notoriously bad at arithmetic.
+func Calculator() Tool {
+	return Tool{
+		Name:        "calculator",
+		Description: "Evaluates an arithmetic expression with +, -, *, /, %, ^ and parentheses. Use it for any calculation instead of doing it yourself.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"expression": {
+					"type": "string",
+					"description": "The expression to evaluate, for example (2 + 3) * 4.5 ^ 2"
+				}
+			},
+			"required": ["expression"],
+			"additionalProperties": false
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			var args struct {
+				Expression string `json:"expression"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, err
+			}
+
+			result, err := evaluate(args.Expression)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]float64{"result": result}, nil
+		},
+	}
+}
+
+// UserProfile lets the model look up the profile of the user it's talking to, and only that user.
+// lookup receives the caller attached to the context with WithCaller.
+func UserProfile(lookup func(ctx context.Context, caller string) (*models.UserData, error)) Tool {
+	return Tool{
+		Name:        "user_profile",
+		Description: "Returns the profile of the current user: first name, last name and email address.",
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {}, "additionalProperties": false}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			caller := Caller(ctx)
+			if caller == "" {
+				return nil, fmt.Errorf("the user is unknown")
+			}
+
+			user, err := lookup(ctx, caller)
+			if err != nil {
+				// The details of database failures are none of the model's business.
+				log.Logger.Error("tools: failed to look up the profile of %s: %v", caller, err)
+				return nil, fmt.Errorf("failed to look up the profile")
+			}
+			if user == nil {
+				return nil, fmt.Errorf("the user doesn't exist")
+			}
+
+			// Never the password hash.
+			return map[string]string{
+				"first_name": user.FirstName,
+				"last_name":  user.LastName,
+				"email":      user.Email,
+			}, nil
+		},
+	}
+}
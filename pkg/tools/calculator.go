@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// Guards against expressions like 1+(((((...))))) blowing the stack.
+const maxExpressionDepth = 64
+
+// expressionParser is a recursive descent parser of the grammar:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = power { ("*" | "/" | "%") power }
+//	power      = unary [ "^" power ]
+//	unary      = [ "-" | "+" ] unary | primary
+//	primary    = number | "(" expression ")"
+type expressionParser struct {
+	input []rune
+	pos   int
+	depth int
+}
+
+// evaluate computes the value of an arithmetic expression.
+func evaluate(expression string) (float64, error) {
+	parser := &expressionParser{input: []rune(expression)}
+
+	value, err := parser.expression()
+	if err != nil {
+		return 0, err
+	}
+
+	parser.skipSpaces()
+	if parser.pos < len(parser.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", parser.input[parser.pos], parser.pos+1)
+	}
+
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("the result is not a finite number")
+	}
+
+	return value, nil
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space character, or 0 at the end of the input.
+func (p *expressionParser) peek() rune {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *expressionParser) expression() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return 0, fmt.Errorf("the expression is nested too deeply")
+	}
+
+	value, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			rhs, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value += rhs
+		case '-':
+			p.pos++
+			rhs, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			value -= rhs
+		default:
+			return value, nil
+		}
+	}
+}
+
+func (p *expressionParser) term() (float64, error) {
+	value, err := p.power()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		operator := p.peek()
+		if operator != '*' && operator != '/' && operator != '%' {
+			return value, nil
+		}
+		p.pos++
+
+		rhs, err := p.power()
+		if err != nil {
+			return 0, err
+		}
+
+		switch operator {
+		case '*':
+			value *= rhs
+		case '/':
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value /= rhs
+		case '%':
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value = math.Mod(value, rhs)
+		}
+	}
+}
+
+// power is right associative, 2^3^2 is 2^9.
+func (p *expressionParser) power() (float64, error) {
+	base, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return 0, fmt.Errorf("the expression is nested too deeply")
+	}
+
+	exponent, err := p.power()
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Pow(base, exponent), nil
+}
+
+func (p *expressionParser) unary() (float64, error) {
+	switch p.peek() {
+	case '-', '+':
+		sign := p.input[p.pos]
+		p.pos++
+
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxExpressionDepth {
+			return 0, fmt.Errorf("the expression is nested too deeply")
+		}
+
+		value, err := p.unary()
+		if sign == '-' {
+			value = -value
+		}
+		return value, err
+	}
+
+	return p.primary()
+}
+
+func (p *expressionParser) primary() (float64, error) {
+	switch next := p.peek(); {
+	case next == 0:
+		return 0, fmt.Errorf("unexpected end of the expression")
+
+	case next == '(':
+		p.pos++
+		value, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return value, nil
+
+	case unicode.IsDigit(next) || next == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponent notation, such as 1.5e3.
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+				end++
+			}
+			if end < len(p.input) && unicode.IsDigit(p.input[end]) {
+				p.pos = end
+				for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+					p.pos++
+				}
+			}
+		}
+		value, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", string(p.input[start:p.pos]))
+		}
+		return value, nil
+
+	default:
+		return 0, fmt.Errorf("unexpected %q at position %d", next, p.pos+1)
+	}
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	testData := []struct {
+		expression string
+		expected   float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 / 4", 2.5},
+		{"10 % 4", 2},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", 4},
+		{"--3", 3},
+		{"1.5e3 + .5", 1500.5},
+		{" 7 - 2 - 1 ", 4},
+	}
+
+	for _, data := range testData {
+		result, err := evaluate(data.expression)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", data.expression, err)
+		}
+		if result != data.expected {
+			t.Errorf("%s: expected %v, got %v", data.expression, data.expected, result)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	testData := []struct {
+		expression string
+		expected   string
+	}{
+		{"", "unexpected end"},
+		{"1 / 0", "division by zero"},
+		{"(1 + 2", "missing closing parenthesis"},
+		{"1 + x", "unexpected 'x'"},
+		{"1 2", "unexpected '2'"},
+		{"1..2", "invalid number"},
+		{"10 ^ 400", "not a finite number"},
+		{strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), "nested too deeply"},
+		{strings.Repeat("-", 100) + "1", "nested too deeply"},
+	}
+
+	for _, data := range testData {
+		_, err := evaluate(data.expression)
+		if err == nil || !strings.Contains(err.Error(), data.expected) {
+			t.Errorf("%q: expected error containing %q, got: %v", data.expression, data.expected, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package tools holds the server-side functions the models are allowed to call.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/isnastish/openai/pkg/api/models"
+	"github.com/isnastish/openai/pkg/log"
+	"github.com/isnastish/openai/pkg/schema"
+)
+
+// How long a single tool call is allowed to take.
+const callTimeout = 10 * time.Second
+
+// OpenAI's restrictions on function names.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Handler runs the tool with the arguments generated by the model, which are already
+// validated against the tool's schema. The result is encoded as json and passed back to the model.
+type Handler func(ctx context.Context, arguments json.RawMessage) (interface{}, error)
+
+type Tool struct {
+	Name string
+	// Tells the model what the tool does and when to use it.
+	Description string
+	// Json schema of the arguments, must describe an object.
+	Parameters json.RawMessage
+	Handler    Handler
+}
+
+type registeredTool struct {
+	Tool
+	schema *schema.Schema
+}
+
+// Registry is populated once at startup and is safe for concurrent use afterwards.
+type Registry struct {
+	tools map[string]*registeredTool
+	// Registration order, so the definitions are sent to the model in a stable order.
+	names []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		tools: make(map[string]*registeredTool),
+	}
+}
+
+func (r *Registry) Register(tool Tool) error {
+	if !namePattern.MatchString(tool.Name) {
+		return fmt.Errorf("tools: invalid tool name %q", tool.Name)
+	}
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tools: tool %s is already registered", tool.Name)
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tools: tool %s has no handler", tool.Name)
+	}
+
+	parameters, err := schema.Compile(tool.Parameters)
+	if err != nil {
+		return fmt.Errorf("tools: invalid parameters schema of tool %s, error: %v", tool.Name, err)
+	}
+
+	r.tools[tool.Name] = &registeredTool{Tool: tool, schema: parameters}
+	r.names = append(r.names, tool.Name)
+
+	return nil
+}
+
+// Names returns the names of all the registered tools, in registration order.
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Definitions returns the definitions of the named tools in the form expected by OpenAI.
+// Unknown names are reported with an error meant to be shown to the client.
+func (r *Registry) Definitions(names []string) ([]models.OpenAITool, error) {
+	definitions := make([]models.OpenAITool, 0, len(names))
+	for _, name := range names {
+		tool, ok := r.tools[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+		definitions = append(definitions, models.OpenAITool{
+			Type: "function",
+			Function: models.OpenAIFunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions, nil
+}
+
+// Call runs the tool requested by the model and returns the content of the tool message.
+// Failures are reported to the model as `{"error": "..."}` rather than to the caller,
+// so that the model can fix the arguments or answer without the tool.
+func (r *Registry) Call(ctx context.Context, call models.OpenAIToolCall) string {
+	result, err := r.call(ctx, call)
+	if err != nil {
+		log.Logger.Warn("tools: call %s of %s failed: %v", call.ID, call.Function.Name, err)
+		result = map[string]string{"error": err.Error()}
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf(`{"error": "failed to encode the result of %s"}`, call.Function.Name)
+	}
+
+	return string(content)
+}
+
+func (r *Registry) call(ctx context.Context, call models.OpenAIToolCall) (interface{}, error) {
+	tool, ok := r.tools[call.Function.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	arguments := call.Function.Arguments
+	// Models omit the arguments of functions which don't take any.
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	if err := tool.schema.Validate([]byte(arguments)); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	log.Logger.Debug("tools: calling %s with %s", tool.Name, arguments)
+
+	return tool.Handler(ctx, json.RawMessage(arguments))
+}
+
+type callerKey struct{}
+
+// WithCaller attaches the identity of the user on whose behalf the tools are called.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller returns the identity attached with WithCaller, empty if there is none.
+func Caller(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
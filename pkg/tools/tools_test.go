@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/isnastish/openai/pkg/api/models"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	registry := NewRegistry()
+	for _, tool := range []Tool{
+		CurrentTime(),
+		Calculator(),
+		UserProfile(func(ctx context.Context, caller string) (*models.UserData, error) {
+			return &models.UserData{FirstName: "Ada", Email: caller, Password: "hash"}, nil
+		}),
+	} {
+		if err := registry.Register(tool); err != nil {
+			t.Fatalf("failed to register %s: %v", tool.Name, err)
+		}
+	}
+	return registry
+}
+
+func toolCall(name string, arguments string) models.OpenAIToolCall {
+	return models.OpenAIToolCall{ID: "call_1", Type: "function", Function: models.OpenAIFunctionCall{Name: name, Arguments: arguments}}
+}
+
+func TestRegister(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	if err := registry.Register(Calculator()); err == nil {
+		t.Errorf("expected duplicate registration to fail")
+	}
+
+	invalidSchema := Tool{Name: "broken", Parameters: json.RawMessage(`{"type": 1}`), Handler: Calculator().Handler}
+	if err := registry.Register(invalidSchema); err == nil {
+		t.Errorf("expected an invalid schema to be rejected")
+	}
+
+	invalidName := Calculator()
+	invalidName.Name = "has spaces"
+	if err := registry.Register(invalidName); err == nil {
+		t.Errorf("expected an invalid name to be rejected")
+	}
+
+	if names := strings.Join(registry.Names(), ","); names != "current_time,calculator,user_profile" {
+		t.Errorf("expected tools in registration order, got: %s", names)
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	definitions, err := registry.Definitions([]string{"calculator"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(definitions) != 1 || definitions[0].Type != "function" || definitions[0].Function.Name != "calculator" {
+		t.Errorf("unexpected definitions: %+v", definitions)
+	}
+
+	if _, err := registry.Definitions([]string{"rm_rf"}); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("expected unknown tool error, got: %v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := WithCaller(context.Background(), "ada@example.com")
+
+	testData := []struct {
+		call     models.OpenAIToolCall
+		expected string
+	}{
+		{toolCall("calculator", `{"expression": "6 * 7"}`), `{"result":42}`},
+		{toolCall("calculator", `{"expression": "1 / 0"}`), `{"error":"division by zero"}`},
+		{toolCall("calculator", `{}`), `"error":"invalid arguments: doesn't match the schema`},
+		{toolCall("calculator", `{"expression": `), `"error":"invalid arguments: not valid json`},
+		{toolCall("user_profile", ``), `{"email":"ada@example.com","first_name":"Ada","last_name":""}`},
+		{toolCall("current_time", `{"timezone": "Mars/Olympus"}`), `{"error":"unknown time zone \"Mars/Olympus\""}`},
+		{toolCall("current_time", `{"timezone": "Europe/Berlin"}`), `"timezone":"Europe/Berlin"`},
+		{toolCall("weather", `{}`), `{"error":"unknown tool \"weather\""}`},
+	}
+
+	for _, data := range testData {
+		if result := registry.Call(ctx, data.call); !strings.Contains(result, data.expected) {
+			t.Errorf("%s(%s): expected %s, got %s", data.call.Function.Name, data.call.Function.Arguments, data.expected, result)
+		}
+	}
+}
+
+func TestUserProfileWithoutCaller(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	if result := registry.Call(context.Background(), toolCall("user_profile", `{}`)); result != `{"error":"the user is unknown"}` {
+		t.Errorf("expected the lookup to be refused without a caller, got: %s", result)
+	}
+}